@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile is a file written to a temporary file next to its final path,
+// and renamed over it once complete, so readers never see a partial file
+type atomicFile struct {
+	*os.File
+
+	path string
+}
+
+// createAtomic creates the temporary file of the given final path
+func createAtomic(path string) (*atomicFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file, %w", err)
+	}
+
+	return &atomicFile{
+		File: file,
+		path: path,
+	}, nil
+}
+
+// commit syncs the temporary file and renames it over the final path
+func (f *atomicFile) commit() error {
+	if err := f.Sync(); err != nil {
+		f.abort()
+
+		return fmt.Errorf("unable to sync %s, %w", f.path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+
+		return fmt.Errorf("unable to close %s, %w", f.path, err)
+	}
+
+	// Temporary files are created with restricted permissions
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		_ = os.Remove(f.Name())
+
+		return fmt.Errorf("unable to chmod %s, %w", f.path, err)
+	}
+
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		_ = os.Remove(f.Name())
+
+		return fmt.Errorf("unable to rename %s, %w", f.path, err)
+	}
+
+	return nil
+}
+
+// abort removes the temporary file, leaving the final path untouched
+func (f *atomicFile) abort() {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// writeFileAtomic writes the data to the given path atomically
+func writeFileAtomic(path string, data []byte) error {
+	file, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.abort()
+
+		return fmt.Errorf("unable to write %s, %w", path, err)
+	}
+
+	return file.commit()
+}
+
+// writeOutput writes the command output to the output path atomically,
+// or to stdout if there is no output path. The output file is left
+// untouched if the write fails
+func writeOutput(outputPath string, stdout io.Writer, writeFn func(out io.Writer) error) error {
+	if outputPath == "" {
+		return writeFn(stdout)
+	}
+
+	file, err := createAtomic(outputPath)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFn(file); err != nil {
+		file.abort()
+
+		return err
+	}
+
+	return file.commit()
+}
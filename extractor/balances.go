@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/tm2/pkg/crypto"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define balances defaults
+const (
+	defaultBalancesDenom = "ugnot"
+	defaultTopHolders    = 10
+)
+
+var (
+	errMissingBalances  = errors.New("missing balances file argument")
+	errInvalidBalance   = errors.New("invalid balance")
+	errInvalidTopNumber = errors.New("invalid number of top holders")
+)
+
+// Define balances config
+type balancesCfg struct {
+	diffPath string
+	denom    string
+	top      int
+
+	sourceCfg
+	rejectsCfg
+}
+
+// newBalancesCmd creates the balances analytics command
+func newBalancesCmd() *ffcli.Command {
+	var (
+		cfg = &balancesCfg{}
+		fs  = flag.NewFlagSet("balances", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "balances",
+		ShortUsage: "balances [flags] <balances file>",
+		ShortHelp:  "reports the totals, top holders, changes and bank flows of a balances file",
+		LongHelp: "Reports the totals per denom and the top holders of a balances file (an <address>=<coins> line per " +
+			"balance, ie " + stagingBalancesFile + "), the balances changed since another balances file, and the " +
+			"bank sends of the holders found in the -source-path tx archive, if set",
+		FlagSet: fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return execBalances(ctx, cfg, args, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the balances command flag set
+func (c *balancesCfg) registerFlags(fs *flag.FlagSet) {
+	c.sourceCfg.registerFlags(fs)
+
+	fs.StringVar(
+		&c.diffPath,
+		"diff",
+		"",
+		"the previous balances file, the balances are compared to",
+	)
+
+	fs.StringVar(
+		&c.denom,
+		"denom",
+		defaultBalancesDenom,
+		"the denom the holders are ranked by",
+	)
+
+	fs.IntVar(
+		&c.top,
+		"top",
+		defaultTopHolders,
+		"the number of top holders to report",
+	)
+
+	c.rejectsCfg.registerFlags(fs)
+}
+
+// balanceSheet is a balances file, in file order
+type balanceSheet []gnoland.Balance
+
+// readBalanceSheet reads the balances file at the given path
+func readBalanceSheet(path string) (balanceSheet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open balances, %w", err)
+	}
+	defer file.Close()
+
+	return parseBalanceSheet(file, path)
+}
+
+// parseBalanceSheet parses a balances file, with an <address>=<coins> line per
+// balance (ie g1...=10000000ugnot). Empty lines and # comments are skipped,
+// and an address can only have a single balance
+func parseBalanceSheet(r io.Reader, name string) (balanceSheet, error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		sheet   balanceSheet
+		seen    = make(map[crypto.Address]int)
+		lineNum int
+	)
+
+	for scanner.Scan() {
+		lineNum++
+
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		address, coins, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w %s:%d, missing <address>=<coins> separator", errInvalidBalance, name, lineNum)
+		}
+
+		var (
+			balance gnoland.Balance
+			err     error
+		)
+
+		if balance.Address, err = crypto.AddressFromBech32(strings.TrimSpace(address)); err != nil {
+			return nil, fmt.Errorf("%w %s:%d, invalid address, %s", errInvalidBalance, name, lineNum, err)
+		}
+
+		if balance.Amount, err = std.ParseCoins(strings.TrimSpace(coins)); err != nil {
+			return nil, fmt.Errorf("%w %s:%d, invalid coins, %s", errInvalidBalance, name, lineNum, err)
+		}
+
+		if previous, ok := seen[balance.Address]; ok {
+			return nil, fmt.Errorf("%w %s:%d, duplicate address %s (line %d)", errInvalidBalance, name, lineNum, balance.Address, previous)
+		}
+
+		seen[balance.Address] = lineNum
+		sheet = append(sheet, balance)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s, %w", name, err)
+	}
+
+	return sheet, nil
+}
+
+// totals returns the sum of the balances, per denom
+func (s balanceSheet) totals() std.Coins {
+	var totals std.Coins
+
+	for _, balance := range s {
+		totals = totals.AddUnsafe(balance.Amount)
+	}
+
+	return totals
+}
+
+// topHolders returns the n balances holding the most of the denom, ranked by amount
+func (s balanceSheet) topHolders(denom string, n int) []gnoland.Balance {
+	holders := slices.DeleteFunc(slices.Clone(s), func(balance gnoland.Balance) bool {
+		return balance.Amount.AmountOf(denom) == 0
+	})
+
+	slices.SortStableFunc(holders, func(a, b gnoland.Balance) int {
+		amountA, amountB := a.Amount.AmountOf(denom), b.Amount.AmountOf(denom)
+
+		switch {
+		case amountA > amountB:
+			return -1
+		case amountA < amountB:
+			return 1
+		default:
+			return strings.Compare(a.Address.String(), b.Address.String())
+		}
+	})
+
+	return holders[:min(n, len(holders))]
+}
+
+// byAddress indexes the balances by address
+func (s balanceSheet) byAddress() map[crypto.Address]std.Coins {
+	balances := make(map[crypto.Address]std.Coins, len(s))
+
+	for _, balance := range s {
+		balances[balance.Address] = balance.Amount
+	}
+
+	return balances
+}
+
+// balanceChange is the change of a single balance between two balances files
+type balanceChange struct {
+	address  crypto.Address
+	previous std.Coins // nil if the address was added
+	current  std.Coins // nil if the address was removed
+}
+
+// diffBalanceSheets lists the balances changed from the previous balances file,
+// in bech32 address order
+func diffBalanceSheets(previous, current balanceSheet) []balanceChange {
+	var (
+		previousBalances = previous.byAddress()
+		currentBalances  = current.byAddress()
+		changes          []balanceChange
+	)
+
+	for _, balance := range current {
+		before, ok := previousBalances[balance.Address]
+		if ok && before.IsEqual(balance.Amount) {
+			continue
+		}
+
+		changes = append(changes, balanceChange{
+			address:  balance.Address,
+			previous: before,
+			current:  balance.Amount,
+		})
+	}
+
+	for _, balance := range previous {
+		if _, ok := currentBalances[balance.Address]; !ok {
+			changes = append(changes, balanceChange{
+				address:  balance.Address,
+				previous: balance.Amount,
+			})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b balanceChange) int {
+		return strings.Compare(a.address.String(), b.address.String())
+	})
+
+	return changes
+}
+
+// balancesReport is the report of a balances file
+type balancesReport struct {
+	sheet   balanceSheet
+	denom   string
+	top     int
+	changes []balanceChange
+	diffed  bool
+
+	transfers *transferGraph // nil if no tx archive was given
+}
+
+// execBalances runs the balances analytics
+func execBalances(ctx context.Context, cfg *balancesCfg, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errMissingBalances
+	}
+
+	if cfg.top < 0 {
+		return errInvalidTopNumber
+	}
+
+	sheet, err := readBalanceSheet(args[0])
+	if err != nil {
+		return err
+	}
+
+	report := &balancesReport{
+		sheet: sheet,
+		denom: cfg.denom,
+		top:   cfg.top,
+	}
+
+	if cfg.diffPath != "" {
+		previous, err := readBalanceSheet(cfg.diffPath)
+		if err != nil {
+			return err
+		}
+
+		report.changes = diffBalanceSheets(previous, sheet)
+		report.diffed = true
+	}
+
+	if cfg.sourcePath != "" {
+		if err := cfg.sourceCfg.validate(); err != nil {
+			return err
+		}
+
+		sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
+		if err != nil {
+			return err
+		}
+
+		rejects, err := newRejectHandler(cfg.rejectsCfg)
+		if err != nil {
+			return err
+		}
+		defer rejects.close()
+
+		report.transfers = newTransferGraph()
+
+		for transfer, err := range archiveBankTransfers(ctx, sourceFiles, rejects) {
+			if err != nil {
+				return err
+			}
+
+			report.transfers.add(transfer)
+		}
+	}
+
+	if _, err := io.WriteString(stdout, report.render()); err != nil {
+		return fmt.Errorf("unable to write balances report, %w", err)
+	}
+
+	return nil
+}
+
+// render renders the balances report
+func (r *balancesReport) render() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "addresses: %d\n", len(r.sheet))
+	b.WriteString("totals:\n")
+
+	for _, coin := range r.sheet.totals() {
+		fmt.Fprintf(&b, "  %s\n", coin)
+	}
+
+	holders := r.sheet.topHolders(r.denom, r.top)
+
+	fmt.Fprintf(&b, "top holders (%s):\n", r.denom)
+
+	for i, holder := range holders {
+		fmt.Fprintf(&b, "  %d. %s %s\n", i+1, holder.Address, holder.Amount)
+	}
+
+	if r.diffed {
+		fmt.Fprintf(&b, "changes: %d\n", len(r.changes))
+
+		for _, change := range r.changes {
+			switch {
+			case change.previous == nil:
+				fmt.Fprintf(&b, "  + %s %s\n", change.address, change.current)
+			case change.current == nil:
+				fmt.Fprintf(&b, "  - %s %s\n", change.address, change.previous)
+			default:
+				fmt.Fprintf(
+					&b,
+					"  ~ %s %s -> %s (%s)\n",
+					change.address,
+					change.previous,
+					change.current,
+					formatCoinsDelta(change.current.SubUnsafe(change.previous)),
+				)
+			}
+		}
+	}
+
+	if r.transfers == nil {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "bank sends: %d\n", r.transfers.count)
+	fmt.Fprintf(&b, "balance flows (%s):\n", r.denom)
+
+	var (
+		balances = r.sheet.byAddress()
+		moved    []crypto.Address
+		unknown  int
+	)
+
+	for address := range r.transfers.flows {
+		if _, ok := balances[address]; !ok {
+			unknown++
+
+			continue
+		}
+
+		moved = append(moved, address)
+	}
+
+	// The balances that moved the most of the denom come first
+	r.transfers.sortByVolume(moved, r.denom)
+
+	for _, address := range moved[:min(r.top, len(moved))] {
+		flow := r.transfers.flows[address]
+
+		fmt.Fprintf(
+			&b,
+			"  %s %s: received %s (%d sends), sent %s (%d sends), net %s\n",
+			address,
+			balances[address],
+			formatCoins(flow.received),
+			flow.receivedCount,
+			formatCoins(flow.sent),
+			flow.sentCount,
+			formatCoinsDelta(flow.net()),
+		)
+	}
+
+	fmt.Fprintf(&b, "balance addresses with bank sends: %d\n", len(moved))
+	fmt.Fprintf(&b, "bank send addresses without balance: %d\n", unknown)
+
+	return b.String()
+}
+
+// formatCoins formats the coins, or 0 if there are none
+func formatCoins(coins std.Coins) string {
+	if coins.IsZero() {
+		return "0"
+	}
+
+	return coins.String()
+}
+
+// formatCoinsDelta formats the signed coins, with an explicit sign
+func formatCoinsDelta(coins std.Coins) string {
+	if coins.IsZero() {
+		return "0"
+	}
+
+	deltas := make([]string, 0, len(coins))
+
+	for _, coin := range coins {
+		if coin.IsPositive() {
+			deltas = append(deltas, "+"+coin.String())
+		} else {
+			deltas = append(deltas, coin.String())
+		}
+	}
+
+	return strings.Join(deltas, ",")
+}
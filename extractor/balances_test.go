@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testHolderA = "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5"
+	testHolderB = "g1qpymzwx4l4cy6cerdyajp9ksvjsf20rk5y9rtt"
+	testHolderC = "g1us8428u2a5satrlxzagqqa5m6vmuze025anjlj"
+)
+
+// sendLine renders a tx-archive line with a bank send, at the block height
+func sendLine(t *testing.T, from, to, coins string, height int64) string {
+	t.Helper()
+
+	amount, err := std.ParseCoins(coins)
+	require.NoError(t, err)
+
+	line, err := amino.MarshalJSON(gnoland.TxWithMetadata{
+		Tx: std.Tx{
+			Msgs: []std.Msg{
+				bank.MsgSend{
+					FromAddress: addressFromString(t, from),
+					ToAddress:   addressFromString(t, to),
+					Amount:      amount,
+				},
+			},
+		},
+		Metadata: &gnoland.GnoTxMetadata{
+			Timestamp:   1717236000 + height,
+			BlockHeight: height,
+		},
+	})
+	require.NoError(t, err)
+
+	return string(line) + "\n"
+}
+
+func TestParseBalanceSheet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		sheet, err := parseBalanceSheet(strings.NewReader(
+			"# staging balances\n"+
+				testHolderB+"=10ugnot\n"+
+				"\n"+
+				testHolderA+" = 5foo,20ugnot # faucet\n",
+		), "balances")
+		require.NoError(t, err)
+
+		require.Len(t, sheet, 2)
+		assert.Equal(t, testHolderB+"=10ugnot", sheet[0].String())
+		assert.Equal(t, testHolderA+"=5foo,20ugnot", sheet[1].String())
+
+		assert.Equal(t, "5foo,30ugnot", sheet.totals().String())
+	})
+
+	testTable := []struct {
+		name     string
+		input    string
+		contains string
+	}{
+		{"missing separator", testHolderA + " 10ugnot\n", "balances:1, missing"},
+		{"invalid address", "g1invalid=10ugnot\n", "balances:1, invalid address"},
+		{"invalid coins", testHolderA + "=ugnot10\n", "balances:1, invalid coins"},
+		{"duplicate address", testHolderA + "=10ugnot\n" + testHolderB + "=1ugnot\n" + testHolderA + "=5ugnot\n", "balances:3, duplicate address"},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseBalanceSheet(strings.NewReader(testCase.input), "balances")
+			require.ErrorIs(t, err, errInvalidBalance)
+			assert.Contains(t, err.Error(), testCase.contains)
+		})
+	}
+}
+
+func TestBalanceSheet(t *testing.T) {
+	t.Parallel()
+
+	parse := func(t *testing.T, input string) balanceSheet {
+		t.Helper()
+
+		sheet, err := parseBalanceSheet(strings.NewReader(input), "balances")
+		require.NoError(t, err)
+
+		return sheet
+	}
+
+	t.Run("top holders", func(t *testing.T) {
+		t.Parallel()
+
+		sheet := parse(t, testHolderA+"=10ugnot\n"+testHolderB+"=5foo\n"+testHolderC+"=30ugnot\n")
+
+		holders := sheet.topHolders("ugnot", 5)
+		require.Len(t, holders, 2)
+		assert.Equal(t, testHolderC, holders[0].Address.String())
+		assert.Equal(t, testHolderA, holders[1].Address.String())
+
+		assert.Len(t, sheet.topHolders("ugnot", 1), 1)
+		assert.Len(t, sheet.topHolders("foo", 5), 1)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		t.Parallel()
+
+		previous := parse(t, testHolderA+"=10ugnot\n"+testHolderB+"=5ugnot\n"+testHolderC+"=1ugnot\n")
+		current := parse(t, testHolderC+"=1ugnot\n"+testHolderA+"=3foo,12ugnot\n")
+
+		changes := diffBalanceSheets(previous, current)
+		require.Len(t, changes, 2)
+
+		assert.Equal(t, testHolderA, changes[0].address.String())
+		assert.Equal(t, "+3foo,+2ugnot", formatCoinsDelta(changes[0].current.SubUnsafe(changes[0].previous)))
+
+		assert.Equal(t, testHolderB, changes[1].address.String())
+		assert.Nil(t, changes[1].current)
+	})
+}
+
+func TestBalances(t *testing.T) {
+	t.Parallel()
+
+	sourceDir := writeBackupFiles(t, map[string]string{
+		"backup_0000001-0000010.jsonl": sendLine(t, testHolderA, testHolderB, "100ugnot", 2) +
+			sendLine(t, testHolderA, testHolderC, "50ugnot", 4) +
+			"{\"tx\": \n",
+		"backup_0000011-0000020.jsonl": sendLine(t, testHolderB, testHolderA, "3foo,20ugnot", 15) +
+			sendLine(t, testHolderB, "g1q6jrp203fq0239pv38sdq3y3urvd6vt5azacpv", "1ugnot", 16),
+		stagingBalancesFile: testHolderA + "=1000ugnot\n" + testHolderB + "=3foo,80ugnot\n",
+		"previous.txt":      testHolderA + "=1000ugnot\n" + testHolderC + "=10ugnot\n",
+	})
+
+	balances := func(t *testing.T, cfg *balancesCfg) (string, error) {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		if cfg.denom == "" {
+			cfg.denom = defaultBalancesDenom
+		}
+
+		var out bytes.Buffer
+
+		err := execBalances(ctx, cfg, []string{filepath.Join(sourceDir, stagingBalancesFile)}, &out)
+
+		return out.String(), err
+	}
+
+	t.Run("totals and top holders", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := balances(t, &balancesCfg{top: 1})
+		require.NoError(t, err)
+
+		assert.Equal(t, "addresses: 2\n"+
+			"totals:\n"+
+			"  3foo\n"+
+			"  1080ugnot\n"+
+			"top holders (ugnot):\n"+
+			"  1. "+testHolderA+" 1000ugnot\n", out)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := balances(t, &balancesCfg{diffPath: filepath.Join(sourceDir, "previous.txt")})
+		require.NoError(t, err)
+
+		assert.Contains(t, out, "changes: 2\n"+
+			"  + "+testHolderB+" 3foo,80ugnot\n"+
+			"  - "+testHolderC+" 10ugnot\n")
+	})
+
+	t.Run("bank flows", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := balances(t, &balancesCfg{
+			sourceCfg: sourceCfg{fileType: ".jsonl", sourcePath: sourceDir},
+			top:       10,
+		})
+		require.NoError(t, err)
+
+		assert.Contains(t, out, "bank sends: 4\n"+
+			"balance flows (ugnot):\n"+
+			"  "+testHolderA+" 1000ugnot: received 3foo,20ugnot (1 sends), sent 150ugnot (2 sends), net +3foo,-130ugnot\n"+
+			"  "+testHolderB+" 3foo,80ugnot: received 100ugnot (1 sends), sent 3foo,21ugnot (2 sends), net -3foo,+79ugnot\n"+
+			"balance addresses with bank sends: 2\n"+
+			"bank send addresses without balance: 2\n")
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := balances(t, &balancesCfg{
+			sourceCfg:  sourceCfg{fileType: ".jsonl", sourcePath: sourceDir},
+			rejectsCfg: rejectsCfg{strict: true},
+		})
+		assert.ErrorIs(t, err, errInvalidTxLine)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		var out bytes.Buffer
+
+		assert.ErrorIs(t, execBalances(context.Background(), &balancesCfg{}, nil, &out), errMissingBalances)
+		assert.ErrorIs(t, execBalances(context.Background(), &balancesCfg{top: -1}, []string{"balances"}, &out), errInvalidTopNumber)
+
+		_, err := balances(t, &balancesCfg{sourceCfg: sourceCfg{sourcePath: sourceDir}})
+		assert.ErrorIs(t, err, errInvalidFileType)
+	})
+}
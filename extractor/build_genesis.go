@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	bft "github.com/gnolang/gno/tm2/pkg/bft/types"
+	"github.com/gnolang/gno/tm2/pkg/crypto"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define build genesis constants
+const (
+	defaultGenesisFile    = "genesis.json"
+	defaultGenesisChainID = "dev"
+
+	// The voting power of a validator without one, as gnoland start gives its test validator
+	defaultValidatorPower = 10
+)
+
+var (
+	errInvalidBlockRange = errors.New("invalid block range")
+	errInvalidChainID    = errors.New("invalid chain ID")
+	errInvalidValidator  = errors.New("invalid validator")
+)
+
+// Define build genesis config
+type buildGenesisCfg struct {
+	balancesPath string
+	outputPath   string
+	chainID      string
+	fromBlock    uint64
+	toBlock      uint64
+	pkgPath      string
+	msgTypes     string
+	validators   string
+
+	sourceCfg
+	rejectsCfg
+}
+
+// newBuildGenesisCmd creates the genesis build command
+func newBuildGenesisCmd() *ffcli.Command {
+	var (
+		cfg = &buildGenesisCfg{}
+		fs  = flag.NewFlagSet("build-genesis", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "build-genesis",
+		ShortUsage: "build-genesis [flags]",
+		ShortHelp:  "builds a genesis.json replaying the archived txs",
+		LongHelp: "Builds a gno.land genesis.json holding the archived txs as its app_state txs, in chain order, " +
+			"along with the balances of a balances file. The txs can be filtered by block range, package path " +
+			"prefix and message type. The validators of the local node are given with -validator",
+		FlagSet: fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execBuildGenesis(ctx, cfg, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the build genesis command flag set
+func (c *buildGenesisCfg) registerFlags(fs *flag.FlagSet) {
+	c.sourceCfg.registerFlags(fs)
+
+	fs.StringVar(
+		&c.balancesPath,
+		"balances-path",
+		"",
+		"the balances file of the genesis, with an <address>=<coins> line per balance (ie "+stagingBalancesFile+")",
+	)
+
+	fs.StringVar(
+		&c.outputPath,
+		"output-path",
+		defaultGenesisFile,
+		"the output genesis file",
+	)
+
+	fs.StringVar(
+		&c.chainID,
+		"chain-id",
+		defaultGenesisChainID,
+		"the chain ID of the genesis",
+	)
+
+	fs.Uint64Var(
+		&c.fromBlock,
+		"from-block",
+		0,
+		"only include the txs from this block height, txs of an unknown or estimated height are left out if set",
+	)
+
+	fs.Uint64Var(
+		&c.toBlock,
+		"to-block",
+		0,
+		"only include the txs up to this block height (all if 0), txs of an unknown or estimated height are left out if set",
+	)
+
+	fs.StringVar(
+		&c.pkgPath,
+		"pkg-path",
+		"",
+		"only include the txs with a message on a package with this path prefix (ie gno.land/r/gnoswap)",
+	)
+
+	fs.StringVar(
+		&c.msgTypes,
+		"msg-type",
+		"",
+		"only include the txs with a message of these comma separated types (ie add_package,exec,run,send)",
+	)
+
+	fs.StringVar(
+		&c.validators,
+		"validator",
+		"",
+		"the comma separated genesis validators, as <bech32 pubkey>[=<power>] (ie from gnoland secrets get validator_key), with a power of "+
+			strconv.Itoa(defaultValidatorPower)+" if not set",
+	)
+
+	c.rejectsCfg.registerFlags(fs)
+}
+
+// genesisTxFilter selects the archived txs included in the genesis
+type genesisTxFilter struct {
+	fromBlock uint64
+	toBlock   uint64
+	pkgPath   string
+	msgTypes  []string
+}
+
+// execBuildGenesis runs the genesis build
+func execBuildGenesis(ctx context.Context, cfg *buildGenesisCfg, stdout io.Writer) error {
+	// Check the source is valid
+	if err := cfg.sourceCfg.validate(); err != nil {
+		return err
+	}
+
+	if cfg.toBlock != 0 && cfg.toBlock < cfg.fromBlock {
+		return fmt.Errorf("%w %d-%d", errInvalidBlockRange, cfg.fromBlock, cfg.toBlock)
+	}
+
+	if strings.TrimSpace(cfg.chainID) == "" {
+		return errInvalidChainID
+	}
+
+	validators, err := parseGenesisValidators(cfg.validators)
+	if err != nil {
+		return err
+	}
+
+	sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	sortArchiveFiles(sourceFiles)
+
+	rejects, err := newRejectHandler(cfg.rejectsCfg)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
+
+	filter := genesisTxFilter{
+		fromBlock: cfg.fromBlock,
+		toBlock:   cfg.toBlock,
+		pkgPath:   cfg.pkgPath,
+		msgTypes:  parseMsgTypes(cfg.msgTypes),
+	}
+
+	state := gnoland.DefaultGenState()
+
+	var (
+		skipped    int
+		estimated  int // skipped txs without a recorded block height
+		duplicates int // txs backed up twice, by overlapping files
+
+		included = make(map[string]struct{}) // the keys of the included txs
+	)
+
+	for _, sourceFile := range sourceFiles {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		// Files named after a block range out of the filter hold no tx to include
+		if r, ok := blockRangeFromPath(sourceFile); ok && !filter.overlaps(r) {
+			continue
+		}
+
+		decoder := newAutoDecoder(sourceFile)
+
+		opts := streamOpts{
+			decodeFn: func(line []byte) (archiveEntry, error) {
+				entry, err := decoder.decode(line)
+				if err != nil {
+					return entry, err
+				}
+
+				if !filter.matches(entry) {
+					skipped++
+
+					if filter.hasBlockRange() && entry.heightSource != heightSourceBlock {
+						estimated++
+					}
+
+					return entry, nil
+				}
+
+				// Files with overlapping block ranges back up the same txs
+				key, err := genesisTxKey(entry)
+				if err != nil {
+					return entry, err
+				}
+
+				if _, ok := included[key]; ok {
+					duplicates++
+
+					return entry, nil
+				}
+
+				included[key] = struct{}{}
+				state.Txs = append(state.Txs, genesisTx(entry))
+
+				return entry, nil
+			},
+			rejectFn:    rejects.reject,
+			maxLineSize: rejects.maxLineSize,
+		}
+
+		for _, err := range streamFileMessages(sourceFile, opts) {
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if cfg.balancesPath != "" {
+		balances, err := readBalanceSheet(cfg.balancesPath)
+		if err != nil {
+			return err
+		}
+
+		state.Balances = balances
+	}
+
+	genesis := &bft.GenesisDoc{
+		GenesisTime:     time.Now().UTC().Truncate(time.Second),
+		ChainID:         cfg.chainID,
+		ConsensusParams: bft.DefaultConsensusParams(),
+		Validators:      validators,
+		AppState:        state,
+	}
+
+	raw, err := amino.MarshalJSONIndent(genesis, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal genesis, %w", err)
+	}
+
+	if err := writeFileAtomic(cfg.outputPath, append(raw, '\n')); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "txs: %d, skipped: %d, duplicates: %d\n", len(state.Txs), skipped, duplicates)
+
+	if estimated > 0 {
+		fmt.Fprintf(stdout, "skipped without a block height: %d\n", estimated)
+	}
+
+	fmt.Fprintf(stdout, "balances: %d\n", len(state.Balances))
+	fmt.Fprintf(stdout, "validators: %d\n", len(validators))
+	fmt.Fprintf(stdout, "wrote %s\n", cfg.outputPath)
+
+	return nil
+}
+
+// genesisTx returns the genesis tx of an archived tx. The tx-archive metadata is
+// kept as is, while the txs of the older formats get the known height and timestamp
+func genesisTx(entry archiveEntry) gnoland.TxWithMetadata {
+	if entry.metadata != nil {
+		return gnoland.TxWithMetadata{
+			Tx:       entry.tx,
+			Metadata: entry.metadata,
+		}
+	}
+
+	tx := gnoland.TxWithMetadata{
+		Tx: entry.tx,
+	}
+
+	if entry.height != 0 || entry.timestamp != 0 {
+		tx.Metadata = &gnoland.GnoTxMetadata{
+			Timestamp:   entry.timestamp,
+			BlockHeight: int64(entry.height),
+		}
+	}
+
+	return tx
+}
+
+// genesisTxKey returns the key identifying an archived tx across overlapping
+// files: its hash, at its block height if recorded. The height estimated from
+// the file range differs between the files, and is left out
+func genesisTxKey(entry archiveEntry) (string, error) {
+	hash, err := txHash(entry.tx)
+	if err != nil {
+		return "", err
+	}
+
+	if entry.heightSource != heightSourceBlock {
+		return hash, nil
+	}
+
+	return fmt.Sprintf("%d/%s", entry.height, hash), nil
+}
+
+// overlaps checks if the block range overlaps the filter block range
+func (f genesisTxFilter) overlaps(r blockRange) bool {
+	if r.to < f.fromBlock {
+		return false
+	}
+
+	return f.toBlock == 0 || r.from <= f.toBlock
+}
+
+// hasBlockRange checks if the filter is on a block range
+func (f genesisTxFilter) hasBlockRange() bool {
+	return f.fromBlock != 0 || f.toBlock != 0
+}
+
+// matches checks if the archived tx passes the filter. The block range filter
+// leaves out the txs of an unknown height, and the txs whose height is only
+// estimated from their file range, as they could be on either side of the range.
+// The message filters keep the txs with at least one message of the requested
+// type, on the requested packages
+func (f genesisTxFilter) matches(entry archiveEntry) bool {
+	if f.hasBlockRange() {
+		if entry.heightSource != heightSourceBlock || entry.height < f.fromBlock {
+			return false
+		}
+
+		if f.toBlock != 0 && entry.height > f.toBlock {
+			return false
+		}
+	}
+
+	if f.pkgPath == "" && len(f.msgTypes) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(entry.tx.Msgs, func(msg std.Msg) bool {
+		if len(f.msgTypes) > 0 && !slices.Contains(f.msgTypes, msg.Type()) {
+			return false
+		}
+
+		if f.pkgPath == "" {
+			return true
+		}
+
+		pkgPath, ok := msgPkgPath(msg)
+
+		return ok && strings.HasPrefix(pkgPath, f.pkgPath)
+	})
+}
+
+// parseMsgTypes parses the comma separated message types
+func parseMsgTypes(msgTypes string) []string {
+	var parsed []string
+
+	for _, msgType := range strings.Split(msgTypes, ",") {
+		if msgType = strings.TrimSpace(msgType); msgType != "" {
+			parsed = append(parsed, msgType)
+		}
+	}
+
+	return parsed
+}
+
+// parseGenesisValidators parses the comma separated <bech32 pubkey>[=<power>] validators
+func parseGenesisValidators(validators string) ([]bft.GenesisValidator, error) {
+	var parsed []bft.GenesisValidator
+
+	for _, validator := range strings.Split(validators, ",") {
+		if validator = strings.TrimSpace(validator); validator == "" {
+			continue
+		}
+
+		var (
+			pubKeyRaw, powerRaw, hasPower = strings.Cut(validator, "=")
+			power                         = int64(defaultValidatorPower)
+		)
+
+		pubKey, err := crypto.PubKeyFromBech32(pubKeyRaw)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q, %w", errInvalidValidator, validator, err)
+		}
+
+		if hasPower {
+			power, err = strconv.ParseInt(powerRaw, 10, 64)
+			if err != nil || power <= 0 {
+				return nil, fmt.Errorf("%w %q, invalid power", errInvalidValidator, validator)
+			}
+		}
+
+		parsed = append(parsed, bft.GenesisValidator{
+			Address: pubKey.Address(),
+			PubKey:  pubKey,
+			Power:   power,
+			Name:    fmt.Sprintf("validator%d", len(parsed)+1),
+		})
+	}
+
+	return parsed, nil
+}
+
+// msgPkgPath returns the path of the package the message is on, if any
+func msgPkgPath(msg std.Msg) (string, bool) {
+	switch msg := msg.(type) {
+	case vm.MsgAddPackage:
+		if msg.Package != nil {
+			return msg.Package.Path, true
+		}
+	case vm.MsgCall:
+		return msg.PkgPath, true
+	case vm.MsgRun:
+		if msg.Package != nil {
+			return msg.Package.Path, true
+		}
+	}
+
+	return "", false
+}
+
+// sortArchiveFiles sorts the archive files in chain order, by the first block
+// height (or staging tx number) of their name. Files named after no range come
+// first, in name order
+func sortArchiveFiles(paths []string) {
+	slices.Sort(paths)
+
+	slices.SortStableFunc(paths, func(a, b string) int {
+		startA, startB := archiveFileStart(a), archiveFileStart(b)
+
+		switch {
+		case startA < startB:
+			return -1
+		case startA > startB:
+			return 1
+		default:
+			return 0
+		}
+	})
+}
+
+// archiveFileStart returns the first block height (or staging tx number) of the
+// archive file name, or 0 if the name holds no range
+func archiveFileStart(path string) uint64 {
+	if r, ok := blockRangeFromPath(path); ok {
+		return r.from
+	}
+
+	if matches := stagingChunkRegex.FindStringSubmatch(filepath.Base(path)); matches != nil {
+		start, _ := strconv.ParseUint(matches[1], 10, 64)
+
+		return start
+	}
+
+	return 0
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	bft "github.com/gnolang/gno/tm2/pkg/bft/types"
+	"github.com/gnolang/gno/tm2/pkg/crypto"
+	"github.com/gnolang/gno/tm2/pkg/crypto/ed25519"
+	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildGenesis(t *testing.T) {
+	t.Parallel()
+
+	var (
+		caller = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+
+		addPkg = vm.MsgAddPackage{
+			Creator: caller,
+			Package: &std.MemPackage{
+				Name:  "foo",
+				Path:  "gno.land/r/demo/foo",
+				Files: []*std.MemFile{{Name: "foo.gno", Body: "package foo"}},
+			},
+		}
+		call = vm.MsgCall{Caller: caller, PkgPath: "gno.land/r/demo/foo", Func: "Foo"}
+		send = bank.MsgSend{FromAddress: caller, ToAddress: caller, Amount: std.NewCoins(std.NewCoin("ugnot", 1))}
+	)
+
+	// txLine renders a tx-archive line with the message, at the block height
+	txLine := func(t *testing.T, msg std.Msg, height int64) string {
+		t.Helper()
+
+		line, err := amino.MarshalJSON(gnoland.TxWithMetadata{
+			Tx: std.Tx{Msgs: []std.Msg{msg}, Memo: "genesis"},
+			Metadata: &gnoland.GnoTxMetadata{
+				Timestamp:   1717236000 + height,
+				BlockHeight: height,
+				ChainID:     "test5",
+			},
+		})
+		require.NoError(t, err)
+
+		return string(line) + "\n"
+	}
+
+	legacyLine, err := amino.MarshalJSON(LegacyTx{Tx: std.Tx{Msgs: []std.Msg{call}}, BlockNum: 3})
+	require.NoError(t, err)
+
+	sourceDir := writeBackupFiles(t, map[string]string{
+		"backup_0000001-0000010.jsonl":  txLine(t, addPkg, 2) + string(legacyLine) + "\n" + txLine(t, send, 5),
+		"backup_0000011-0000020.jsonl":  txLine(t, call, 12) + "{\"tx\": \n",
+		"backup_0000021-0000030.jsonl":  txLine(t, call, 25),
+		"backup_staging_balances.jsonl": "# staging balances\n" + caller.String() + "=10ugnot\n",
+	})
+
+	build := func(t *testing.T, cfg *buildGenesisCfg) (string, bft.GenesisDoc) {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		cfg.fileType = ".jsonl"
+		cfg.sourcePath = sourceDir
+		cfg.outputPath = filepath.Join(t.TempDir(), defaultGenesisFile)
+
+		if cfg.chainID == "" {
+			cfg.chainID = defaultGenesisChainID
+		}
+
+		var out bytes.Buffer
+
+		require.NoError(t, execBuildGenesis(ctx, cfg, &out))
+
+		raw, err := os.ReadFile(cfg.outputPath)
+		require.NoError(t, err)
+
+		var genesis bft.GenesisDoc
+		require.NoError(t, amino.UnmarshalJSON(raw, &genesis))
+
+		return out.String(), genesis
+	}
+
+	// heights returns the block heights of the genesis txs
+	heights := func(t *testing.T, genesis bft.GenesisDoc) []int64 {
+		t.Helper()
+
+		state, ok := genesis.AppState.(gnoland.GnoGenesisState)
+		require.True(t, ok)
+
+		heights := make([]int64, 0, len(state.Txs))
+
+		for _, tx := range state.Txs {
+			require.NotNil(t, tx.Metadata)
+
+			heights = append(heights, tx.Metadata.BlockHeight)
+		}
+
+		return heights
+	}
+
+	t.Run("whole archive", func(t *testing.T) {
+		t.Parallel()
+
+		out, genesis := build(t, &buildGenesisCfg{
+			balancesPath: filepath.Join(sourceDir, stagingBalancesFile),
+			chainID:      "test5-replay",
+		})
+
+		assert.Contains(t, out, "txs: 5, skipped: 0, duplicates: 0\nbalances: 1\n")
+
+		assert.Equal(t, "test5-replay", genesis.ChainID)
+		assert.Equal(t, []int64{2, 3, 5, 12, 25}, heights(t, genesis))
+
+		state := genesis.AppState.(gnoland.GnoGenesisState)
+
+		// The tx-archive metadata is kept as is
+		assert.Equal(t, &gnoland.GnoTxMetadata{Timestamp: 1717236002, BlockHeight: 2, ChainID: "test5"}, state.Txs[0].Metadata)
+		assert.Equal(t, &gnoland.GnoTxMetadata{BlockHeight: 3}, state.Txs[1].Metadata)
+
+		require.Len(t, state.Balances, 1)
+		assert.Equal(t, caller.String()+"=10ugnot", state.Balances[0].String())
+	})
+
+	t.Run("block range", func(t *testing.T) {
+		t.Parallel()
+
+		out, genesis := build(t, &buildGenesisCfg{fromBlock: 3, toBlock: 12})
+
+		assert.Contains(t, out, "txs: 3, skipped: 1, duplicates: 0\n")
+		assert.Equal(t, []int64{3, 5, 12}, heights(t, genesis))
+	})
+
+	t.Run("package path", func(t *testing.T) {
+		t.Parallel()
+
+		_, genesis := build(t, &buildGenesisCfg{pkgPath: "gno.land/r/demo"})
+		assert.Equal(t, []int64{2, 3, 12, 25}, heights(t, genesis))
+
+		_, genesis = build(t, &buildGenesisCfg{pkgPath: "gno.land/r/gnoswap"})
+		assert.Empty(t, heights(t, genesis))
+	})
+
+	t.Run("message type", func(t *testing.T) {
+		t.Parallel()
+
+		_, genesis := build(t, &buildGenesisCfg{msgTypes: "add_package, send"})
+		assert.Equal(t, []int64{2, 5}, heights(t, genesis))
+
+		_, genesis = build(t, &buildGenesisCfg{msgTypes: msgTypeCall, pkgPath: "gno.land/r/demo", toBlock: 20})
+		assert.Equal(t, []int64{3, 12}, heights(t, genesis))
+	})
+
+	t.Run("estimated heights", func(t *testing.T) {
+		t.Parallel()
+
+		// Bare txs only have the height of their file range
+		bareLine, err := amino.MarshalJSON(std.Tx{Msgs: []std.Msg{call}})
+		require.NoError(t, err)
+
+		bareDir := writeBackupFiles(t, map[string]string{
+			"backup_0000001-0000010.jsonl": txLine(t, addPkg, 2),
+			"backup_0000011-0000020.jsonl": string(bareLine) + "\n",
+		})
+
+		cfg := &buildGenesisCfg{
+			sourceCfg:  sourceCfg{fileType: ".jsonl", sourcePath: bareDir},
+			outputPath: filepath.Join(t.TempDir(), defaultGenesisFile),
+			chainID:    defaultGenesisChainID,
+			fromBlock:  11,
+		}
+
+		var out bytes.Buffer
+
+		require.NoError(t, execBuildGenesis(context.Background(), cfg, &out))
+		assert.Contains(t, out.String(), "txs: 0, skipped: 1, duplicates: 0\nskipped without a block height: 1\n")
+
+		cfg.fromBlock = 0
+
+		out.Reset()
+
+		require.NoError(t, execBuildGenesis(context.Background(), cfg, &out))
+		assert.Contains(t, out.String(), "txs: 2, skipped: 0, duplicates: 0\nbalances: 0\n")
+	})
+
+	t.Run("overlapping files", func(t *testing.T) {
+		t.Parallel()
+
+		// Both files back up the boundary block 10
+		overlapDir := writeBackupFiles(t, map[string]string{
+			"backup_0000001-0000010.jsonl": txLine(t, addPkg, 2) + txLine(t, send, 10),
+			"backup_0000010-0000020.jsonl": txLine(t, send, 10) + txLine(t, call, 12),
+		})
+
+		cfg := &buildGenesisCfg{
+			sourceCfg:  sourceCfg{fileType: ".jsonl", sourcePath: overlapDir},
+			outputPath: filepath.Join(t.TempDir(), defaultGenesisFile),
+			chainID:    defaultGenesisChainID,
+		}
+
+		var out bytes.Buffer
+
+		require.NoError(t, execBuildGenesis(context.Background(), cfg, &out))
+		assert.Contains(t, out.String(), "txs: 3, skipped: 0, duplicates: 1\n")
+
+		raw, err := os.ReadFile(cfg.outputPath)
+		require.NoError(t, err)
+
+		var genesis bft.GenesisDoc
+		require.NoError(t, amino.UnmarshalJSON(raw, &genesis))
+
+		assert.Equal(t, []int64{2, 10, 12}, heights(t, genesis))
+	})
+
+	t.Run("validators", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			first  = ed25519.GenPrivKey().PubKey()
+			second = ed25519.GenPrivKey().PubKey()
+		)
+
+		out, genesis := build(t, &buildGenesisCfg{
+			validators: crypto.PubKeyToBech32(first) + ", " + crypto.PubKeyToBech32(second) + "=5",
+		})
+
+		assert.Contains(t, out, "validators: 2\n")
+
+		assert.Equal(t, []bft.GenesisValidator{
+			{Address: first.Address(), PubKey: first, Power: defaultValidatorPower, Name: "validator1"},
+			{Address: second.Address(), PubKey: second, Power: 5, Name: "validator2"},
+		}, genesis.Validators)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		t.Parallel()
+
+		err := execBuildGenesis(context.Background(), &buildGenesisCfg{
+			sourceCfg:  sourceCfg{fileType: ".jsonl", sourcePath: sourceDir},
+			outputPath: filepath.Join(t.TempDir(), defaultGenesisFile),
+			chainID:    defaultGenesisChainID,
+			rejectsCfg: rejectsCfg{strict: true},
+		}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, errInvalidTxLine)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		testTable := []struct {
+			name     string
+			cfg      *buildGenesisCfg
+			expected error
+		}{
+			{"missing file type", &buildGenesisCfg{sourceCfg: sourceCfg{sourcePath: sourceDir}, chainID: "dev"}, errInvalidFileType},
+			{"missing source", &buildGenesisCfg{sourceCfg: sourceCfg{fileType: ".jsonl"}, chainID: "dev"}, errInvalidSourceDir},
+			{"block range", &buildGenesisCfg{sourceCfg: sourceCfg{fileType: ".jsonl", sourcePath: sourceDir}, chainID: "dev", fromBlock: 10, toBlock: 5}, errInvalidBlockRange},
+			{"chain ID", &buildGenesisCfg{sourceCfg: sourceCfg{fileType: ".jsonl", sourcePath: sourceDir}}, errInvalidChainID},
+			{"validator pubkey", &buildGenesisCfg{sourceCfg: sourceCfg{fileType: ".jsonl", sourcePath: sourceDir}, chainID: "dev", validators: caller.String()}, errInvalidValidator},
+			{"validator power", &buildGenesisCfg{sourceCfg: sourceCfg{fileType: ".jsonl", sourcePath: sourceDir}, chainID: "dev", validators: crypto.PubKeyToBech32(ed25519.GenPrivKey().PubKey()) + "=0"}, errInvalidValidator},
+		}
+
+		for _, testCase := range testTable {
+			assert.ErrorIs(t, execBuildGenesis(context.Background(), testCase.cfg, &bytes.Buffer{}), testCase.expected, testCase.name)
+		}
+	})
+}
+
+func TestSortArchiveFiles(t *testing.T) {
+	t.Parallel()
+
+	paths := []string{
+		"staging/backup_staging_txs_10001-11000.jsonl",
+		"test5/backup_0000011-0000020.jsonl",
+		"staging/backup_staging_txs_2001-3000.jsonl",
+		"test5/txexport-b.log",
+		"test5/backup_0000001-0000010.jsonl.gz",
+		"test5/txexport-a.log",
+	}
+
+	sortArchiveFiles(paths)
+
+	assert.Equal(t, []string{
+		"test5/txexport-a.log",
+		"test5/txexport-b.log",
+		"test5/backup_0000001-0000010.jsonl.gz",
+		"test5/backup_0000011-0000020.jsonl",
+		"staging/backup_staging_txs_2001-3000.jsonl",
+		"staging/backup_staging_txs_10001-11000.jsonl",
+	}, paths)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define the call log output formats
+const (
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+)
+
+var errInvalidFormat = errors.New("invalid output format")
+
+// callsCSVHeader is the header row of the CSV call log
+var callsCSVHeader = []string{
+	"height",
+	"timestamp",
+	"caller",
+	"pkg_path",
+	"func",
+	"args",
+	"send",
+	"max_deposit",
+	"gas_wanted",
+	"gas_fee",
+	"memo",
+}
+
+// Define calls config
+type callsCfg struct {
+	outputPath string
+	format     string
+	pkgPath    string
+
+	sourceCfg
+	rejectsCfg
+}
+
+// newCallsCmd creates the call log export command
+func newCallsCmd() *ffcli.Command {
+	var (
+		cfg = &callsCfg{}
+		fs  = flag.NewFlagSet("calls", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "calls",
+		ShortUsage: "calls [flags]",
+		ShortHelp:  "exports the vm.MsgCall call log",
+		LongHelp:   "Exports one normalized record per vm.MsgCall found in the transaction data, as JSONL or CSV",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execCalls(ctx, cfg, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the calls command flag set
+func (c *callsCfg) registerFlags(fs *flag.FlagSet) {
+	c.sourceCfg.registerFlags(fs)
+
+	fs.StringVar(
+		&c.outputPath,
+		"output-path",
+		"",
+		"the output file for the call log (stdout if empty)",
+	)
+
+	fs.StringVar(
+		&c.format,
+		"format",
+		formatJSONL,
+		fmt.Sprintf("the call log output format (%s, %s)", formatJSONL, formatCSV),
+	)
+
+	fs.StringVar(
+		&c.pkgPath,
+		"pkg-path",
+		"",
+		"only export calls to packages with this path prefix (ie gno.land/r/gnoswap)",
+	)
+
+	c.rejectsCfg.registerFlags(fs)
+}
+
+// execCalls runs the call log export
+func execCalls(ctx context.Context, cfg *callsCfg, stdout io.Writer) error {
+	// Check the source is valid
+	if err := cfg.sourceCfg.validate(); err != nil {
+		return err
+	}
+
+	// Check the output format is valid
+	if cfg.format != formatJSONL && cfg.format != formatCSV {
+		return fmt.Errorf("%w %q", errInvalidFormat, cfg.format)
+	}
+
+	sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	rejects, err := newRejectHandler(cfg.rejectsCfg)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
+
+	err = writeOutput(cfg.outputPath, stdout, func(out io.Writer) error {
+		writer := newCallWriter(cfg.format, out)
+
+		for _, sourceFile := range sourceFiles {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				for txMsg, err := range fileMessages(sourceFile, rejects, msgTypeCall) {
+					if err != nil {
+						return err
+					}
+
+					record, err := callRecordFromMsg(txMsg)
+					if err != nil {
+						return err
+					}
+
+					if !strings.HasPrefix(record.PkgPath, cfg.pkgPath) {
+						continue
+					}
+
+					if err := writer.write(record); err != nil {
+						return fmt.Errorf("unable to write call record, %w", err)
+					}
+				}
+			}
+		}
+
+		if err := writer.flush(); err != nil {
+			return fmt.Errorf("unable to flush call log, %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return rejects.close()
+}
+
+// CallRecord defines a single normalized vm.MsgCall record of the call log
+type CallRecord struct {
+	Height     uint64   `json:"height"`      // the block height of the call
+	Timestamp  int64    `json:"timestamp"`   // the block timestamp of the call (unix seconds)
+	Caller     string   `json:"caller"`      // the caller address
+	PkgPath    string   `json:"pkg_path"`    // the called package path
+	Func       string   `json:"func"`        // the called function
+	Args       []string `json:"args"`        // the call arguments
+	Send       string   `json:"send"`        // the coins sent along with the call
+	MaxDeposit string   `json:"max_deposit"` // the maximum storage deposit of the call
+	GasWanted  int64    `json:"gas_wanted"`  // the gas limit of the transaction
+	GasFee     string   `json:"gas_fee"`     // the gas fee of the transaction
+	Memo       string   `json:"memo"`        // the memo of the transaction
+}
+
+// callRecordFromMsg converts the transaction message into a normalized call record
+func callRecordFromMsg(txMsg TxMessage) (CallRecord, error) {
+	msgCall, ok := txMsg.Msg.(vm.MsgCall)
+	if !ok {
+		return CallRecord{}, errors.New("could not cast into MsgCall")
+	}
+
+	args := msgCall.Args
+	if args == nil {
+		args = []string{}
+	}
+
+	return CallRecord{
+		Height:     txMsg.Height,
+		Timestamp:  txMsg.Timestamp,
+		Caller:     msgCall.Caller.String(),
+		PkgPath:    msgCall.PkgPath,
+		Func:       msgCall.Func,
+		Args:       args,
+		Send:       msgCall.Send.String(),
+		MaxDeposit: msgCall.MaxDeposit.String(),
+		GasWanted:  txMsg.Tx.Fee.GasWanted,
+		GasFee:     txMsg.Tx.Fee.GasFee.String(),
+		Memo:       txMsg.Tx.Memo,
+	}, nil
+}
+
+// callWriter writes call records in a specific output format
+type callWriter interface {
+	write(CallRecord) error
+	flush() error
+}
+
+// newCallWriter creates a call writer for the given output format
+func newCallWriter(format string, out io.Writer) callWriter {
+	if format == formatCSV {
+		return &csvCallWriter{
+			writer: csv.NewWriter(out),
+		}
+	}
+
+	return &jsonlCallWriter{
+		encoder: json.NewEncoder(out),
+	}
+}
+
+// jsonlCallWriter writes one JSON call record per line
+type jsonlCallWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlCallWriter) write(record CallRecord) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlCallWriter) flush() error {
+	return nil
+}
+
+// csvCallWriter writes the call records as CSV rows, with a header row.
+// The call arguments are JSON encoded into a single column
+type csvCallWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvCallWriter) write(record CallRecord) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(callsCSVHeader); err != nil {
+			return err
+		}
+
+		w.headerWritten = true
+	}
+
+	args, err := json.Marshal(record.Args)
+	if err != nil {
+		return err
+	}
+
+	return w.writer.Write([]string{
+		strconv.FormatUint(record.Height, 10),
+		strconv.FormatInt(record.Timestamp, 10),
+		record.Caller,
+		record.PkgPath,
+		record.Func,
+		string(args),
+		record.Send,
+		record.MaxDeposit,
+		strconv.FormatInt(record.GasWanted, 10),
+		record.GasFee,
+		record.Memo,
+	})
+}
+
+func (w *csvCallWriter) flush() error {
+	// Always write the header, even for an empty call log
+	if !w.headerWritten {
+		if err := w.writer.Write(callsCSVHeader); err != nil {
+			return err
+		}
+
+		w.headerWritten = true
+	}
+
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
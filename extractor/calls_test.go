@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalls_Errors(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name        string
+		cfg         *callsCfg
+		expectedErr error
+	}{
+		{
+			"invalid filetype",
+			&callsCfg{sourceCfg: sourceCfg{sourcePath: "."}, format: formatJSONL},
+			errInvalidFileType,
+		},
+		{
+			"invalid source dir",
+			&callsCfg{sourceCfg: sourceCfg{fileType: ".log"}, format: formatJSONL},
+			errInvalidSourceDir,
+		},
+		{
+			"invalid format",
+			&callsCfg{sourceCfg: sourceCfg{fileType: ".log", sourcePath: "."}, format: "xml"},
+			errInvalidFormat,
+		},
+		{
+			"no source files",
+			&callsCfg{sourceCfg: sourceCfg{fileType: ".log", sourcePath: "."}, format: formatCSV},
+			errNoSourceFilesFound,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancelFn()
+
+			assert.ErrorIs(t, execCalls(ctx, testCase.cfg, &bytes.Buffer{}), testCase.expectedErr)
+		})
+	}
+}
+
+func TestCalls_Export(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sourceDir = t.TempDir()
+		caller    = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+
+		swap = vm.MsgCall{
+			Caller:     caller,
+			Send:       std.NewCoins(std.NewCoin("ugnot", 5)),
+			MaxDeposit: std.NewCoins(std.NewCoin("ugnot", 7)),
+			PkgPath:    "gno.land/r/gnoswap/router",
+			Func:       "ExactInSwapRoute",
+			Args:       []string{"a,b", `"quoted"`},
+		}
+		other = vm.MsgCall{
+			Caller:  caller,
+			PkgPath: "gno.land/r/demo/boards",
+			Func:    "CreateBoard",
+		}
+	)
+
+	file, err := os.Create(filepath.Join(sourceDir, "backup_0000001-0000100.jsonl"))
+	require.NoError(t, err)
+
+	require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+		Tx: std.Tx{
+			Msgs: []std.Msg{swap, other},
+			Fee:  std.NewFee(1000, std.NewCoin("ugnot", 10)),
+			Memo: "swap",
+		},
+		Metadata: &gnoland.GnoTxMetadata{Timestamp: 1786139574, BlockHeight: 42},
+	}, file))
+	require.NoError(t, file.Close())
+
+	expected := CallRecord{
+		Height:     42,
+		Timestamp:  1786139574,
+		Caller:     caller.String(),
+		PkgPath:    "gno.land/r/gnoswap/router",
+		Func:       "ExactInSwapRoute",
+		Args:       []string{"a,b", `"quoted"`},
+		Send:       "5ugnot",
+		MaxDeposit: "7ugnot",
+		GasWanted:  1000,
+		GasFee:     "10ugnot",
+		Memo:       "swap",
+	}
+
+	t.Run("jsonl", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		var out bytes.Buffer
+
+		require.NoError(t, execCalls(ctx, &callsCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			format:    formatJSONL,
+		}, &out))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+
+		var record CallRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+		assert.Equal(t, expected, record)
+
+		// Missing args are normalized to an empty list
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+		assert.Equal(t, []string{}, record.Args)
+	})
+
+	t.Run("csv with package filter", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		outputPath := filepath.Join(t.TempDir(), "calls.csv")
+
+		require.NoError(t, execCalls(ctx, &callsCfg{
+			sourceCfg:  sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputPath: outputPath,
+			format:     formatCSV,
+			pkgPath:    "gno.land/r/gnoswap",
+		}, &bytes.Buffer{}))
+
+		file, err := os.Open(outputPath)
+		require.NoError(t, err)
+		defer file.Close()
+
+		rows, err := csv.NewReader(file).ReadAll()
+		require.NoError(t, err)
+
+		require.Len(t, rows, 2)
+		assert.Equal(t, callsCSVHeader, rows[0])
+		assert.Equal(t, []string{
+			"42",
+			"1786139574",
+			caller.String(),
+			"gno.land/r/gnoswap/router",
+			"ExactInSwapRoute",
+			`["a,b","\"quoted\""]`,
+			"5ugnot",
+			"7ugnot",
+			"1000",
+			"10ugnot",
+			"swap",
+		}, rows[1])
+	})
+
+	t.Run("failed export keeps the output file", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		invalidDir := writeBackupFiles(t, map[string]string{
+			"backup_0000001-0000100.jsonl": "{\"tx\": \n",
+		})
+
+		outputDir := t.TempDir()
+		outputPath := filepath.Join(outputDir, "calls.jsonl")
+		require.NoError(t, os.WriteFile(outputPath, []byte("previous\n"), 0o644))
+
+		err := execCalls(ctx, &callsCfg{
+			sourceCfg:  sourceCfg{fileType: sourceFileType, sourcePath: invalidDir},
+			outputPath: outputPath,
+			format:     formatJSONL,
+			rejectsCfg: rejectsCfg{strict: true},
+		}, &bytes.Buffer{})
+		require.ErrorIs(t, err, errInvalidTxLine)
+
+		raw, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+		assert.Equal(t, "previous\n", string(raw))
+
+		// The temporary file is removed
+		entries, err := os.ReadDir(outputDir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// compression is the compression format of an archive file
+type compression string
+
+// Define the supported archive compression formats
+const (
+	compressionNone compression = "none"
+	compressionGzip compression = "gzip"
+	compressionZstd compression = "zstd"
+	compressionXz   compression = "xz"
+)
+
+// compressionExtensions maps the compressed file extensions to their format
+var compressionExtensions = map[string]compression{
+	".gz":   compressionGzip,
+	".zst":  compressionZstd,
+	".zstd": compressionZstd,
+	".xz":   compressionXz,
+}
+
+// compressionMagics are the magic bytes each compressed stream starts with
+var compressionMagics = []struct {
+	magic       []byte
+	compression compression
+}{
+	{[]byte{0x1f, 0x8b}, compressionGzip},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, compressionZstd},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, compressionXz},
+}
+
+// trimCompressionExt strips the compression extension from the file name, if any
+func trimCompressionExt(name string) string {
+	if _, ok := compressionExtensions[filepath.Ext(name)]; ok {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	return name
+}
+
+// detectCompression detects the compression of an archive from the first bytes
+// of the file, falling back to the file extension when they match no known format
+func detectCompression(name string, header []byte) compression {
+	for _, m := range compressionMagics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.compression
+		}
+	}
+
+	if c, ok := compressionExtensions[filepath.Ext(name)]; ok {
+		return c
+	}
+
+	return compressionNone
+}
+
+// archiveReader is the (decompressed) content of an archive file
+type archiveReader struct {
+	io.Reader
+
+	closeFns []func() error // called in reverse order on close
+}
+
+// Close closes the decompressor, and the archive file
+func (r *archiveReader) Close() error {
+	var errs []error
+
+	for i := len(r.closeFns) - 1; i >= 0; i-- {
+		errs = append(errs, r.closeFns[i]())
+	}
+
+	return errors.Join(errs...)
+}
+
+// openArchive opens the given archive file, transparently
+// decompressing gzip, zstd and xz archives
+func openArchive(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file, %w", err)
+	}
+
+	var (
+		buffered = bufio.NewReader(file)
+		reader   = &archiveReader{
+			Reader:   buffered,
+			closeFns: []func() error{file.Close},
+		}
+	)
+
+	// A short (or empty) file is simply not compressed
+	header, err := buffered.Peek(6)
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("unable to read file header, %w", err)
+	}
+
+	switch detectCompression(filePath, header) {
+	case compressionNone:
+	case compressionGzip:
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			_ = file.Close()
+
+			return nil, fmt.Errorf("unable to open gzip archive, %w", err)
+		}
+
+		reader.Reader = gz
+		reader.closeFns = append(reader.closeFns, gz.Close)
+	case compressionZstd:
+		// Files are decoded concurrently already (-workers)
+		zr, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			_ = file.Close()
+
+			return nil, fmt.Errorf("unable to open zstd archive, %w", err)
+		}
+
+		reader.Reader = zr
+		reader.closeFns = append(reader.closeFns, func() error {
+			zr.Close()
+
+			return nil
+		})
+	case compressionXz:
+		xr, err := xz.NewReader(buffered)
+		if err != nil {
+			_ = file.Close()
+
+			return nil, fmt.Errorf("unable to open xz archive, %w", err)
+		}
+
+		reader.Reader = xr
+	}
+
+	return reader, nil
+}
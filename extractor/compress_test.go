@@ -0,0 +1,169 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestMatchFileType(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name      string
+		fileName  string
+		fileType  string
+		isMatched bool
+	}{
+		{"plain suffix", "backup_0000001-0001000.jsonl", ".jsonl", true},
+		{"gzip archive", "backup_0000001-0001000.jsonl.gz", ".jsonl", true},
+		{"zstd archive", "backup_0000001-0001000.jsonl.zst", ".jsonl", true},
+		{"xz archive", "backup_0000001-0001000.jsonl.xz", ".jsonl", true},
+		{"explicit compressed suffix", "backup_0000001-0001000.jsonl.gz", ".jsonl.gz", true},
+		{"other suffix", "txexport-al.log", ".jsonl", false},
+		{"unknown compression", "backup_0000001-0001000.jsonl.bz2", ".jsonl", false},
+		{"multiple patterns", "txexport-al.log.xz", ".jsonl, .log", true},
+		{"glob pattern", "backup_staging_txs_1-1000.jsonl.gz", "backup_staging_*.jsonl", true},
+		{"glob mismatch", "backup_0000001-0001000.jsonl", "backup_staging_*.jsonl", false},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(
+				t,
+				testCase.isMatched,
+				matchFileType(testCase.fileName, parseFileTypes(testCase.fileType)),
+			)
+		})
+	}
+}
+
+// compressFile writes the compressed copy of the source file to the destination
+func compressFile(t *testing.T, source, destination string, c compression) {
+	t.Helper()
+
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+
+	file, err := os.Create(destination)
+	require.NoError(t, err)
+
+	var writer io.WriteCloser
+
+	switch c {
+	case compressionGzip:
+		writer = gzip.NewWriter(file)
+	case compressionZstd:
+		writer, err = zstd.NewWriter(file)
+		require.NoError(t, err)
+	case compressionXz:
+		writer, err = xz.NewWriter(file)
+		require.NoError(t, err)
+	default:
+		t.Fatalf("unexpected compression %s", c)
+	}
+
+	_, err = writer.Write(content)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+}
+
+func TestValidFlow_Compressed(t *testing.T) {
+	t.Parallel()
+
+	plainDir := t.TempDir()
+	generateVersionedSourceFiles(t, plainDir, 4, 10)
+
+	extract := func(sourceDir string) map[string]string {
+		outputDir := t.TempDir()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputDir: outputDir,
+		}))
+
+		tree := readTree(t, outputDir)
+
+		// The extractor state holds the source file names
+		delete(tree, extractorStateFile)
+
+		return tree
+	}
+
+	expected := extract(plainDir)
+
+	sourceFiles, err := findFilePaths(plainDir, sourceFileType)
+	require.NoError(t, err)
+	require.Len(t, sourceFiles, 4)
+
+	testTable := []struct {
+		name        string
+		compression compression
+		extension   string
+	}{
+		{"gzip", compressionGzip, ".gz"},
+		{"zstd", compressionZstd, ".zst"},
+		{"xz", compressionXz, ".xz"},
+		{"gzip without extension", compressionGzip, ""},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			sourceDir := t.TempDir()
+
+			for _, sourceFile := range sourceFiles {
+				destination := filepath.Join(sourceDir, filepath.Base(sourceFile)+testCase.extension)
+
+				compressFile(t, sourceFile, destination, testCase.compression)
+			}
+
+			assert.Equal(t, expected, extract(sourceDir))
+		})
+	}
+}
+
+func TestOpenArchive_Invalid(t *testing.T) {
+	t.Parallel()
+
+	// The extension says gzip, but the content does not
+	filePath := filepath.Join(t.TempDir(), "backup_0000001-0001000.jsonl.gz")
+	require.NoError(t, os.WriteFile(filePath, []byte("{}\n"), 0o644))
+
+	_, err := openArchive(filePath)
+	assert.ErrorContains(t, err, "unable to open gzip archive")
+
+	// Empty files are valid, empty archives
+	emptyPath := filepath.Join(t.TempDir(), "backup_0000001-0001000.jsonl")
+	require.NoError(t, os.WriteFile(emptyPath, nil, 0o644))
+
+	reader, err := openArchive(emptyPath)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, content)
+
+	require.NoError(t, reader.Close())
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/gnolang/gno/contribs/tx-archive/backup"
+	"github.com/gnolang/gno/contribs/tx-archive/backup/client"
+	"github.com/gnolang/gno/contribs/tx-archive/backup/client/rpc"
+	"github.com/gnolang/gno/contribs/tx-archive/backup/writer/standard"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define fetch constants
+const (
+	chainMetadataFile  = "metadata.json"
+	latestHeightField  = "latest_block_height"
+	defaultMaxInterval = 100_000
+
+	// The RPC nodes enforce a 10s WebSocket write deadline, which the
+	// responses of bigger batches exceed in tx-dense ranges
+	defaultFetchBatch = 100
+)
+
+var (
+	errInvalidRemote      = errors.New("invalid remote address")
+	errInvalidChainDir    = errors.New("invalid chain directory")
+	errInvalidMaxInterval = errors.New("invalid max interval")
+	errInvalidBatchSize   = errors.New("invalid batch size")
+	errInvalidMetadata    = errors.New("invalid chain metadata")
+)
+
+// Define fetch config
+type fetchCfg struct {
+	remote      string
+	chainDir    string
+	maxInterval uint64
+	toBlock     uint64
+	batchSize   uint
+	ws          bool
+	all         bool
+}
+
+// newFetchCmd creates the backup fetch command
+func newFetchCmd() *ffcli.Command {
+	var (
+		cfg = &fetchCfg{}
+		fs  = flag.NewFlagSet("fetch", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "fetch",
+		ShortUsage: "fetch [flags]",
+		ShortHelp:  "backs up the blocks produced since the last fetch",
+		LongHelp:   "Backs up the txs of the blocks following the chain metadata latest block height into a new backup file, and advances the latest block height",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execFetch(ctx, cfg)
+		},
+	}
+}
+
+// registerFlags registers the fetch command flag set
+func (c *fetchCfg) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(
+		&c.remote,
+		"remote",
+		"",
+		"the JSON-RPC URL of the chain node (ie https://rpc.gno.land, or wss://rpc.gno.land/websocket with -ws)",
+	)
+
+	fs.StringVar(
+		&c.chainDir,
+		"chain-dir",
+		".",
+		"the chain directory holding the backup files and the "+chainMetadataFile+" file",
+	)
+
+	fs.Uint64Var(
+		&c.maxInterval,
+		"max-interval",
+		defaultMaxInterval,
+		"the maximum number of blocks backed up into a single backup file",
+	)
+
+	fs.Uint64Var(
+		&c.toBlock,
+		"to-block",
+		0,
+		"the last block to back up (latest node block if 0)",
+	)
+
+	fs.UintVar(
+		&c.batchSize,
+		"batch",
+		defaultFetchBatch,
+		"the number of blocks fetched per RPC batch",
+	)
+
+	fs.BoolVar(
+		&c.ws,
+		"ws",
+		false,
+		"flag indicating if the node should be queried over a WebSocket connection",
+	)
+
+	fs.BoolVar(
+		&c.all,
+		"all",
+		false,
+		"flag indicating if backup files should be fetched until the last block is reached, instead of a single one",
+	)
+}
+
+// execFetch runs the backup fetch
+func execFetch(ctx context.Context, cfg *fetchCfg) error {
+	// Check the remote is valid
+	if cfg.remote == "" {
+		return errInvalidRemote
+	}
+
+	// Check the fetch limits are valid
+	if cfg.maxInterval == 0 {
+		return errInvalidMaxInterval
+	}
+
+	if cfg.batchSize == 0 {
+		return errInvalidBatchSize
+	}
+
+	if info, err := os.Stat(cfg.chainDir); err != nil || !info.IsDir() {
+		return errInvalidChainDir
+	}
+
+	var (
+		nodeClient client.Client
+		err        error
+	)
+
+	if cfg.ws {
+		nodeClient, err = rpc.NewWSClient(cfg.remote)
+	} else {
+		nodeClient, err = rpc.NewHTTPClient(cfg.remote)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to create RPC client, %w", err)
+	}
+
+	// The last block is read once, so that every backup
+	// file of the run ends up with a consistent range
+	toBlock := cfg.toBlock
+
+	if toBlock == 0 {
+		toBlock, err = nodeClient.GetLatestBlockNumber()
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		metadata, err := readChainMetadata(cfg.chainDir)
+		if err != nil {
+			return err
+		}
+
+		latest, err := metadata.latestHeight()
+		if err != nil {
+			return err
+		}
+
+		from, to, ok := fetchRange(latest, toBlock, cfg.maxInterval)
+		if !ok {
+			slog.Info("backup is up to date", "latest block height", latest)
+
+			return nil
+		}
+
+		if err := fetchBackup(ctx, nodeClient, cfg, from, to); err != nil {
+			return err
+		}
+
+		metadata.setLatestHeight(to)
+
+		if err := writeChainMetadata(cfg.chainDir, metadata); err != nil {
+			return err
+		}
+
+		if !cfg.all || to == toBlock {
+			return nil
+		}
+	}
+}
+
+// fetchRange returns the block range following the latest backed up height,
+// up to the given last block and capped to the max interval. The range is
+// empty when the last block was already backed up
+func fetchRange(latest, toBlock, maxInterval uint64) (uint64, uint64, bool) {
+	from := latest + 1
+
+	if toBlock < from {
+		return 0, 0, false
+	}
+
+	to := toBlock
+
+	if to-from >= maxInterval {
+		to = from + maxInterval - 1
+	}
+
+	return from, to, true
+}
+
+// backupFileName returns the name of the backup file of the block range
+func backupFileName(from, to uint64) string {
+	return fmt.Sprintf("backup_%07d-%07d.jsonl", from, to)
+}
+
+// fetchBackup backs up the txs of the block range into their backup file.
+// The file is only created once the whole range was backed up
+func fetchBackup(ctx context.Context, nodeClient client.Client, cfg *fetchCfg, from, to uint64) error {
+	var (
+		name = backupFileName(from, to)
+		path = filepath.Join(cfg.chainDir, name)
+	)
+
+	slog.Info("fetching backup", "from", from, "to", to, "file", name)
+
+	file, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+
+	var (
+		buf     = bufio.NewWriter(file)
+		service = backup.NewService(
+			nodeClient,
+			standard.NewWriter(buf),
+			backup.WithLogger(backupLogger{}),
+			backup.WithBatchSize(cfg.batchSize),
+		)
+	)
+
+	err = service.ExecuteBackup(ctx, backup.Config{
+		FromBlock: from,
+		ToBlock:   &to,
+	})
+	if err != nil {
+		file.abort()
+
+		return fmt.Errorf("unable to fetch blocks %d-%d, %w", from, to, err)
+	}
+
+	if err := buf.Flush(); err != nil {
+		file.abort()
+
+		return fmt.Errorf("unable to write %s, %w", name, err)
+	}
+
+	return file.commit()
+}
+
+// chainMetadata is the metadata file of a chain directory.
+// Its fields are kept raw, so the fields other than
+// the latest block height survive the updates
+type chainMetadata map[string]json.RawMessage
+
+// readChainMetadata reads the metadata file of the chain directory
+func readChainMetadata(chainDir string) (chainMetadata, error) {
+	raw, err := os.ReadFile(filepath.Join(chainDir, chainMetadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read chain metadata, %w", err)
+	}
+
+	var metadata chainMetadata
+
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return nil, fmt.Errorf("%w, %w", errInvalidMetadata, err)
+	}
+
+	if metadata == nil {
+		metadata = make(chainMetadata)
+	}
+
+	return metadata, nil
+}
+
+// latestHeight returns the latest backed up block height
+func (m chainMetadata) latestHeight() (uint64, error) {
+	raw, ok := m[latestHeightField]
+	if !ok {
+		return 0, fmt.Errorf("%w, missing %s", errInvalidMetadata, latestHeightField)
+	}
+
+	var height uint64
+
+	if err := json.Unmarshal(raw, &height); err != nil {
+		return 0, fmt.Errorf("%w, invalid %s, %w", errInvalidMetadata, latestHeightField, err)
+	}
+
+	return height, nil
+}
+
+// setLatestHeight sets the latest backed up block height
+func (m chainMetadata) setLatestHeight(height uint64) {
+	m[latestHeightField] = json.RawMessage(fmt.Sprintf("%d", height))
+}
+
+// writeChainMetadata writes the metadata file of the chain directory atomically
+func writeChainMetadata(chainDir string, metadata chainMetadata) error {
+	raw, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal chain metadata, %w", err)
+	}
+
+	return writeFileAtomic(filepath.Join(chainDir, chainMetadataFile), append(raw, '\n'))
+}
+
+// backupLogger forwards the tx-archive backup logs to slog
+type backupLogger struct{}
+
+func (backupLogger) Info(msg string, args ...interface{}) {
+	slog.Info(msg, args...)
+}
+
+func (backupLogger) Debug(msg string, args ...interface{}) {
+	slog.Debug(msg, args...)
+}
+
+func (backupLogger) Error(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+}
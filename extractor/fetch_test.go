@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+	ctypes "github.com/gnolang/gno/tm2/pkg/bft/rpc/core/types"
+	rpctypes "github.com/gnolang/gno/tm2/pkg/bft/rpc/lib/types"
+	"github.com/gnolang/gno/tm2/pkg/bft/state"
+	"github.com/gnolang/gno/tm2/pkg/bft/types"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockBlockTime is the timestamp of the mock node genesis
+const mockBlockTime = 1717236000
+
+// mockNode is a tm2 JSON-RPC node serving the status, block and
+// block_results methods over HTTP, with one tx every txInterval blocks
+type mockNode struct {
+	t *testing.T
+
+	latest     int64
+	txInterval int64
+	failHeight int64 // height whose block request fails, if any
+}
+
+// newMockNode starts the mock node, and returns its RPC URL
+func newMockNode(t *testing.T, node *mockNode) string {
+	t.Helper()
+
+	node.t = t
+
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// blockTxs returns the txs of the given block height
+func (n *mockNode) blockTxs(height int64) []std.Tx {
+	if height%n.txInterval != 0 {
+		return nil
+	}
+
+	return []std.Tx{
+		{
+			Msgs: []std.Msg{
+				vm.MsgCall{
+					Caller:  addressFromString(n.t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5"),
+					PkgPath: "gno.land/r/demo/counter",
+					Func:    "Incr",
+				},
+			},
+			Memo: fmt.Sprintf("block %d", height),
+		},
+	}
+}
+
+// ServeHTTP serves single and batch JSON-RPC requests
+func (n *mockNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(n.t, err)
+
+	var response any
+
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var requests rpctypes.RPCRequests
+		require.NoError(n.t, json.Unmarshal(body, &requests))
+
+		responses := make(rpctypes.RPCResponses, 0, len(requests))
+
+		for _, request := range requests {
+			responses = append(responses, n.handle(request))
+		}
+
+		response = responses
+	} else {
+		var request rpctypes.RPCRequest
+		require.NoError(n.t, json.Unmarshal(body, &request))
+
+		response = n.handle(request)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(n.t, json.NewEncoder(w).Encode(response))
+}
+
+// handle handles a single JSON-RPC request
+func (n *mockNode) handle(request rpctypes.RPCRequest) rpctypes.RPCResponse {
+	var params struct {
+		Height json.RawMessage `json:"height"`
+	}
+
+	if len(request.Params) > 0 {
+		require.NoError(n.t, json.Unmarshal(request.Params, &params))
+	}
+
+	var height int64
+
+	if len(params.Height) > 0 {
+		require.NoError(n.t, amino.UnmarshalJSON(params.Height, &height))
+	}
+
+	switch request.Method {
+	case "status":
+		return rpctypes.NewRPCSuccessResponse(request.ID, &ctypes.ResultStatus{
+			SyncInfo: ctypes.SyncInfo{LatestBlockHeight: n.latest},
+		})
+	case "block":
+		if height == n.failHeight {
+			return rpctypes.RPCInternalError(request.ID, fmt.Errorf("block %d is unavailable", height))
+		}
+
+		var encoded types.Txs
+
+		for _, tx := range n.blockTxs(height) {
+			encoded = append(encoded, amino.MustMarshal(tx))
+		}
+
+		header := types.Header{
+			Height: height,
+			Time:   time.Unix(mockBlockTime+height, 0).UTC(),
+		}
+
+		return rpctypes.NewRPCSuccessResponse(request.ID, &ctypes.ResultBlock{
+			BlockMeta: &types.BlockMeta{Header: header},
+			Block: &types.Block{
+				Header: header,
+				Data:   types.Data{Txs: encoded},
+			},
+		})
+	case "block_results":
+		return rpctypes.NewRPCSuccessResponse(request.ID, &ctypes.ResultBlockResults{
+			Height: height,
+			Results: &state.ABCIResponses{
+				DeliverTxs: make([]abci.ResponseDeliverTx, len(n.blockTxs(height))),
+			},
+		})
+	default:
+		return rpctypes.RPCMethodNotFoundError(request.ID)
+	}
+}
+
+// newChainDir creates a chain directory with the given metadata file content
+func newChainDir(t *testing.T, metadata string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, chainMetadataFile), []byte(metadata), 0o644))
+
+	return dir
+}
+
+// readBackupMemos reads the memo and timestamp of every tx of the backup file
+func readBackupMemos(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var (
+		memos   []string
+		scanner = bufio.NewScanner(file)
+	)
+
+	for scanner.Scan() {
+		var tx gnoland.TxWithMetadata
+		require.NoError(t, amino.UnmarshalJSON(scanner.Bytes(), &tx))
+
+		memos = append(memos, fmt.Sprintf("%s at %d", tx.Tx.Memo, tx.Metadata.Timestamp-mockBlockTime))
+	}
+
+	require.NoError(t, scanner.Err())
+
+	return memos
+}
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+
+	fetch := func(t *testing.T, cfg *fetchCfg) error {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		if cfg.batchSize == 0 {
+			cfg.batchSize = 4
+		}
+
+		return execFetch(ctx, cfg)
+	}
+
+	t.Run("capped range", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			remote   = newMockNode(t, &mockNode{latest: 25, txInterval: 3})
+			chainDir = newChainDir(t, "{\n  \"latest_block_height\": 4,\n  \"chain_id\": \"test\"\n}\n")
+		)
+
+		require.NoError(t, fetch(t, &fetchCfg{
+			remote:      remote,
+			chainDir:    chainDir,
+			maxInterval: 10,
+		}))
+
+		tree := readTree(t, chainDir)
+
+		assert.Equal(t, "{\n  \"chain_id\": \"test\",\n  \"latest_block_height\": 14\n}\n", tree[chainMetadataFile])
+		assert.Equal(
+			t,
+			[]string{"block 6 at 6", "block 9 at 9", "block 12 at 12"},
+			readBackupMemos(t, filepath.Join(chainDir, "backup_0000005-0000014.jsonl")),
+		)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			remote   = newMockNode(t, &mockNode{latest: 25, txInterval: 5})
+			chainDir = newChainDir(t, "{ \"latest_block_height\": 0 }")
+		)
+
+		require.NoError(t, fetch(t, &fetchCfg{
+			remote:      remote,
+			chainDir:    chainDir,
+			maxInterval: 10,
+			all:         true,
+		}))
+
+		files, err := filepath.Glob(filepath.Join(chainDir, "backup_*.jsonl"))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			filepath.Join(chainDir, "backup_0000001-0000010.jsonl"),
+			filepath.Join(chainDir, "backup_0000011-0000020.jsonl"),
+			filepath.Join(chainDir, "backup_0000021-0000025.jsonl"),
+		}, files)
+
+		assert.Equal(t, []string{"block 25 at 25"}, readBackupMemos(t, files[2]))
+
+		metadata, err := readChainMetadata(chainDir)
+		require.NoError(t, err)
+
+		latest, err := metadata.latestHeight()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(25), latest)
+
+		// Nothing is left to fetch
+		require.NoError(t, fetch(t, &fetchCfg{
+			remote:      remote,
+			chainDir:    chainDir,
+			maxInterval: 10,
+			all:         true,
+		}))
+
+		again, err := filepath.Glob(filepath.Join(chainDir, "*"))
+		require.NoError(t, err)
+		assert.Len(t, again, 4)
+	})
+
+	t.Run("to block", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			remote   = newMockNode(t, &mockNode{latest: 25, txInterval: 5})
+			chainDir = newChainDir(t, "{ \"latest_block_height\": 0 }")
+		)
+
+		require.NoError(t, fetch(t, &fetchCfg{
+			remote:      remote,
+			chainDir:    chainDir,
+			maxInterval: 100,
+			toBlock:     7,
+		}))
+
+		assert.Equal(
+			t,
+			[]string{"block 5 at 5"},
+			readBackupMemos(t, filepath.Join(chainDir, "backup_0000001-0000007.jsonl")),
+		)
+	})
+
+	t.Run("failed fetch", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			remote   = newMockNode(t, &mockNode{latest: 25, txInterval: 5, failHeight: 12})
+			metadata = "{ \"latest_block_height\": 0 }"
+			chainDir = newChainDir(t, metadata)
+		)
+
+		require.Error(t, fetch(t, &fetchCfg{
+			remote:      remote,
+			chainDir:    chainDir,
+			maxInterval: 10,
+			all:         true,
+		}))
+
+		// The complete ranges are kept, and no partial file is left behind
+		entries, err := os.ReadDir(chainDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, "backup_0000001-0000010.jsonl", entries[0].Name())
+		assert.Equal(t, "{\n  \"latest_block_height\": 10\n}\n", readTree(t, chainDir)[chainMetadataFile])
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		chainDir := newChainDir(t, "{ \"latest_block_height\": 0 }")
+
+		assert.ErrorIs(t, fetch(t, &fetchCfg{chainDir: chainDir, maxInterval: 10}), errInvalidRemote)
+		assert.ErrorIs(t, fetch(t, &fetchCfg{remote: "http://127.0.0.1:1", chainDir: chainDir}), errInvalidMaxInterval)
+		assert.ErrorIs(t, fetch(t, &fetchCfg{
+			remote:      "http://127.0.0.1:1",
+			chainDir:    filepath.Join(chainDir, "missing"),
+			maxInterval: 10,
+		}), errInvalidChainDir)
+
+		invalidDir := newChainDir(t, "{}")
+
+		assert.ErrorIs(t, fetch(t, &fetchCfg{
+			remote:      newMockNode(t, &mockNode{latest: 25, txInterval: 5}),
+			chainDir:    invalidDir,
+			maxInterval: 10,
+		}), errInvalidMetadata)
+	})
+}
+
+func TestFetchRange(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name        string
+		latest      uint64
+		toBlock     uint64
+		maxInterval uint64
+
+		from, to uint64
+		ok       bool
+	}{
+		{"below the cap", 100, 150, 100, 101, 150, true},
+		{"at the cap", 100, 200, 100, 101, 200, true},
+		{"above the cap", 100, 201, 100, 101, 200, true},
+		{"single block", 100, 101, 100, 101, 101, true},
+		{"up to date", 100, 100, 100, 0, 0, false},
+		{"node behind", 100, 50, 100, 0, 0, false},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			from, to, ok := fetchRange(testCase.latest, testCase.toBlock, testCase.maxInterval)
+
+			assert.Equal(t, testCase.ok, ok)
+			assert.Equal(t, testCase.from, from)
+			assert.Equal(t, testCase.to, to)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
+	"github.com/gnolang/gno/tm2/pkg/std"
+)
+
+// txFingerprint returns the semantic fingerprint of a tx, the hex encoded SHA-256
+// of what its messages do: the message type, package path, file hashes, func and
+// args. The signers (creator, caller, sender), the signatures and the fee are left
+// out, as the Portal Loop replays its txs with keys regenerated on every restart
+func txFingerprint(tx std.Tx) string {
+	h := sha256.New()
+
+	for _, msg := range tx.Msgs {
+		writeMsgFingerprint(h, msg)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeMsgFingerprint writes the signer independent fields of the message
+func writeMsgFingerprint(h hash.Hash, msg std.Msg) {
+	fmt.Fprintf(h, "msg %s/%s\n", msg.Route(), msg.Type())
+
+	switch msg := msg.(type) {
+	case vm.MsgAddPackage:
+		if msg.Package != nil {
+			fmt.Fprintf(h, "path %q\n", msg.Package.Path)
+			writeFilesFingerprint(h, msg.Package.Files)
+		}
+	case vm.MsgCall:
+		fmt.Fprintf(h, "path %q\nfunc %q\nargs %q\n", msg.PkgPath, msg.Func, msg.Args)
+	case vm.MsgRun:
+		// The run package path holds the caller address
+		if msg.Package != nil {
+			writeFilesFingerprint(h, msg.Package.Files)
+		}
+	case bank.MsgSend:
+		fmt.Fprintf(h, "to %s\namount %s\n", msg.ToAddress, msg.Amount)
+	default:
+		// Unknown messages are fingerprinted as a whole
+		raw, err := amino.MarshalJSON(msg)
+		if err != nil {
+			raw = []byte(err.Error())
+		}
+
+		fmt.Fprintf(h, "json %s\n", raw)
+	}
+}
+
+// writeFilesFingerprint writes the name and body hash of every package file,
+// sorted by name
+func writeFilesFingerprint(h hash.Hash, files []*std.MemFile) {
+	sorted := slices.DeleteFunc(slices.Clone(files), func(file *std.MemFile) bool {
+		return file == nil
+	})
+
+	slices.SortFunc(sorted, func(a, b *std.MemFile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, file := range sorted {
+		fmt.Fprintf(h, "file %q %x\n", file.Name, sha256.Sum256([]byte(file.Body)))
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxFingerprint(t *testing.T) {
+	t.Parallel()
+
+	var (
+		first  = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+		second = addressFromString(t, "g1qpymzwx4l4cy6cerdyajp9ksvjsf20rk5y9rtt")
+	)
+
+	addPkg := func(creator string, files ...*std.MemFile) std.Tx {
+		return std.Tx{
+			Msgs: []std.Msg{
+				vm.MsgAddPackage{
+					Creator: addressFromString(t, creator),
+					Package: &std.MemPackage{
+						Name:  "foo",
+						Path:  "gno.land/p/demo/foo",
+						Files: files,
+					},
+				},
+			},
+		}
+	}
+
+	call := func(caller string, args ...string) std.Tx {
+		return std.Tx{
+			Msgs: []std.Msg{
+				vm.MsgCall{
+					Caller:  addressFromString(t, caller),
+					PkgPath: "gno.land/r/demo/foo",
+					Func:    "Call",
+					Args:    args,
+				},
+			},
+		}
+	}
+
+	var (
+		fooFile = &std.MemFile{Name: "foo.gno", Body: "package foo"}
+		barFile = &std.MemFile{Name: "bar.gno", Body: "package foo\n\nvar bar int"}
+	)
+
+	signed := call(first.String(), "a")
+	signed.Signatures = []std.Signature{{Signature: []byte("signature")}}
+	signed.Fee = std.NewFee(1000, std.NewCoin("ugnot", 1))
+
+	testTable := []struct {
+		name  string
+		a     std.Tx
+		b     std.Tx
+		equal bool
+	}{
+		{
+			"add package creator",
+			addPkg(first.String(), fooFile, barFile),
+			addPkg(second.String(), barFile, fooFile),
+			true,
+		},
+		{
+			"add package files",
+			addPkg(first.String(), fooFile),
+			addPkg(first.String(), fooFile, barFile),
+			false,
+		},
+		{
+			"call signatures and fee",
+			call(second.String(), "a"),
+			signed,
+			true,
+		},
+		{
+			"call args",
+			call(first.String(), "a", "b"),
+			call(first.String(), "ab"),
+			false,
+		},
+		{
+			"run caller",
+			std.Tx{Msgs: []std.Msg{vm.MsgRun{Caller: first, Package: &std.MemPackage{Path: "gno.land/e/" + first.String() + "/run", Files: []*std.MemFile{fooFile}}}}},
+			std.Tx{Msgs: []std.Msg{vm.MsgRun{Caller: second, Package: &std.MemPackage{Path: "gno.land/e/" + second.String() + "/run", Files: []*std.MemFile{fooFile}}}}},
+			true,
+		},
+		{
+			"send recipient",
+			std.Tx{Msgs: []std.Msg{bank.MsgSend{FromAddress: first, ToAddress: second, Amount: std.NewCoins(std.NewCoin("ugnot", 1))}}},
+			std.Tx{Msgs: []std.Msg{bank.MsgSend{FromAddress: second, ToAddress: first, Amount: std.NewCoins(std.NewCoin("ugnot", 1))}}},
+			false,
+		},
+		{
+			"message count",
+			call(first.String(), "a"),
+			std.Tx{Msgs: append(call(first.String(), "a").Msgs, call(first.String(), "a").Msgs...)},
+			false,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.equal, txFingerprint(testCase.a) == txFingerprint(testCase.b))
+		})
+	}
+}
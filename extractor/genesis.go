@@ -0,0 +1,645 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/crypto"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define genesis export constants
+const (
+	stagingTxsPrefix    = "backup_staging_txs"
+	stagingBalancesFile = "backup_staging_balances.jsonl"
+	defaultChunkSize    = 1000
+	genesisEndpoint     = "/genesis"
+)
+
+// stagingChunkRegex matches the staging tx chunk file names (backup_staging_txs_<start>-<end>.jsonl)
+var stagingChunkRegex = regexp.MustCompile(`^` + stagingTxsPrefix + `_(\d+)-(\d+)\.jsonl$`)
+
+var (
+	errMissingGenesis   = errors.New("either a genesis file or a remote is required")
+	errInvalidChunkSize = errors.New("invalid chunk size")
+	errInvalidGenesis   = errors.New("invalid genesis")
+)
+
+// Define genesis export config
+type genesisExportCfg struct {
+	genesisPath string
+	remote      string
+	chainDir    string
+	chunkSize   int
+	repair      bool
+}
+
+// newGenesisExportCmd creates the genesis export command
+func newGenesisExportCmd() *ffcli.Command {
+	var (
+		cfg = &genesisExportCfg{}
+		fs  = flag.NewFlagSet("genesis-export", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "genesis-export",
+		ShortUsage: "genesis-export [flags]",
+		ShortHelp:  "exports the genesis txs and balances of a chain replaying its history at genesis",
+		LongHelp: "Exports the txs and balances of a chain genesis, as the Portal Loop (staging) replays its whole history at genesis. " +
+			"The balance of the genesis deployer, regenerated on every restart, is removed, and the genesis txs missing " +
+			"from the " + stagingTxsPrefix + "_<start>-<end>.jsonl chunks are appended to them. The txs are compared by " +
+			"their fingerprint, which leaves out the signers and signatures",
+		FlagSet: fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execGenesisExport(ctx, cfg, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the genesis export command flag set
+func (c *genesisExportCfg) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(
+		&c.genesisPath,
+		"genesis",
+		"",
+		"the path to the genesis file, either the genesis itself or the RPC /genesis response. Takes precedence over the remote",
+	)
+
+	fs.StringVar(
+		&c.remote,
+		"remote",
+		"",
+		"the JSON-RPC URL of the chain, the genesis is fetched from its "+genesisEndpoint+" endpoint",
+	)
+
+	fs.StringVar(
+		&c.chainDir,
+		"chain-dir",
+		".",
+		"the chain directory holding the tx chunks and the balances",
+	)
+
+	fs.IntVar(
+		&c.chunkSize,
+		"chunk-size",
+		defaultChunkSize,
+		"the number of txs of a single chunk file",
+	)
+
+	fs.BoolVar(
+		&c.repair,
+		"repair",
+		false,
+		"flag indicating if the chunks overlapping another chunk holding all their txs should be removed, instead of exporting the genesis",
+	)
+}
+
+// genesisExport is the content of a genesis exported to the staging archive
+type genesisExport struct {
+	txs          [][]byte // the amino JSON encoded genesis txs, in genesis order
+	fingerprints []string // the fingerprints of the genesis txs
+	balances     []gnoland.Balance
+	deployer     crypto.Address // zero if the genesis has no package deployment
+}
+
+// execGenesisExport runs the genesis export
+func execGenesisExport(ctx context.Context, cfg *genesisExportCfg, stdout io.Writer) error {
+	if info, err := os.Stat(cfg.chainDir); err != nil || !info.IsDir() {
+		return errInvalidChainDir
+	}
+
+	if cfg.repair {
+		chunks, err := listStagingChunks(cfg.chainDir)
+		if err != nil {
+			return err
+		}
+
+		return repairStagingChunks(chunks, stdout)
+	}
+
+	if cfg.genesisPath == "" && cfg.remote == "" {
+		return errMissingGenesis
+	}
+
+	if cfg.chunkSize <= 0 {
+		return errInvalidChunkSize
+	}
+
+	raw, err := readGenesis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	export, err := decodeGenesisExport(raw)
+	if err != nil {
+		return err
+	}
+
+	if err := writeStagingBalances(cfg.chainDir, export.balances); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "balances: %d\n", len(export.balances))
+
+	if !export.deployer.IsZero() {
+		fmt.Fprintf(stdout, "removed deployer balance: %s\n", export.deployer)
+	}
+
+	chunks, err := listStagingChunks(cfg.chainDir)
+	if err != nil {
+		return err
+	}
+
+	backedUp := make(map[string]int)
+
+	for _, chunk := range chunks {
+		if err := chunk.fingerprints(backedUp); err != nil {
+			return err
+		}
+	}
+
+	existing := 0
+	for _, count := range backedUp {
+		existing += count
+	}
+
+	txs, dropped := newGenesisTxs(export, backedUp)
+
+	fmt.Fprintf(stdout, "genesis txs: %d, backed up: %d, new: %d\n", len(export.txs), existing, len(txs))
+
+	if dropped > 0 {
+		fmt.Fprintf(stdout, "backed up txs missing from the genesis: %d\n", dropped)
+	}
+
+	if len(txs) == 0 {
+		fmt.Fprintln(stdout, "no new txs")
+
+		return nil
+	}
+
+	written, err := appendStagingChunks(cfg.chainDir, chunks, txs, cfg.chunkSize)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range written {
+		fmt.Fprintf(stdout, "wrote %s\n", name)
+	}
+
+	return nil
+}
+
+// readGenesis reads the raw genesis from the genesis file, or from the remote
+func readGenesis(ctx context.Context, cfg *genesisExportCfg) ([]byte, error) {
+	if cfg.genesisPath != "" {
+		raw, err := os.ReadFile(cfg.genesisPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read genesis, %w", err)
+		}
+
+		return raw, nil
+	}
+
+	url := strings.TrimRight(strings.Trim(cfg.remote, `"`), "/") + genesisEndpoint
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidRemote, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch genesis, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch genesis, %s", resp.Status)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch genesis, %w", err)
+	}
+
+	return raw, nil
+}
+
+// decodeGenesisExport decodes the txs and balances of a genesis, and removes the
+// deployer balance. Only the app_state txs and balances are decoded, so that the
+// genesis fields unknown to the gno types the extractor is built with are ignored
+func decodeGenesisExport(raw []byte) (genesisExport, error) {
+	var genesis struct {
+		AppState *struct {
+			Balances []string          `json:"balances"` // <address>=<coins>
+			Txs      []json.RawMessage `json:"txs"`
+		} `json:"app_state"`
+		Result *struct {
+			Genesis json.RawMessage `json:"genesis"`
+		} `json:"result"`
+	}
+
+	if err := json.Unmarshal(raw, &genesis); err != nil {
+		return genesisExport{}, fmt.Errorf("%w: %s", errInvalidGenesis, err)
+	}
+
+	// The RPC /genesis response wraps the genesis
+	if genesis.Result != nil && genesis.AppState == nil {
+		return decodeGenesisExport(genesis.Result.Genesis)
+	}
+
+	if genesis.AppState == nil {
+		return genesisExport{}, fmt.Errorf("%w: missing app_state", errInvalidGenesis)
+	}
+
+	export := genesisExport{
+		txs: make([][]byte, 0, len(genesis.AppState.Txs)),
+	}
+
+	for i, rawTx := range genesis.AppState.Txs {
+		var tx gnoland.TxWithMetadata
+
+		if err := amino.UnmarshalJSON(rawTx, &tx); err != nil {
+			return genesisExport{}, fmt.Errorf("unable to decode genesis tx %d, %w", i, err)
+		}
+
+		// The deployer signs the first package deployments (the examples)
+		if export.deployer.IsZero() {
+			for _, msg := range tx.Tx.Msgs {
+				if addPkg, ok := msg.(vm.MsgAddPackage); ok {
+					export.deployer = addPkg.Creator
+
+					break
+				}
+			}
+		}
+
+		line, err := amino.MarshalJSON(tx)
+		if err != nil {
+			return genesisExport{}, fmt.Errorf("unable to encode genesis tx %d, %w", i, err)
+		}
+
+		export.txs = append(export.txs, line)
+		export.fingerprints = append(export.fingerprints, txFingerprint(tx.Tx))
+	}
+
+	for _, entry := range genesis.AppState.Balances {
+		var balance gnoland.Balance
+
+		if err := balance.Parse(entry); err != nil {
+			return genesisExport{}, fmt.Errorf("unable to decode genesis balance, %w", err)
+		}
+
+		if !export.deployer.IsZero() && balance.Address == export.deployer {
+			continue
+		}
+
+		export.balances = append(export.balances, balance)
+	}
+
+	return export, nil
+}
+
+// newGenesisTxs returns the genesis txs missing from the backed up tx fingerprints,
+// in genesis order, along with the number of backed up txs missing from the genesis.
+// A tx found n times in the backup matches its n first occurrences in the genesis
+func newGenesisTxs(export genesisExport, backedUp map[string]int) ([][]byte, int) {
+	var (
+		remaining = maps.Clone(backedUp)
+		txs       [][]byte
+	)
+
+	for i, fingerprint := range export.fingerprints {
+		if remaining[fingerprint] > 0 {
+			remaining[fingerprint]--
+
+			continue
+		}
+
+		txs = append(txs, export.txs[i])
+	}
+
+	dropped := 0
+	for _, count := range remaining {
+		dropped += count
+	}
+
+	return txs, dropped
+}
+
+// writeStagingBalances overwrites the staging balances file, one <address>=<coins> line per balance
+func writeStagingBalances(chainDir string, balances []gnoland.Balance) error {
+	var b strings.Builder
+
+	for _, balance := range balances {
+		b.WriteString(balance.String())
+		b.WriteString("\n")
+	}
+
+	return writeFileAtomic(filepath.Join(chainDir, stagingBalancesFile), []byte(b.String()))
+}
+
+// stagingChunk is a staging tx chunk file, holding the genesis txs start to end
+type stagingChunk struct {
+	path  string
+	start int
+	end   int
+	lines int
+}
+
+// listStagingChunks lists the staging tx chunk files of the chain directory,
+// sorted by tx range (as sort -V sorts their names)
+func listStagingChunks(chainDir string) ([]stagingChunk, error) {
+	entries, err := os.ReadDir(chainDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read chain directory, %w", err)
+	}
+
+	var chunks []stagingChunk
+
+	for _, entry := range entries {
+		matches := stagingChunkRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		start, _ := strconv.Atoi(matches[1])
+		end, _ := strconv.Atoi(matches[2])
+
+		chunk := stagingChunk{
+			path:  filepath.Join(chainDir, entry.Name()),
+			start: start,
+			end:   end,
+		}
+
+		if chunk.lines, err = countFileLines(chunk.path); err != nil {
+			return nil, err
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	slices.SortFunc(chunks, func(a, b stagingChunk) int {
+		if a.start != b.start {
+			return a.start - b.start
+		}
+
+		return a.end - b.end
+	})
+
+	return chunks, nil
+}
+
+// fingerprints adds the fingerprint count of every tx of the chunk to the given counts
+func (c stagingChunk) fingerprints(counts map[string]int) error {
+	var (
+		decoder = newAutoDecoder(c.path)
+		lineNum int
+		lineErr error
+	)
+
+	err := scanFileLines(c.path, func(line []byte) {
+		lineNum++
+
+		if lineErr != nil {
+			return
+		}
+
+		entry, err := decoder.decode(line)
+		if err != nil {
+			lineErr = fmt.Errorf("unable to decode %s tx %d, %w", filepath.Base(c.path), lineNum, err)
+
+			return
+		}
+
+		counts[txFingerprint(entry.tx)]++
+	})
+	if err != nil {
+		return err
+	}
+
+	return lineErr
+}
+
+// repairStagingChunks removes the chunks whose tx range is within the range of
+// another chunk holding all their txs, ie a chunk left behind by an export
+// renaming the last chunk. The other overlaps are reported, and left as they are
+func repairStagingChunks(chunks []stagingChunk, stdout io.Writer) error {
+	removed := make(map[string]bool)
+
+	for i, first := range chunks {
+		for _, second := range chunks[i+1:] {
+			if second.start > first.end {
+				break
+			}
+
+			if removed[first.path] || removed[second.path] {
+				continue
+			}
+
+			// Chunks are sorted by start, so only the first one can contain the second
+			// one, unless both start at the same tx
+			inner, outer := second, first
+
+			switch {
+			case first.start == second.start && first.end <= second.end:
+				inner, outer = first, second
+			case second.end > first.end:
+				fmt.Fprintf(stdout, "overlap left: %s and %s\n", first.name(), second.name())
+
+				continue
+			}
+
+			missing, err := missingChunkTxs(inner, outer)
+			if err != nil {
+				return err
+			}
+
+			if missing > 0 {
+				fmt.Fprintf(stdout, "overlap left: %s and %s, %d txs of %s are not in %s\n",
+					first.name(), second.name(), missing, inner.name(), outer.name())
+
+				continue
+			}
+
+			if err := os.Remove(inner.path); err != nil {
+				return fmt.Errorf("unable to remove chunk %s, %w", inner.name(), err)
+			}
+
+			removed[inner.path] = true
+
+			fmt.Fprintf(stdout, "removed %s, its txs are in %s\n", inner.name(), outer.name())
+		}
+	}
+
+	if len(removed) == 0 {
+		fmt.Fprintln(stdout, "no overlapping chunks removed")
+	}
+
+	return nil
+}
+
+// missingChunkTxs counts the txs of the inner chunk missing from the outer chunk
+func missingChunkTxs(inner, outer stagingChunk) (int, error) {
+	var (
+		innerTxs = make(map[string]int)
+		outerTxs = make(map[string]int)
+	)
+
+	if err := inner.fingerprints(innerTxs); err != nil {
+		return 0, err
+	}
+
+	if err := outer.fingerprints(outerTxs); err != nil {
+		return 0, err
+	}
+
+	missing := 0
+
+	for fingerprint, count := range innerTxs {
+		missing += max(count-outerTxs[fingerprint], 0)
+	}
+
+	return missing, nil
+}
+
+// name returns the chunk file name
+func (c stagingChunk) name() string {
+	return filepath.Base(c.path)
+}
+
+// countFileLines counts the lines of a file, as wc -l does
+func countFileLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open %s, %w", path, err)
+	}
+	defer file.Close()
+
+	var (
+		reader = bufio.NewReader(file)
+		lines  int
+	)
+
+	for {
+		_, err := reader.ReadSlice('\n')
+
+		switch {
+		case err == nil:
+			lines++
+		case errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF):
+			return lines, nil
+		default:
+			return 0, fmt.Errorf("unable to read %s, %w", path, err)
+		}
+	}
+}
+
+// appendStagingChunks appends the new txs to the staging chunks. The last chunk
+// is filled up to the chunk size and renamed to its new end, and the remaining
+// txs are written to new chunks of the chunk size. Returns the written chunk names
+func appendStagingChunks(chainDir string, chunks []stagingChunk, txs [][]byte, chunkSize int) ([]string, error) {
+	var (
+		written []string
+		next    = 1
+	)
+
+	if len(chunks) > 0 {
+		last := chunks[len(chunks)-1]
+		next = last.end + 1
+
+		if room := min(chunkSize-last.lines, len(txs)); room > 0 {
+			name, err := writeStagingChunk(chainDir, last.path, last.start, last.end+room, txs[:room])
+			if err != nil {
+				return written, err
+			}
+
+			written = append(written, name)
+			txs = txs[room:]
+			next += room
+		}
+	}
+
+	for len(txs) > 0 {
+		size := min(chunkSize, len(txs))
+
+		name, err := writeStagingChunk(chainDir, "", next, next+size-1, txs[:size])
+		if err != nil {
+			return written, err
+		}
+
+		written = append(written, name)
+		txs = txs[size:]
+		next += size
+	}
+
+	return written, nil
+}
+
+// writeStagingChunk writes the txs to the chunk holding the genesis txs start to end,
+// after the content of the previous chunk file if any, which is removed once the
+// chunk is in place
+func writeStagingChunk(chainDir, previous string, start, end int, txs [][]byte) (string, error) {
+	var (
+		name = fmt.Sprintf("%s_%d-%d.jsonl", stagingTxsPrefix, start, end)
+		path = filepath.Join(chainDir, name)
+	)
+
+	out, err := createAtomic(path)
+	if err != nil {
+		return "", err
+	}
+
+	buf := bufio.NewWriter(out)
+
+	if previous != "" {
+		if err := appendFile(buf, previous); err != nil {
+			out.abort()
+
+			return "", err
+		}
+	}
+
+	for _, tx := range txs {
+		buf.Write(tx)
+		buf.WriteString("\n")
+	}
+
+	if err := buf.Flush(); err != nil {
+		out.abort()
+
+		return "", fmt.Errorf("unable to write %s, %w", name, err)
+	}
+
+	if err := out.commit(); err != nil {
+		return "", err
+	}
+
+	if previous != "" && previous != path {
+		if err := os.Remove(previous); err != nil {
+			return "", fmt.Errorf("unable to remove chunk %s, %w", filepath.Base(previous), err)
+		}
+	}
+
+	return name, nil
+}
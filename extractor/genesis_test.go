@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDeployer = "g1us8428u2a5satrlxzagqqa5m6vmuze025anjlj"
+
+// genesisTxs generates the genesis txs of a Portal Loop restart: the deployer
+// adds a package, and the given number of calls follow it
+func genesisTxs(t *testing.T, calls int) []gnoland.TxWithMetadata {
+	t.Helper()
+
+	var (
+		deployer = addressFromString(t, testDeployer)
+		caller   = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+	)
+
+	txs := []gnoland.TxWithMetadata{
+		{
+			Tx: std.Tx{
+				Msgs: []std.Msg{
+					vm.MsgAddPackage{
+						Creator: deployer,
+						Package: &std.MemPackage{
+							Name:  "foo",
+							Path:  "gno.land/p/demo/foo",
+							Files: []*std.MemFile{{Name: "foo.gno", Body: "package foo"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for i := range calls {
+		txs = append(txs, gnoland.TxWithMetadata{
+			Tx: std.Tx{
+				Msgs: []std.Msg{
+					vm.MsgCall{
+						Caller:  caller,
+						PkgPath: "gno.land/r/demo/foo",
+						Func:    "Call" + strconv.Itoa(i),
+					},
+				},
+			},
+			Metadata: &gnoland.GnoTxMetadata{
+				Timestamp: int64(1760319258 + i),
+			},
+		})
+	}
+
+	return txs
+}
+
+// restartTxs returns the txs as replayed by another Portal Loop restart: deployed
+// by another deployer, and signed with other keys
+func restartTxs(t *testing.T, txs []gnoland.TxWithMetadata) []gnoland.TxWithMetadata {
+	t.Helper()
+
+	restarted := make([]gnoland.TxWithMetadata, 0, len(txs))
+
+	for _, tx := range txs {
+		msgs := make([]std.Msg, 0, len(tx.Tx.Msgs))
+
+		for _, msg := range tx.Tx.Msgs {
+			if addPkg, ok := msg.(vm.MsgAddPackage); ok {
+				addPkg.Creator = addressFromString(t, "g1qpymzwx4l4cy6cerdyajp9ksvjsf20rk5y9rtt")
+				msg = addPkg
+			}
+
+			msgs = append(msgs, msg)
+		}
+
+		tx.Tx.Msgs = msgs
+		tx.Tx.Signatures = []std.Signature{{Signature: []byte("restart")}}
+
+		restarted = append(restarted, tx)
+	}
+
+	return restarted
+}
+
+// writeGenesis writes a fixture genesis with the given txs, wrapped in the RPC
+// /genesis response if needed. The genesis carries fields unknown to the gno types
+func writeGenesis(t *testing.T, txs []gnoland.TxWithMetadata, wrapped bool) string {
+	t.Helper()
+
+	rawTxs := make([]json.RawMessage, 0, len(txs))
+
+	for _, tx := range txs {
+		raw, err := amino.MarshalJSON(tx)
+		require.NoError(t, err)
+
+		rawTxs = append(rawTxs, raw)
+	}
+
+	genesis := map[string]any{
+		"genesis_time": "2026-06-18T00:00:00Z",
+		"chain_id":     "staging",
+		"app_state": map[string]any{
+			"@type": "/gno.GenesisState",
+			"balances": []string{
+				"g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5=10000000000ugnot",
+				testDeployer + "=2100000ugnot",
+				"g1qpymzwx4l4cy6cerdyajp9ksvjsf20rk5y9rtt=1000ugnot,5foo",
+			},
+			"txs": rawTxs,
+			"vm": map[string]any{
+				"params": map[string]any{"unknown_param": "1"},
+			},
+		},
+	}
+
+	var doc any = genesis
+	if wrapped {
+		doc = map[string]any{
+			"jsonrpc": "2.0",
+			"id":      "",
+			"result":  map[string]any{"genesis": genesis},
+		}
+	}
+
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o644))
+
+	return path
+}
+
+// txChunk renders the backup lines of the txs
+func txChunk(t *testing.T, txs ...gnoland.TxWithMetadata) string {
+	t.Helper()
+
+	var b strings.Builder
+
+	for _, tx := range txs {
+		line, err := amino.MarshalJSON(tx)
+		require.NoError(t, err)
+
+		b.Write(line)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func TestGenesisExport(t *testing.T) {
+	t.Parallel()
+
+	const expectedBalances = "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5=10000000000ugnot\n" +
+		"g1qpymzwx4l4cy6cerdyajp9ksvjsf20rk5y9rtt=5foo,1000ugnot\n"
+
+	export := func(t *testing.T, cfg *genesisExportCfg) string {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		if cfg.chunkSize == 0 {
+			cfg.chunkSize = 2
+		}
+
+		var out bytes.Buffer
+
+		require.NoError(t, execGenesisExport(ctx, cfg, &out))
+
+		return out.String()
+	}
+
+	t.Run("initial chunks", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			txs      = genesisTxs(t, 4)
+			chainDir = t.TempDir()
+		)
+
+		out := export(t, &genesisExportCfg{
+			genesisPath: writeGenesis(t, txs, false),
+			chainDir:    chainDir,
+		})
+
+		expected := "balances: 2\n" +
+			"removed deployer balance: " + testDeployer + "\n" +
+			"genesis txs: 5, backed up: 0, new: 5\n" +
+			"wrote backup_staging_txs_1-2.jsonl\n" +
+			"wrote backup_staging_txs_3-4.jsonl\n" +
+			"wrote backup_staging_txs_5-5.jsonl\n"
+
+		assert.Equal(t, expected, out)
+
+		assert.Equal(t, map[string]string{
+			"./":                            "",
+			"backup_staging_balances.jsonl": expectedBalances,
+			"backup_staging_txs_1-2.jsonl":  txChunk(t, txs[0:2]...),
+			"backup_staging_txs_3-4.jsonl":  txChunk(t, txs[2:4]...),
+			"backup_staging_txs_5-5.jsonl":  txChunk(t, txs[4]),
+		}, readTree(t, chainDir))
+	})
+
+	t.Run("new txs", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			txs    = genesisTxs(t, 5)
+			backup = restartTxs(t, txs)
+		)
+
+		// The backed up txs were signed with the keys of a previous restart
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_staging_txs_1-2.jsonl":  txChunk(t, backup[0:2]...),
+			"backup_staging_txs_3-3.jsonl":  txChunk(t, backup[2]),
+			"backup_staging_balances.jsonl": "old\n",
+		})
+
+		out := export(t, &genesisExportCfg{
+			genesisPath: writeGenesis(t, txs, false),
+			chainDir:    chainDir,
+		})
+
+		assert.Contains(t, out, "genesis txs: 6, backed up: 3, new: 3\n"+
+			"wrote backup_staging_txs_3-4.jsonl\n"+
+			"wrote backup_staging_txs_5-6.jsonl\n")
+
+		assert.Equal(t, map[string]string{
+			"./":                            "",
+			"backup_staging_balances.jsonl": expectedBalances,
+			"backup_staging_txs_1-2.jsonl":  txChunk(t, backup[0:2]...),
+			"backup_staging_txs_3-4.jsonl":  txChunk(t, backup[2], txs[3]),
+			"backup_staging_txs_5-6.jsonl":  txChunk(t, txs[4:6]...),
+		}, readTree(t, chainDir))
+	})
+
+	t.Run("reordered and dropped txs", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			txs    = genesisTxs(t, 3)
+			backup = restartTxs(t, txs)
+		)
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_staging_txs_1-3.jsonl": txChunk(t, backup[0:3]...),
+		})
+
+		// The genesis drops the second tx, and puts a new one before the third
+		out := export(t, &genesisExportCfg{
+			genesisPath: writeGenesis(t, []gnoland.TxWithMetadata{txs[0], txs[3], txs[2]}, false),
+			chainDir:    chainDir,
+			chunkSize:   4,
+		})
+
+		assert.Contains(t, out, "genesis txs: 3, backed up: 3, new: 1\n"+
+			"backed up txs missing from the genesis: 1\n"+
+			"wrote backup_staging_txs_1-4.jsonl\n")
+
+		assert.Equal(t, txChunk(t, backup[0], backup[1], backup[2], txs[3]), readTree(t, chainDir)["backup_staging_txs_1-4.jsonl"])
+	})
+
+	t.Run("repeated txs", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			tx     = genesisTxs(t, 1)[1]
+			backup = restartTxs(t, []gnoland.TxWithMetadata{tx})
+		)
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_staging_txs_1-1.jsonl": txChunk(t, backup...),
+		})
+
+		out := export(t, &genesisExportCfg{
+			genesisPath: writeGenesis(t, []gnoland.TxWithMetadata{tx, tx, tx}, false),
+			chainDir:    chainDir,
+		})
+
+		assert.Contains(t, out, "genesis txs: 3, backed up: 1, new: 2\n")
+
+		tree := readTree(t, chainDir)
+
+		assert.Equal(t, txChunk(t, backup[0], tx), tree["backup_staging_txs_1-2.jsonl"])
+		assert.Equal(t, txChunk(t, tx), tree["backup_staging_txs_3-3.jsonl"])
+	})
+
+	t.Run("full last chunk", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			txs    = genesisTxs(t, 2)
+			backup = restartTxs(t, txs)
+		)
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_staging_txs_1-2.jsonl": txChunk(t, backup[0:2]...),
+		})
+
+		export(t, &genesisExportCfg{
+			genesisPath: writeGenesis(t, txs, false),
+			chainDir:    chainDir,
+		})
+
+		tree := readTree(t, chainDir)
+
+		assert.Equal(t, txChunk(t, backup[0:2]...), tree["backup_staging_txs_1-2.jsonl"])
+		assert.Equal(t, txChunk(t, txs[2]), tree["backup_staging_txs_3-3.jsonl"])
+	})
+
+	t.Run("no new txs", func(t *testing.T) {
+		t.Parallel()
+
+		txs := genesisTxs(t, 2)
+
+		files := map[string]string{
+			"backup_staging_txs_1-2.jsonl": txChunk(t, txs[0:2]...),
+			"backup_staging_txs_3-3.jsonl": txChunk(t, restartTxs(t, txs)[2]),
+		}
+
+		chainDir := writeBackupFiles(t, files)
+
+		out := export(t, &genesisExportCfg{
+			genesisPath: writeGenesis(t, txs, false),
+			chainDir:    chainDir,
+		})
+
+		assert.True(t, strings.HasSuffix(out, "genesis txs: 3, backed up: 3, new: 0\nno new txs\n"))
+
+		files["./"] = ""
+		files[stagingBalancesFile] = expectedBalances
+
+		assert.Equal(t, files, readTree(t, chainDir))
+	})
+
+	t.Run("undecodable chunk", func(t *testing.T) {
+		t.Parallel()
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_staging_txs_1-2.jsonl": txChunk(t, genesisTxs(t, 0)...) + "{\"tx\": \n",
+		})
+
+		err := execGenesisExport(context.Background(), &genesisExportCfg{
+			genesisPath: writeGenesis(t, genesisTxs(t, 2), false),
+			chainDir:    chainDir,
+			chunkSize:   2,
+		}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "unable to decode backup_staging_txs_1-2.jsonl tx 2")
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			txs    = genesisTxs(t, 4)
+			backup = restartTxs(t, txs)
+		)
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_staging_txs_1-1.jsonl": txChunk(t, backup[0]),
+			"backup_staging_txs_1-2.jsonl": txChunk(t, txs[0:2]...),
+			"backup_staging_txs_3-3.jsonl": txChunk(t, txs[4]),
+			"backup_staging_txs_3-4.jsonl": txChunk(t, txs[2:4]...),
+			"backup_staging_txs_4-5.jsonl": txChunk(t, txs[3:5]...),
+		})
+
+		// The repair needs no genesis
+		out := export(t, &genesisExportCfg{
+			chainDir: chainDir,
+			repair:   true,
+		})
+
+		expected := "removed backup_staging_txs_1-1.jsonl, its txs are in backup_staging_txs_1-2.jsonl\n" +
+			"overlap left: backup_staging_txs_3-3.jsonl and backup_staging_txs_3-4.jsonl, 1 txs of backup_staging_txs_3-3.jsonl are not in backup_staging_txs_3-4.jsonl\n" +
+			"overlap left: backup_staging_txs_3-4.jsonl and backup_staging_txs_4-5.jsonl\n"
+
+		assert.Equal(t, expected, out)
+
+		tree := readTree(t, chainDir)
+
+		assert.NotContains(t, tree, "backup_staging_txs_1-1.jsonl")
+		assert.Len(t, tree, 5)
+
+		assert.Equal(t, "no overlapping chunks removed\n", export(t, &genesisExportCfg{
+			chainDir: t.TempDir(),
+			repair:   true,
+		}))
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		t.Parallel()
+
+		txs := genesisTxs(t, 1)
+
+		raw, err := os.ReadFile(writeGenesis(t, txs, true))
+		require.NoError(t, err)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != genesisEndpoint {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			_, _ = w.Write(raw)
+		}))
+		t.Cleanup(server.Close)
+
+		chainDir := t.TempDir()
+
+		export(t, &genesisExportCfg{
+			remote:    server.URL + "/",
+			chainDir:  chainDir,
+			chunkSize: defaultChunkSize,
+		})
+
+		assert.Equal(t, txChunk(t, txs...), readTree(t, chainDir)["backup_staging_txs_1-2.jsonl"])
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			ctx      = context.Background()
+			chainDir = t.TempDir()
+			genesis  = filepath.Join(chainDir, "genesis.json")
+		)
+
+		testTable := []struct {
+			name     string
+			cfg      *genesisExportCfg
+			expected error
+		}{
+			{"missing genesis", &genesisExportCfg{chainDir: chainDir, chunkSize: 1}, errMissingGenesis},
+			{"invalid chunk size", &genesisExportCfg{genesisPath: genesis, chainDir: chainDir}, errInvalidChunkSize},
+			{"invalid chain dir", &genesisExportCfg{genesisPath: genesis, chainDir: genesis, chunkSize: 1}, errInvalidChainDir},
+		}
+
+		for _, testCase := range testTable {
+			assert.ErrorIs(t, execGenesisExport(ctx, testCase.cfg, &bytes.Buffer{}), testCase.expected, testCase.name)
+		}
+
+		require.NoError(t, os.WriteFile(genesis, []byte(`{"genesis_time": "2026-06-18T00:00:00Z"}`), 0o644))
+
+		err := execGenesisExport(ctx, &genesisExportCfg{genesisPath: genesis, chainDir: chainDir, chunkSize: 1}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, errInvalidGenesis)
+	})
+}
+
+func TestDecodeGenesisExport(t *testing.T) {
+	t.Parallel()
+
+	// A genesis without package deployment has no deployer to remove
+	txs := genesisTxs(t, 1)[1:]
+
+	raw, err := os.ReadFile(writeGenesis(t, txs, false))
+	require.NoError(t, err)
+
+	export, err := decodeGenesisExport(raw)
+	require.NoError(t, err)
+
+	assert.True(t, export.deployer.IsZero())
+	assert.Len(t, export.balances, 3)
+	assert.Len(t, export.txs, 1)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// formatDOT is the Graphviz output format of the dependency graph
+const formatDOT = "dot"
+
+// Define deps config
+type depsCfg struct {
+	outputPath string
+	format     string
+	title      string
+	height     uint64
+
+	sourceCfg
+	rejectsCfg
+}
+
+// newDepsCmd creates the import dependency graph command
+func newDepsCmd() *ffcli.Command {
+	var (
+		cfg = &depsCfg{}
+		fs  = flag.NewFlagSet("deps", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "deps",
+		ShortUsage: "deps [flags]",
+		ShortHelp:  "builds the import graph of the deployed packages",
+		LongHelp:   "Builds the import graph between the packages deployed on the chain, optionally as of a block height, as Markdown, JSON or DOT",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execDeps(ctx, cfg, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the deps command flag set
+func (c *depsCfg) registerFlags(fs *flag.FlagSet) {
+	c.sourceCfg.registerFlags(fs)
+
+	fs.StringVar(
+		&c.outputPath,
+		"output-path",
+		"",
+		"the output file for the graph (stdout if empty)",
+	)
+
+	fs.StringVar(
+		&c.format,
+		"format",
+		formatMarkdown,
+		fmt.Sprintf("the graph output format (%s, %s, %s)", formatMarkdown, formatJSON, formatDOT),
+	)
+
+	fs.StringVar(
+		&c.title,
+		"title",
+		"",
+		"the title of the Markdown summary (ie the chain remote)",
+	)
+
+	fs.Uint64Var(
+		&c.height,
+		"height",
+		0,
+		"build the graph as of this block height (the latest state if 0)",
+	)
+
+	c.rejectsCfg.registerFlags(fs)
+}
+
+// execDeps runs the import dependency graph
+func execDeps(ctx context.Context, cfg *depsCfg, stdout io.Writer) error {
+	// Check the source is valid
+	if err := cfg.sourceCfg.validate(); err != nil {
+		return err
+	}
+
+	// Check the output format is valid
+	if cfg.format != formatMarkdown && cfg.format != formatJSON && cfg.format != formatDOT {
+		return fmt.Errorf("%w %q", errInvalidFormat, cfg.format)
+	}
+
+	sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	rejects, err := newRejectHandler(cfg.rejectsCfg)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
+
+	// The latest deployment of every package path (as of the height)
+	packages := make(map[string]DepPackage)
+
+	for _, sourceFile := range sourceFiles {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			for msg, err := range filePackages(sourceFile, rejects) {
+				if err != nil {
+					return err
+				}
+
+				if cfg.height != 0 && msg.Height > cfg.height {
+					continue
+				}
+
+				packages[msg.Package.Path] = DepPackage{
+					Path:    msg.Package.Path,
+					Height:  msg.Height,
+					Imports: packageDeps(msg),
+				}
+			}
+		}
+	}
+
+	if err := rejects.close(); err != nil {
+		return err
+	}
+
+	graph := newDepGraph(packages)
+	graph.Title = cfg.title
+	graph.Height = cfg.height
+
+	return writeOutput(cfg.outputPath, stdout, func(out io.Writer) error {
+		var output string
+
+		switch cfg.format {
+		case formatJSON:
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+
+			if err := encoder.Encode(graph); err != nil {
+				return fmt.Errorf("unable to write graph, %w", err)
+			}
+
+			return nil
+		case formatDOT:
+			output = renderDepsDOT(graph)
+		default:
+			output = renderDepsMarkdown(graph)
+		}
+
+		if _, err := io.WriteString(out, output); err != nil {
+			return fmt.Errorf("unable to write graph, %w", err)
+		}
+
+		return nil
+	})
+}
+
+// packageDeps returns the package paths imported by the deployed package,
+// without the standard libraries, the test files and the package itself
+func packageDeps(msg AddPackage) []string {
+	deps := make([]string, 0)
+
+	for _, importPath := range packageImports(msg.Package.Files, false) {
+		if importPath == msg.Package.Path || isStdlibPath(importPath) {
+			continue
+		}
+
+		deps = append(deps, importPath)
+	}
+
+	return deps
+}
+
+// isStdlibPath checks if the import path is a standard library,
+// whose first element is not a domain (ie strings, chain/banker)
+func isStdlibPath(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+
+	return !strings.Contains(first, ".")
+}
+
+// DepGraph defines the import graph between the deployed packages of a chain
+type DepGraph struct {
+	Title        string       `json:"title"`            // the graph title (ie the chain remote)
+	Height       uint64       `json:"height,omitempty"` // the graph is as of this height, 0 for the latest
+	Packages     []DepPackage `json:"packages"`         // the deployed packages, sorted by path
+	MostImported []PathCount  `json:"most_imported"`    // the number of importers per deployed package
+	Orphans      []string     `json:"orphans"`          // the deployed packages no other package imports
+	Undeployed   []PathCount  `json:"undeployed"`       // the number of importers per never deployed path
+}
+
+// DepPackage defines the imports of a single deployed package
+type DepPackage struct {
+	Path    string   `json:"path"`
+	Height  uint64   `json:"height"`  // the block height of the latest deployment
+	Imports []string `json:"imports"` // the imported package paths, standard libraries excluded
+}
+
+// newDepGraph builds the import graph of the given deployed packages
+func newDepGraph(packages map[string]DepPackage) DepGraph {
+	var (
+		importers  = make(map[string]int)
+		undeployed = make(map[string]int)
+
+		graph = DepGraph{
+			Packages:     make([]DepPackage, 0, len(packages)),
+			MostImported: make([]PathCount, 0),
+			Orphans:      make([]string, 0),
+		}
+	)
+
+	for _, pkg := range packages {
+		graph.Packages = append(graph.Packages, pkg)
+
+		for _, importPath := range pkg.Imports {
+			if _, ok := packages[importPath]; !ok {
+				undeployed[importPath]++
+
+				continue
+			}
+
+			importers[importPath]++
+		}
+	}
+
+	slices.SortFunc(graph.Packages, func(a, b DepPackage) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	for _, pkg := range graph.Packages {
+		if importers[pkg.Path] == 0 {
+			graph.Orphans = append(graph.Orphans, pkg.Path)
+		}
+	}
+
+	graph.MostImported = sortedPathCounts(importers)
+	graph.Undeployed = sortedPathCounts(undeployed)
+
+	return graph
+}
+
+// renderDepsMarkdown renders the graph summary as Markdown
+func renderDepsMarkdown(graph DepGraph) string {
+	var b strings.Builder
+
+	section := func(title string, writeFn func()) {
+		fmt.Fprintf(&b, "## %s\n```\n", title)
+		writeFn()
+		b.WriteString("```\n\n")
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n", graph.Title)
+
+	if graph.Height != 0 {
+		fmt.Fprintf(&b, "As of height %d.\n\n", graph.Height)
+	}
+
+	section("packages", func() {
+		fmt.Fprintf(&b, "%d\n", len(graph.Packages))
+	})
+
+	section("most imported", func() {
+		for _, pc := range graph.MostImported {
+			fmt.Fprintf(&b, "%7d %s\n", pc.Count, quoteJSON(pc.Path))
+		}
+	})
+
+	section("orphans", func() {
+		for _, path := range graph.Orphans {
+			fmt.Fprintf(&b, "%s\n", quoteJSON(path))
+		}
+	})
+
+	section("never deployed imports", func() {
+		for _, pc := range graph.Undeployed {
+			fmt.Fprintf(&b, "%7d %s\n", pc.Count, quoteJSON(pc.Path))
+		}
+	})
+
+	return b.String()
+}
+
+// renderDepsDOT renders the graph in the Graphviz DOT format.
+// Never deployed paths are drawn dashed
+func renderDepsDOT(graph DepGraph) string {
+	var b strings.Builder
+
+	b.WriteString("digraph deps {\n")
+
+	for _, pkg := range graph.Packages {
+		fmt.Fprintf(&b, "  %s;\n", quoteJSON(pkg.Path))
+	}
+
+	for _, pc := range graph.Undeployed {
+		fmt.Fprintf(&b, "  %s [style=dashed];\n", quoteJSON(pc.Path))
+	}
+
+	for _, pkg := range graph.Packages {
+		for _, importPath := range pkg.Imports {
+			fmt.Fprintf(&b, "  %s -> %s;\n", quoteJSON(pkg.Path), quoteJSON(importPath))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
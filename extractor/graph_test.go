@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateDepsSourceFile generates a backup file deploying packages that import
+// each other, one per block. The last deployment drops an import
+func generateDepsSourceFile(t *testing.T, dir string) {
+	t.Helper()
+
+	var (
+		creator = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+
+		pkg = func(path string, files ...*std.MemFile) *std.MemPackage {
+			return &std.MemPackage{
+				Name:  filepath.Base(path),
+				Path:  path,
+				Files: files,
+			}
+		}
+
+		packages = []*std.MemPackage{
+			pkg("gno.land/p/demo/avl", &std.MemFile{
+				Name: "avl.gno",
+				Body: "package avl\n\nimport \"strings\"\n",
+			}),
+			pkg(
+				"gno.land/r/demo/a",
+				&std.MemFile{
+					Name: "a.gno",
+					Body: "package a\n\nimport (\n\t\"strings\"\n\n\t\"gno.land/p/demo/avl\"\n\t\"gno.land/p/demo/ufmt\"\n)\n",
+				},
+				&std.MemFile{
+					Name: "a_test.gno",
+					Body: "package a\n\nimport \"gno.land/p/demo/testutils\"\n",
+				},
+			),
+			pkg("gno.land/r/demo/b", &std.MemFile{
+				Name: "b.gno",
+				Body: "package b\n\nimport (\n\t\"gno.land/p/demo/avl\"\n\t\"gno.land/r/demo/a\"\n)\n",
+			}),
+			pkg("gno.land/r/demo/a", &std.MemFile{
+				Name: "a.gno",
+				Body: "package a\n\nimport \"gno.land/p/demo/avl\"\n",
+			}),
+		}
+	)
+
+	file, err := os.Create(filepath.Join(dir, "backup_0000001-0001000.jsonl"))
+	require.NoError(t, err)
+
+	for i, p := range packages {
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx: std.Tx{
+				Msgs: []std.Msg{
+					vm.MsgAddPackage{
+						Creator: creator,
+						Package: p,
+					},
+				},
+			},
+			Metadata: &gnoland.GnoTxMetadata{
+				BlockHeight: int64(i + 1),
+			},
+		}, file))
+	}
+
+	require.NoError(t, file.Close())
+}
+
+func TestDeps(t *testing.T) {
+	t.Parallel()
+
+	sourceDir := t.TempDir()
+	generateDepsSourceFile(t, sourceDir)
+
+	deps := func(t *testing.T, format string, height uint64) string {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		var out bytes.Buffer
+
+		require.NoError(t, execDeps(ctx, &depsCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			format:    format,
+			title:     "test",
+			height:    height,
+		}, &out))
+
+		return out.String()
+	}
+
+	t.Run("latest", func(t *testing.T) {
+		t.Parallel()
+
+		var graph DepGraph
+		require.NoError(t, json.Unmarshal([]byte(deps(t, formatJSON, 0)), &graph))
+
+		assert.Equal(t, DepGraph{
+			Title: "test",
+			Packages: []DepPackage{
+				{Path: "gno.land/p/demo/avl", Height: 1, Imports: []string{}},
+				{Path: "gno.land/r/demo/a", Height: 4, Imports: []string{"gno.land/p/demo/avl"}},
+				{Path: "gno.land/r/demo/b", Height: 3, Imports: []string{"gno.land/p/demo/avl", "gno.land/r/demo/a"}},
+			},
+			MostImported: []PathCount{
+				{Path: "gno.land/p/demo/avl", Count: 2},
+				{Path: "gno.land/r/demo/a", Count: 1},
+			},
+			Orphans:    []string{"gno.land/r/demo/b"},
+			Undeployed: []PathCount{},
+		}, graph)
+	})
+
+	t.Run("as of height", func(t *testing.T) {
+		t.Parallel()
+
+		var graph DepGraph
+		require.NoError(t, json.Unmarshal([]byte(deps(t, formatJSON, 2)), &graph))
+
+		assert.Equal(t, DepGraph{
+			Title:  "test",
+			Height: 2,
+			Packages: []DepPackage{
+				{Path: "gno.land/p/demo/avl", Height: 1, Imports: []string{}},
+				{Path: "gno.land/r/demo/a", Height: 2, Imports: []string{"gno.land/p/demo/avl", "gno.land/p/demo/ufmt"}},
+			},
+			MostImported: []PathCount{
+				{Path: "gno.land/p/demo/avl", Count: 1},
+			},
+			Orphans: []string{"gno.land/r/demo/a"},
+			Undeployed: []PathCount{
+				{Path: "gno.land/p/demo/ufmt", Count: 1},
+			},
+		}, graph)
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+
+		expected := "# test\n\n" +
+			"As of height 3.\n\n" +
+			"## packages\n```\n3\n```\n\n" +
+			"## most imported\n```\n" +
+			"      2 \"gno.land/p/demo/avl\"\n" +
+			"      1 \"gno.land/r/demo/a\"\n" +
+			"```\n\n" +
+			"## orphans\n```\n" +
+			"\"gno.land/r/demo/b\"\n" +
+			"```\n\n" +
+			"## never deployed imports\n```\n" +
+			"      1 \"gno.land/p/demo/ufmt\"\n" +
+			"```\n\n"
+
+		assert.Equal(t, expected, deps(t, formatMarkdown, 3))
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		t.Parallel()
+
+		expected := "digraph deps {\n" +
+			"  \"gno.land/p/demo/avl\";\n" +
+			"  \"gno.land/r/demo/a\";\n" +
+			"  \"gno.land/p/demo/ufmt\" [style=dashed];\n" +
+			"  \"gno.land/r/demo/a\" -> \"gno.land/p/demo/avl\";\n" +
+			"  \"gno.land/r/demo/a\" -> \"gno.land/p/demo/ufmt\";\n" +
+			"}\n"
+
+		assert.Equal(t, expected, deps(t, formatDOT, 2))
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		t.Parallel()
+
+		err := execDeps(context.Background(), &depsCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			format:    formatCSV,
+		}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, errInvalidFormat)
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+)
+
+// backupFileRegex matches the tx-archive backup file names (backup_<from>-<to>.jsonl)
+var backupFileRegex = regexp.MustCompile(`^backup_(\d+)-(\d+)\.`)
+
+// blockRange is an inclusive block range covered by a single backup file
+type blockRange struct {
+	from uint64
+	to   uint64
+}
+
+// blockRangeFromPath parses the block range from a backup file name.
+// Returns false if the file name does not follow the backup_<from>-<to> format
+func blockRangeFromPath(path string) (blockRange, bool) {
+	matches := backupFileRegex.FindStringSubmatch(filepath.Base(path))
+	if matches == nil {
+		return blockRange{}, false
+	}
+
+	from, err := strconv.ParseUint(matches[1], 10, 64)
+	if err != nil {
+		return blockRange{}, false
+	}
+
+	to, err := strconv.ParseUint(matches[2], 10, 64)
+	if err != nil || to < from {
+		return blockRange{}, false
+	}
+
+	return blockRange{
+		from: from,
+		to:   to,
+	}, true
+}
+
+// heightSource defines where the block height of a transaction comes from
+type heightSource string
+
+const (
+	// heightSourceBlock is the block height recorded along with the transaction
+	heightSourceBlock heightSource = "block"
+
+	// heightSourceFileRange is the start of the backup file block range,
+	// a lower bound of the actual block height
+	heightSourceFileRange heightSource = "file_range"
+
+	// heightSourceUnknown is a missing block height (0)
+	heightSourceUnknown heightSource = "unknown"
+)
+
+// heightSourceOf returns the source of a block height read from the transaction itself
+func heightSourceOf(height uint64) heightSource {
+	if height == 0 {
+		return heightSourceUnknown
+	}
+
+	return heightSourceBlock
+}
+
+// heightFromMetadata returns the block height for a tx-archive transaction.
+// The block height written by tx-archive is preferred; when it is missing,
+// the lower bound of the file block range (fallback) is used instead
+func heightFromMetadata(tx gnoland.TxWithMetadata, fallback uint64) uint64 {
+	if tx.Metadata != nil && tx.Metadata.BlockHeight > 0 {
+		return uint64(tx.Metadata.BlockHeight)
+	}
+
+	return fallback
+}
+
+// heightSourceFromMetadata returns the source of the heightFromMetadata block height
+func heightSourceFromMetadata(tx gnoland.TxWithMetadata, fallback uint64) heightSource {
+	if tx.Metadata != nil && tx.Metadata.BlockHeight > 0 {
+		return heightSourceBlock
+	}
+
+	if fallback > 0 {
+		return heightSourceFileRange
+	}
+
+	return heightSourceUnknown
+}
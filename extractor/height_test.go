@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockRangeFromPath(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		path     string
+		expected blockRange
+		valid    bool
+	}{
+		{
+			"valid backup file",
+			"gnoland1/backup_0300001-0368926.jsonl",
+			blockRange{from: 300001, to: 368926},
+			true,
+		},
+		{
+			"single block backup file",
+			"backup_0000010-0000010.jsonl",
+			blockRange{from: 10, to: 10},
+			true,
+		},
+		{
+			"staging tx chunk",
+			"backup_staging_txs_1001-2000.jsonl",
+			blockRange{},
+			false,
+		},
+		{
+			"inverted range",
+			"backup_0000010-0000001.jsonl",
+			blockRange{},
+			false,
+		},
+		{
+			"legacy log",
+			"txexport-al.log",
+			blockRange{},
+			false,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, ok := blockRangeFromPath(testCase.path)
+
+			assert.Equal(t, testCase.valid, ok)
+			assert.Equal(t, testCase.expected, r)
+		})
+	}
+}
+
+func TestHeightFromMetadata(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, uint64(42), heightFromMetadata(gnoland.TxWithMetadata{}, 42))
+	assert.Equal(
+		t,
+		uint64(42),
+		heightFromMetadata(gnoland.TxWithMetadata{Metadata: &gnoland.GnoTxMetadata{Timestamp: 1}}, 42),
+	)
+	assert.Equal(
+		t,
+		uint64(100),
+		heightFromMetadata(gnoland.TxWithMetadata{Metadata: &gnoland.GnoTxMetadata{BlockHeight: 100}}, 42),
+	)
+}
+
+func TestHeightSourceFromMetadata(t *testing.T) {
+	t.Parallel()
+
+	withHeight := gnoland.TxWithMetadata{Metadata: &gnoland.GnoTxMetadata{BlockHeight: 100}}
+
+	assert.Equal(t, heightSourceBlock, heightSourceFromMetadata(withHeight, 42))
+	assert.Equal(t, heightSourceFileRange, heightSourceFromMetadata(gnoland.TxWithMetadata{}, 42))
+	assert.Equal(t, heightSourceUnknown, heightSourceFromMetadata(gnoland.TxWithMetadata{}, 0))
+}
+
+func TestValidFlow_Heights(t *testing.T) {
+	t.Parallel()
+
+	_, mockAddPkgMsg := generateMockMsgs(t)
+	require.NotEmpty(t, mockAddPkgMsg)
+
+	msg := mockAddPkgMsg[0]
+
+	t.Run("block height from metadata and file range", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			outputDir = t.TempDir()
+			sourceDir = t.TempDir()
+		)
+
+		file, err := os.Create(filepath.Join(sourceDir, "backup_0000100-0000200.jsonl"))
+		require.NoError(t, err)
+
+		// The first deployment has no height, the second one does
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx: std.Tx{Msgs: []std.Msg{msg}},
+		}, file))
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx:       std.Tx{Msgs: []std.Msg{msg}},
+			Metadata: &gnoland.GnoTxMetadata{BlockHeight: 150},
+		}, file))
+		require.NoError(t, file.Close())
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputDir: outputDir,
+		}))
+
+		basePath, err := packageDir(outputDir, msg.Package.Path)
+		require.NoError(t, err)
+
+		// The file range start is a lower bound of the first deployment height
+		first := readMetadata(t, versionDir(basePath, 1))
+		assert.Equal(t, uint64(100), first.Height)
+		assert.Equal(t, string(heightSourceFileRange), first.HeightSource)
+
+		latest := readMetadata(t, basePath)
+		assert.Equal(t, uint64(150), latest.Height)
+		assert.Equal(t, string(heightSourceBlock), latest.HeightSource)
+
+		raw, err := os.ReadFile(filepath.Join(basePath, packageVersionsFile))
+		require.NoError(t, err)
+
+		var versions PackageVersions
+		require.NoError(t, json.Unmarshal(raw, &versions))
+
+		require.Len(t, versions.Versions, 2)
+		assert.Equal(t, string(heightSourceFileRange), versions.Versions[0].HeightSource)
+		assert.Equal(t, string(heightSourceBlock), versions.Versions[1].HeightSource)
+	})
+
+	t.Run("legacy block number", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			outputDir = t.TempDir()
+			sourceDir = t.TempDir()
+		)
+
+		data, err := amino.MarshalJSON(LegacyTx{
+			Tx:       std.Tx{Msgs: []std.Msg{msg}},
+			BlockNum: 64,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(
+			filepath.Join(sourceDir, "backup_0000001-0010001.jsonl"),
+			append(data, '\n'),
+			0o644,
+		))
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputDir: outputDir,
+		}))
+
+		basePath, err := packageDir(outputDir, msg.Package.Path)
+		require.NoError(t, err)
+
+		md := readMetadata(t, basePath)
+		assert.Equal(t, uint64(64), md.Height)
+		assert.Equal(t, string(heightSourceBlock), md.HeightSource)
+	})
+}
+
+func readMetadata(t *testing.T, dir string) Metadata {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join(dir, packageMetadataFile))
+	require.NoError(t, err)
+
+	var md Metadata
+	require.NoError(t, json.Unmarshal(raw, &md))
+
+	return md
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContextLines is the number of context lines around every diff hunk
+const diffContextLines = 3
+
+// noNewlineMarker follows an unterminated last line in a diff
+const noNewlineMarker = "\\ No newline at end of file\n"
+
+var (
+	errMissingPackagePath = errors.New("missing package path argument")
+	errPackageNotDeployed = errors.New("package path was never deployed")
+)
+
+// Define history config
+type historyCfg struct {
+	outputPath string
+	outputDir  string
+	list       bool
+
+	sourceCfg
+	rejectsCfg
+}
+
+// newHistoryCmd creates the package history command
+func newHistoryCmd() *ffcli.Command {
+	var (
+		cfg = &historyCfg{}
+		fs  = flag.NewFlagSet("history", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "history",
+		ShortUsage: "history [flags] <pkgpath>",
+		ShortHelp:  "diffs the consecutive deployments of a package",
+		LongHelp:   "Lists every deployment of the package path, and writes the unified diffs between consecutive versions as a patch series",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return execHistory(ctx, cfg, args, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the history command flag set
+func (c *historyCfg) registerFlags(fs *flag.FlagSet) {
+	c.sourceCfg.registerFlags(fs)
+
+	fs.StringVar(
+		&c.outputPath,
+		"output-path",
+		"",
+		"the output file for the patch series (stdout if empty)",
+	)
+
+	fs.StringVar(
+		&c.outputDir,
+		"output-dir",
+		"",
+		"the output directory for the patch series, one numbered patch file per version",
+	)
+
+	fs.BoolVar(
+		&c.list,
+		"list",
+		false,
+		"flag indicating if only the list of deployments should be written, without the diffs",
+	)
+
+	c.rejectsCfg.registerFlags(fs)
+}
+
+// execHistory runs the package history
+func execHistory(ctx context.Context, cfg *historyCfg, args []string, stdout io.Writer) error {
+	// Check the source is valid
+	if err := cfg.sourceCfg.validate(); err != nil {
+		return err
+	}
+
+	// Check the package path is valid
+	if len(args) != 1 {
+		return errMissingPackagePath
+	}
+
+	pkgPath := args[0]
+
+	if err := validatePackagePath(pkgPath); err != nil {
+		return err
+	}
+
+	sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	rejects, err := newRejectHandler(cfg.rejectsCfg)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
+
+	var versions []AddPackage
+
+	for _, sourceFile := range sourceFiles {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			for msg, err := range filePackages(sourceFile, rejects) {
+				if err != nil {
+					return err
+				}
+
+				if msg.Package.Path == pkgPath {
+					versions = append(versions, msg)
+				}
+			}
+		}
+	}
+
+	if err := rejects.close(); err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("%w %q", errPackageNotDeployed, pkgPath)
+	}
+
+	if cfg.outputDir != "" && !cfg.list {
+		return writePatchSeries(versions, cfg.outputDir)
+	}
+
+	return writeOutput(cfg.outputPath, stdout, func(out io.Writer) error {
+		if cfg.list {
+			if _, err := io.WriteString(out, renderVersionList(versions)); err != nil {
+				return fmt.Errorf("unable to write version list, %w", err)
+			}
+
+			return nil
+		}
+
+		var previous AddPackage
+
+		for i, version := range versions {
+			if _, err := io.WriteString(out, renderPatch(previous, version, i+1, len(versions))); err != nil {
+				return fmt.Errorf("unable to write patch, %w", err)
+			}
+
+			previous = version
+		}
+
+		return nil
+	})
+}
+
+// writePatchSeries writes every version as its own numbered patch file,
+// applying on top of the previous one (the first one creates the package)
+func writePatchSeries(versions []AddPackage, outputDir string) error {
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write dir, %w", err)
+	}
+
+	var previous AddPackage
+
+	for i, version := range versions {
+		var (
+			name  = fmt.Sprintf("%04d-height-%d.patch", i+1, version.Height)
+			patch = renderPatch(previous, version, i+1, len(versions))
+		)
+
+		if err := writeFileAtomic(filepath.Join(outputDir, name), []byte(patch)); err != nil {
+			return fmt.Errorf("unable to write patch %s, %w", name, err)
+		}
+
+		previous = version
+	}
+
+	return nil
+}
+
+// renderVersionList renders one line per deployment:
+// version, height, timestamp, creator, file count and tx hash
+func renderVersionList(versions []AddPackage) string {
+	var b strings.Builder
+
+	for i, version := range versions {
+		fmt.Fprintf(
+			&b,
+			"v%d\t%d\t%s\t%s\t%d files\t%s\n",
+			i+1,
+			version.Height,
+			formatTimestamp(version.Timestamp),
+			version.Creator,
+			len(version.Package.Files),
+			version.TxHash,
+		)
+	}
+
+	return b.String()
+}
+
+// renderPatch renders the patch from the previous version to the given one,
+// with a header holding the deployment details. The first version has
+// no previous version, and adds all the package files
+func renderPatch(previous, version AddPackage, number, total int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\n", version.Creator)
+
+	if version.Timestamp != 0 {
+		fmt.Fprintf(&b, "Date: %s\n", time.Unix(version.Timestamp, 0).UTC().Format(time.RFC1123Z))
+	}
+
+	fmt.Fprintf(&b, "Subject: [PATCH %d/%d] %s v%d\n\n", number, total, version.Package.Path, number)
+	fmt.Fprintf(&b, "Height: %d\n", version.Height)
+	fmt.Fprintf(&b, "Creator: %s\n", version.Creator)
+	fmt.Fprintf(&b, "Timestamp: %s\n", formatTimestamp(version.Timestamp))
+	fmt.Fprintf(&b, "Tx-Hash: %s\n", version.TxHash)
+	b.WriteString("---\n")
+
+	b.WriteString(diffPackages(previous, version))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// diffPackages renders the unified diffs of every file that differs
+// between the two package versions, sorted by file name
+func diffPackages(previous, version AddPackage) string {
+	var (
+		before = packageFileBodies(previous)
+		after  = packageFileBodies(version)
+		names  = make(map[string]struct{})
+	)
+
+	for name := range before {
+		names[name] = struct{}{}
+	}
+
+	for name := range after {
+		names[name] = struct{}{}
+	}
+
+	var b strings.Builder
+
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		oldBody, inBefore := before[name]
+		newBody, inAfter := after[name]
+
+		if inBefore && inAfter && oldBody == newBody {
+			continue
+		}
+
+		fromFile, toFile := "a/"+name, "b/"+name
+
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n", name, name)
+
+		switch {
+		case !inBefore:
+			b.WriteString("new file mode 100644\n")
+
+			fromFile = "/dev/null"
+		case !inAfter:
+			b.WriteString("deleted file mode 100644\n")
+
+			toFile = "/dev/null"
+		}
+
+		// Writing to a strings.Builder never fails
+		_ = difflib.WriteUnifiedDiff(&b, difflib.UnifiedDiff{
+			A:        splitLines(oldBody),
+			B:        splitLines(newBody),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  diffContextLines,
+		})
+	}
+
+	return b.String()
+}
+
+// packageFileBodies returns the file bodies of the package, keyed by file name
+func packageFileBodies(msg AddPackage) map[string]string {
+	bodies := make(map[string]string)
+
+	if msg.Package == nil {
+		return bodies
+	}
+
+	for _, file := range msg.Package.Files {
+		bodies[file.Name] = file.Body
+	}
+
+	return bodies
+}
+
+// splitLines splits the body into lines, keeping the line endings.
+// An unterminated last line is followed by the no newline marker,
+// so it differs from the same line terminated
+func splitLines(body string) []string {
+	if body == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(body, "\n")
+
+	if last := lines[len(lines)-1]; last == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] = last + "\n" + noNewlineMarker
+	}
+
+	return lines
+}
+
+// formatTimestamp formats the unix timestamp as RFC 3339,
+// or "unknown" for the archives without timestamps
+func formatTimestamp(timestamp int64) string {
+	if timestamp == 0 {
+		return "unknown"
+	}
+
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateHistorySourceFile generates a backup file redeploying
+// the same realm three times, along with another package
+func generateHistorySourceFile(t *testing.T, dir string) {
+	t.Helper()
+
+	var (
+		creator = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+
+		deployments = []*std.MemPackage{
+			{
+				Name: "foo",
+				Path: "gno.land/r/demo/foo",
+				Files: []*std.MemFile{
+					{Name: "foo.gno", Body: "package foo\n\nfunc A() int {\n\treturn 1\n}\n"},
+					{Name: "README.md", Body: "# foo"},
+				},
+			},
+			{
+				Name:  "bar",
+				Path:  "gno.land/r/demo/bar",
+				Files: []*std.MemFile{{Name: "bar.gno", Body: "package bar\n"}},
+			},
+			{
+				Name: "foo",
+				Path: "gno.land/r/demo/foo",
+				Files: []*std.MemFile{
+					{Name: "foo.gno", Body: "package foo\n\nfunc A() int {\n\treturn 2\n}\n"},
+					{Name: "helpers.gno", Body: "package foo\n"},
+				},
+			},
+			{
+				Name: "foo",
+				Path: "gno.land/r/demo/foo",
+				Files: []*std.MemFile{
+					{Name: "foo.gno", Body: "package foo\n\nfunc A() int {\n\treturn 2\n}\n"},
+					{Name: "helpers.gno", Body: "package foo\n"},
+				},
+			},
+		}
+	)
+
+	file, err := os.Create(filepath.Join(dir, "backup_0000001-0001000.jsonl"))
+	require.NoError(t, err)
+
+	for i, pkg := range deployments {
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx: std.Tx{
+				Msgs: []std.Msg{
+					vm.MsgAddPackage{
+						Creator: creator,
+						Package: pkg,
+					},
+				},
+			},
+			Metadata: &gnoland.GnoTxMetadata{
+				Timestamp:   1717236000 + int64(i)*60,
+				BlockHeight: int64(i+1) * 10,
+			},
+		}, file))
+	}
+
+	require.NoError(t, file.Close())
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	sourceDir := t.TempDir()
+	generateHistorySourceFile(t, sourceDir)
+
+	history := func(t *testing.T, cfg *historyCfg, args ...string) (string, error) {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		cfg.fileType = sourceFileType
+		cfg.sourcePath = sourceDir
+
+		var out bytes.Buffer
+
+		err := execHistory(ctx, cfg, args, &out)
+
+		return out.String(), err
+	}
+
+	t.Run("list", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := history(t, &historyCfg{list: true}, "gno.land/r/demo/foo")
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Len(t, lines, 3)
+
+		assert.True(t, strings.HasPrefix(
+			lines[1],
+			"v2\t30\t2024-06-01T10:02:00Z\tg1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5\t2 files\t",
+		))
+	})
+
+	t.Run("patches", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := history(t, &historyCfg{}, "gno.land/r/demo/foo")
+		require.NoError(t, err)
+
+		patches := strings.Split(out, "From: ")
+		require.Len(t, patches, 4)
+
+		// The first version adds every file
+		assert.Contains(t, patches[1], "Subject: [PATCH 1/3] gno.land/r/demo/foo v1\n")
+		assert.Contains(t, patches[1], "diff --git a/README.md b/README.md\nnew file mode 100644\n--- /dev/null\n+++ b/README.md\n@@ -0,0 +1 @@\n+# foo\n\\ No newline at end of file\n")
+
+		expected := "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5\n" +
+			"Date: Sat, 01 Jun 2024 10:02:00 +0000\n" +
+			"Subject: [PATCH 2/3] gno.land/r/demo/foo v2\n\n" +
+			"Height: 30\n" +
+			"Creator: g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5\n" +
+			"Timestamp: 2024-06-01T10:02:00Z\n"
+
+		assert.True(t, strings.HasPrefix(patches[2], expected))
+
+		expected = "---\n" +
+			"diff --git a/README.md b/README.md\n" +
+			"deleted file mode 100644\n" +
+			"--- a/README.md\n" +
+			"+++ /dev/null\n" +
+			"@@ -1 +0,0 @@\n" +
+			"-# foo\n" +
+			"\\ No newline at end of file\n" +
+			"diff --git a/foo.gno b/foo.gno\n" +
+			"--- a/foo.gno\n" +
+			"+++ b/foo.gno\n" +
+			"@@ -1,5 +1,5 @@\n" +
+			" package foo\n" +
+			" \n" +
+			" func A() int {\n" +
+			"-\treturn 1\n" +
+			"+\treturn 2\n" +
+			" }\n" +
+			"diff --git a/helpers.gno b/helpers.gno\n" +
+			"new file mode 100644\n" +
+			"--- /dev/null\n" +
+			"+++ b/helpers.gno\n" +
+			"@@ -0,0 +1 @@\n" +
+			"+package foo\n" +
+			"\n"
+
+		assert.True(t, strings.HasSuffix(patches[2], expected))
+
+		// Identical redeployments have no diff
+		assert.True(t, strings.HasSuffix(patches[3], "---\n\n"))
+	})
+
+	t.Run("patch series", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			outputDir = t.TempDir()
+			cfg       = &historyCfg{outputDir: outputDir}
+		)
+
+		out, err := history(t, cfg, "gno.land/r/demo/foo")
+		require.NoError(t, err)
+		assert.Empty(t, out)
+
+		stdout, err := history(t, &historyCfg{}, "gno.land/r/demo/foo")
+		require.NoError(t, err)
+
+		tree := readTree(t, outputDir)
+
+		assert.Equal(t, stdout, tree["0001-height-10.patch"]+tree["0002-height-30.patch"]+tree["0003-height-40.patch"])
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := history(t, &historyCfg{})
+		assert.ErrorIs(t, err, errMissingPackagePath)
+
+		_, err = history(t, &historyCfg{}, "gno.land/r/demo/missing")
+		assert.ErrorIs(t, err, errPackageNotDeployed)
+
+		_, err = history(t, &historyCfg{}, "gno.land/r/../foo")
+		assert.ErrorIs(t, err, errInvalidPackagePath)
+	})
+}
+
+func TestDiffPackages_NoNewline(t *testing.T) {
+	t.Parallel()
+
+	version := func(body string) AddPackage {
+		return AddPackage{
+			MsgAddPackage: vm.MsgAddPackage{
+				Package: &std.MemPackage{
+					Files: []*std.MemFile{{Name: "foo.gno", Body: body}},
+				},
+			},
+		}
+	}
+
+	// Terminating the last line is a change of its own
+	expected := "diff --git a/foo.gno b/foo.gno\n" +
+		"--- a/foo.gno\n" +
+		"+++ b/foo.gno\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" package foo\n" +
+		"-// foo\n" +
+		"\\ No newline at end of file\n" +
+		"+// foo\n"
+
+	assert.Equal(t, expected, diffPackages(version("package foo\n// foo"), version("package foo\n// foo\n")))
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// defaultJoinMaxSize is the size below which contiguous backup files are joined
+const defaultJoinMaxSize = 100 * 1024
+
+var errInvalidJoinMaxSize = errors.New("invalid join max size")
+
+// backupNameRegex matches the names of the uncompressed backup files
+var backupNameRegex = regexp.MustCompile(`^backup_\d+-\d+\.jsonl$`)
+
+// Define join config
+type joinCfg struct {
+	chainDir string
+	maxSize  int64
+	dryRun   bool
+}
+
+// newJoinCmd creates the backup join command
+func newJoinCmd() *ffcli.Command {
+	var (
+		cfg = &joinCfg{}
+		fs  = flag.NewFlagSet("join", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "join",
+		ShortUsage: "join [flags]",
+		ShortHelp:  "joins the small contiguous backup files, and reports the block coverage",
+		LongHelp:   "Joins the consecutive backup files whose block ranges are contiguous while their joined size stays under the max size, and reports the block gaps and overlaps of the backup files",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execJoin(ctx, cfg, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the join command flag set
+func (c *joinCfg) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(
+		&c.chainDir,
+		"chain-dir",
+		".",
+		"the chain directory holding the backup files",
+	)
+
+	fs.Int64Var(
+		&c.maxSize,
+		"max-size",
+		defaultJoinMaxSize,
+		"the size in bytes joined files must stay under",
+	)
+
+	fs.BoolVar(
+		&c.dryRun,
+		"dry-run",
+		false,
+		"flag indicating if the joins should only be reported, without being written",
+	)
+}
+
+// execJoin runs the backup join
+func execJoin(ctx context.Context, cfg *joinCfg, stdout io.Writer) error {
+	// Check the max size is valid
+	if cfg.maxSize <= 0 {
+		return errInvalidJoinMaxSize
+	}
+
+	if info, err := os.Stat(cfg.chainDir); err != nil || !info.IsDir() {
+		return errInvalidChainDir
+	}
+
+	files, err := listBackupFiles(cfg.chainDir)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+
+	for _, group := range planJoins(files, cfg.maxSize) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		joined := group.joined(cfg.chainDir)
+
+		// A file already covering the joined range overlaps the group files,
+		// and is left for the coverage report rather than overwritten
+		if slices.ContainsFunc(files, func(file backupFile) bool {
+			return file.path == joined.path
+		}) {
+			continue
+		}
+
+		fmt.Fprintf(
+			&b,
+			"joined %s -> %s (%d bytes)\n",
+			strings.Join(group.names(), ", "),
+			joined.name(),
+			joined.size,
+		)
+
+		if cfg.dryRun {
+			continue
+		}
+
+		if err := joinFiles(group, joined); err != nil {
+			return err
+		}
+
+		files = group.replace(files, joined)
+	}
+
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+
+	coverage, err := analyzeCoverage(files)
+	if err != nil {
+		return err
+	}
+
+	b.WriteString(coverage.render())
+
+	if _, err := io.WriteString(stdout, b.String()); err != nil {
+		return fmt.Errorf("unable to write coverage report, %w", err)
+	}
+
+	return nil
+}
+
+// backupFile is a backup file of the chain directory, with its block range
+type backupFile struct {
+	blockRange
+
+	path string
+	size int64
+}
+
+// name returns the file name of the backup file
+func (f backupFile) name() string {
+	return filepath.Base(f.path)
+}
+
+// listBackupFiles lists the uncompressed backup files of the chain directory,
+// sorted by block range. Files that don't follow the backup_<from>-<to>.jsonl
+// naming (ie the archives of the legacy chains) are ignored
+func listBackupFiles(chainDir string) ([]backupFile, error) {
+	entries, err := os.ReadDir(chainDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read chain dir, %w", err)
+	}
+
+	var files []backupFile
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !backupNameRegex.MatchString(entry.Name()) {
+			continue
+		}
+
+		r, ok := blockRangeFromPath(entry.Name())
+		if !ok {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("unable to stat %s, %w", entry.Name(), err)
+		}
+
+		files = append(files, backupFile{
+			blockRange: r,
+			path:       filepath.Join(chainDir, entry.Name()),
+			size:       info.Size(),
+		})
+	}
+
+	slices.SortFunc(files, compareBackupFiles)
+
+	return files, nil
+}
+
+// compareBackupFiles orders the backup files by block range
+func compareBackupFiles(a, b backupFile) int {
+	if a.from != b.from {
+		if a.from < b.from {
+			return -1
+		}
+
+		return 1
+	}
+
+	if a.to != b.to {
+		if a.to < b.to {
+			return -1
+		}
+
+		return 1
+	}
+
+	return strings.Compare(a.path, b.path)
+}
+
+// joinGroup is a run of consecutive backup files joined into a single one
+type joinGroup []backupFile
+
+// planJoins groups the consecutive backup files to join. A file is joined to the
+// previous one only if its range directly follows it, so that a coverage gap
+// or an overlap stays visible in the file names, and if their joined size
+// stays under the max size
+func planJoins(files []backupFile, maxSize int64) []joinGroup {
+	var (
+		groups  []joinGroup
+		current joinGroup
+		size    int64
+	)
+
+	flush := func() {
+		if len(current) > 1 {
+			groups = append(groups, current)
+		}
+	}
+
+	for _, file := range files {
+		if len(current) > 0 {
+			last := current[len(current)-1]
+
+			if file.from == last.to+1 && size+file.size < maxSize {
+				current = append(current, file)
+				size += file.size
+
+				continue
+			}
+		}
+
+		flush()
+
+		current = joinGroup{file}
+		size = file.size
+	}
+
+	flush()
+
+	return groups
+}
+
+// names returns the file names of the group files
+func (g joinGroup) names() []string {
+	names := make([]string, 0, len(g))
+
+	for _, file := range g {
+		names = append(names, file.name())
+	}
+
+	return names
+}
+
+// joined returns the backup file the group files are joined into
+func (g joinGroup) joined(chainDir string) backupFile {
+	joined := backupFile{
+		blockRange: blockRange{
+			from: g[0].from,
+			to:   g[len(g)-1].to,
+		},
+	}
+
+	joined.path = filepath.Join(chainDir, backupFileName(joined.from, joined.to))
+
+	for _, file := range g {
+		joined.size += file.size
+	}
+
+	return joined
+}
+
+// replace replaces the group files by the joined file in the file list
+func (g joinGroup) replace(files []backupFile, joined backupFile) []backupFile {
+	replaced := slices.DeleteFunc(slices.Clone(files), func(file backupFile) bool {
+		return slices.ContainsFunc(g, func(grouped backupFile) bool {
+			return grouped.path == file.path
+		})
+	})
+
+	replaced = append(replaced, joined)
+	slices.SortFunc(replaced, compareBackupFiles)
+
+	return replaced
+}
+
+// joinFiles concatenates the group files into the joined file, written
+// atomically, and removes the group files once it is in place
+func joinFiles(group joinGroup, joined backupFile) error {
+	out, err := createAtomic(joined.path)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range group {
+		if err := appendFile(out, file.path); err != nil {
+			out.abort()
+
+			return err
+		}
+	}
+
+	if err := out.commit(); err != nil {
+		return err
+	}
+
+	for _, file := range group {
+		if err := os.Remove(file.path); err != nil {
+			return fmt.Errorf("unable to remove joined file %s, %w", file.name(), err)
+		}
+	}
+
+	return nil
+}
+
+// appendFile appends the content of the file at the given path to the writer
+func appendFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s, %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("unable to copy %s, %w", path, err)
+	}
+
+	return nil
+}
+
+// blockCoverage is the block coverage of the backup files of a chain directory
+type blockCoverage struct {
+	files    []backupFile
+	gaps     []coverageGap
+	overlaps []coverageOverlap
+}
+
+// coverageGap is a block range no backup file covers
+type coverageGap struct {
+	blockRange
+
+	before, after string // names of the surrounding files, if any
+}
+
+// coverageOverlap is a block range covered by two backup files
+type coverageOverlap struct {
+	blockRange
+
+	first, second string
+	duplicates    int // number of tx lines found in both files
+}
+
+// analyzeCoverage finds the gaps and overlaps of the sorted backup files,
+// starting from the first block. Overlapping files are compared line by line,
+// to count the txs backed up twice
+func analyzeCoverage(files []backupFile) (blockCoverage, error) {
+	var (
+		coverage = blockCoverage{files: files}
+		covered  uint64 // the last covered block
+		previous string // the file covering the last covered block
+	)
+
+	for i, file := range files {
+		if file.from > covered+1 {
+			coverage.gaps = append(coverage.gaps, coverageGap{
+				blockRange: blockRange{from: covered + 1, to: file.from - 1},
+				before:     previous,
+				after:      file.name(),
+			})
+		}
+
+		for _, earlier := range files[:i] {
+			if earlier.to < file.from {
+				continue
+			}
+
+			duplicates, err := countDuplicateLines(earlier.path, file.path)
+			if err != nil {
+				return blockCoverage{}, err
+			}
+
+			coverage.overlaps = append(coverage.overlaps, coverageOverlap{
+				blockRange: blockRange{from: file.from, to: min(earlier.to, file.to)},
+				first:      earlier.name(),
+				second:     file.name(),
+				duplicates: duplicates,
+			})
+		}
+
+		if file.to > covered {
+			covered = file.to
+			previous = file.name()
+		}
+	}
+
+	return coverage, nil
+}
+
+// countDuplicateLines counts the non-empty lines of the second file
+// that are also lines of the first file
+func countDuplicateLines(first, second string) (int, error) {
+	lines := make(map[[sha256.Size]byte]struct{})
+
+	err := scanFileLines(first, func(line []byte) {
+		lines[sha256.Sum256(line)] = struct{}{}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var duplicates int
+
+	err = scanFileLines(second, func(line []byte) {
+		if _, ok := lines[sha256.Sum256(line)]; ok {
+			duplicates++
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return duplicates, nil
+}
+
+// scanFileLines calls the callback with every non-empty line of the file
+func scanFileLines(path string, callback func(line []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s, %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if line = trimLineEnding(line); len(line) > 0 {
+			callback(line)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to read %s, %w", path, err)
+		}
+	}
+}
+
+// trimLineEnding trims the trailing line ending of the line
+func trimLineEnding(line []byte) []byte {
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+
+	return line
+}
+
+// covered returns the last block covered by the backup files
+func (c blockCoverage) covered() uint64 {
+	var covered uint64
+
+	for _, file := range c.files {
+		covered = max(covered, file.to)
+	}
+
+	return covered
+}
+
+// expectLatest records the blocks after the backup files,
+// up to the given latest block, as a gap
+func (c *blockCoverage) expectLatest(latest uint64) {
+	covered := c.covered()
+	if covered >= latest {
+		return
+	}
+
+	gap := coverageGap{
+		blockRange: blockRange{from: covered + 1, to: latest},
+	}
+
+	for _, file := range c.files {
+		if file.to == covered {
+			gap.before = file.name()
+
+			break
+		}
+	}
+
+	c.gaps = append(c.gaps, gap)
+}
+
+// render renders the coverage report
+func (c blockCoverage) render() string {
+	var b strings.Builder
+
+	if len(c.files) == 0 {
+		b.WriteString("coverage: no backup files\n")
+
+		if len(c.gaps) == 0 {
+			return b.String()
+		}
+	} else {
+		fmt.Fprintf(&b, "coverage: blocks %d-%d, %d files\n", c.files[0].from, c.covered(), len(c.files))
+	}
+
+	fmt.Fprintf(&b, "gaps: %d\n", len(c.gaps))
+
+	for _, gap := range c.gaps {
+		fmt.Fprintf(&b, "  blocks %d-%d missing", gap.from, gap.to)
+
+		switch {
+		case gap.before != "" && gap.after != "":
+			fmt.Fprintf(&b, ", between %s and %s\n", gap.before, gap.after)
+		case gap.after != "":
+			fmt.Fprintf(&b, ", before %s\n", gap.after)
+		case gap.before != "":
+			fmt.Fprintf(&b, ", after %s\n", gap.before)
+		default:
+			b.WriteString("\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "overlaps: %d\n", len(c.overlaps))
+
+	for _, overlap := range c.overlaps {
+		fmt.Fprintf(
+			&b,
+			"  blocks %d-%d in %s and %s, %d duplicate txs\n",
+			overlap.from,
+			overlap.to,
+			overlap.first,
+			overlap.second,
+			overlap.duplicates,
+		)
+	}
+
+	return b.String()
+}
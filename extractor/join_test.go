@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBackupFiles writes the backup files to a new chain directory
+func writeBackupFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	return dir
+}
+
+func TestJoin(t *testing.T) {
+	t.Parallel()
+
+	join := func(t *testing.T, cfg *joinCfg) string {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		if cfg.maxSize == 0 {
+			cfg.maxSize = 20
+		}
+
+		var out bytes.Buffer
+
+		require.NoError(t, execJoin(ctx, cfg, &out))
+
+		return out.String()
+	}
+
+	t.Run("contiguous files", func(t *testing.T) {
+		t.Parallel()
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_0000001-0000010.jsonl": "a\n",
+			"backup_0000011-0000020.jsonl": "b\n",
+			"backup_0000021-0000030.jsonl": "",
+			"backup_0000031-0000040.jsonl": "0123456789abcdefghi\n",
+			"backup_0000041-0000050.jsonl": "c\n",
+			"backup_0000051-0000060.jsonl": "d\n",
+			"metadata.json":                "{}",
+		})
+
+		expected := "joined backup_0000001-0000010.jsonl, backup_0000011-0000020.jsonl, backup_0000021-0000030.jsonl -> backup_0000001-0000030.jsonl (4 bytes)\n" +
+			"joined backup_0000041-0000050.jsonl, backup_0000051-0000060.jsonl -> backup_0000041-0000060.jsonl (4 bytes)\n" +
+			"\n" +
+			"coverage: blocks 1-60, 3 files\n" +
+			"gaps: 0\n" +
+			"overlaps: 0\n"
+
+		assert.Equal(t, expected, join(t, &joinCfg{chainDir: chainDir}))
+
+		assert.Equal(t, map[string]string{
+			"./":                           "",
+			"backup_0000001-0000030.jsonl": "a\nb\n",
+			"backup_0000031-0000040.jsonl": "0123456789abcdefghi\n",
+			"backup_0000041-0000060.jsonl": "c\nd\n",
+			"metadata.json":                "{}",
+		}, readTree(t, chainDir))
+	})
+
+	t.Run("gaps and overlaps", func(t *testing.T) {
+		t.Parallel()
+
+		files := map[string]string{
+			"backup_0000005-0000010.jsonl": "a\n",
+			"backup_0000010-0000020.jsonl": "b\n",
+			"backup_0000026-0000030.jsonl": "c\nd\n",
+			"backup_0000028-0000029.jsonl": "d\n",
+			"backup_0000031-0000040.jsonl": "e\n",
+		}
+
+		chainDir := writeBackupFiles(t, files)
+
+		// Files are only joined to the file sorted right before them
+		expected := "coverage: blocks 5-40, 5 files\n" +
+			"gaps: 2\n" +
+			"  blocks 1-4 missing, before backup_0000005-0000010.jsonl\n" +
+			"  blocks 21-25 missing, between backup_0000010-0000020.jsonl and backup_0000026-0000030.jsonl\n" +
+			"overlaps: 2\n" +
+			"  blocks 10-10 in backup_0000005-0000010.jsonl and backup_0000010-0000020.jsonl, 0 duplicate txs\n" +
+			"  blocks 28-29 in backup_0000026-0000030.jsonl and backup_0000028-0000029.jsonl, 1 duplicate txs\n"
+
+		assert.Equal(t, expected, join(t, &joinCfg{chainDir: chainDir}))
+
+		files["./"] = ""
+		assert.Equal(t, files, readTree(t, chainDir))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		t.Parallel()
+
+		files := map[string]string{
+			"backup_0000001-0000010.jsonl": "a\n",
+			"backup_0000011-0000020.jsonl": "b\n",
+		}
+
+		chainDir := writeBackupFiles(t, files)
+
+		out := join(t, &joinCfg{chainDir: chainDir, dryRun: true})
+
+		assert.True(t, strings.HasPrefix(out, "joined backup_0000001-0000010.jsonl, backup_0000011-0000020.jsonl -> backup_0000001-0000020.jsonl (4 bytes)\n"))
+		assert.Contains(t, out, "coverage: blocks 1-20, 2 files\n")
+
+		files["./"] = ""
+		assert.Equal(t, files, readTree(t, chainDir))
+	})
+
+	t.Run("joined file exists", func(t *testing.T) {
+		t.Parallel()
+
+		files := map[string]string{
+			"backup_0000001-0000010.jsonl": "a\n",
+			"backup_0000011-0000020.jsonl": "b\n",
+			"backup_0000001-0000020.jsonl": "a\nb\n",
+		}
+
+		chainDir := writeBackupFiles(t, files)
+
+		out := join(t, &joinCfg{chainDir: chainDir})
+
+		assert.True(t, strings.HasPrefix(out, "coverage: blocks 1-20, 3 files\n"))
+		assert.Contains(t, out, "overlaps: 2\n")
+
+		files["./"] = ""
+		assert.Equal(t, files, readTree(t, chainDir))
+	})
+
+	t.Run("no backup files", func(t *testing.T) {
+		t.Parallel()
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"txexport-aa.log":                    "a\n",
+			"backup_0000001-0000010.jsonl.gz":    "a\n",
+			"backup_staging_txs_1-1000.jsonl":    "a\n",
+			"backup_staging_balances.jsonl":      "a\n",
+			"backup_0000011-0000001.jsonl":       "a\n",
+			"backup_0000011-0000020.jsonl.jsonl": "a\n",
+		})
+
+		assert.Equal(t, "coverage: no backup files\n", join(t, &joinCfg{chainDir: chainDir}))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		chainDir := t.TempDir()
+
+		assert.ErrorIs(t, execJoin(context.Background(), &joinCfg{chainDir: chainDir, maxSize: -1}, &bytes.Buffer{}), errInvalidJoinMaxSize)
+		assert.ErrorIs(t, execJoin(context.Background(), &joinCfg{
+			chainDir: filepath.Join(chainDir, "missing"),
+			maxSize:  defaultJoinMaxSize,
+		}, &bytes.Buffer{}), errInvalidChainDir)
+	})
+}
+
+func TestPlanJoins(t *testing.T) {
+	t.Parallel()
+
+	file := func(from, to uint64, size int64) backupFile {
+		return backupFile{
+			blockRange: blockRange{from: from, to: to},
+			path:       backupFileName(from, to),
+			size:       size,
+		}
+	}
+
+	testTable := []struct {
+		name     string
+		files    []backupFile
+		expected []joinGroup
+	}{
+		{
+			"contiguous",
+			[]backupFile{file(1, 10, 1), file(11, 20, 1), file(21, 30, 1)},
+			[]joinGroup{{file(1, 10, 1), file(11, 20, 1), file(21, 30, 1)}},
+		},
+		{
+			"size threshold",
+			[]backupFile{file(1, 10, 4), file(11, 20, 5), file(21, 30, 1), file(31, 40, 1)},
+			[]joinGroup{{file(1, 10, 4), file(11, 20, 5)}, {file(21, 30, 1), file(31, 40, 1)}},
+		},
+		{
+			"big file",
+			[]backupFile{file(1, 10, 1), file(11, 20, 10), file(21, 30, 1)},
+			nil,
+		},
+		{
+			"gap",
+			[]backupFile{file(1, 10, 1), file(12, 20, 1)},
+			nil,
+		},
+		{
+			"shared block",
+			[]backupFile{file(300001, 368926, 1), file(368926, 3105231, 1)},
+			nil,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, planJoins(testCase.files, 10))
+		})
+	}
+}
@@ -1,21 +1,20 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"io"
+	"iter"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
 
-	"github.com/gnolang/gno/gno.land/pkg/gnoland"
 	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/gnovm/pkg/gnomod"
 	"github.com/gnolang/gno/tm2/pkg/amino"
 	"github.com/gnolang/gno/tm2/pkg/std"
 	"github.com/peterbourgon/ff/v3/ffcli"
@@ -31,15 +30,21 @@ var (
 	errInvalidSourceDir   = errors.New("invalid source directory")
 	errInvalidOutputDir   = errors.New("invalid output directory")
 	errNoSourceFilesFound = errors.New("no source files found, exiting")
+	errInvalidWorkers     = errors.New("invalid number of workers")
 )
 
 // Define extractor config
 type extractorCfg struct {
-	fileType   string
-	sourcePath string
-	outputDir  string
+	outputDir string
 
-	legacyMode bool
+	legacyMode  bool // deprecated, ignored
+	includeRun  bool
+	workers     int
+	incremental bool
+	genModules  bool
+
+	sourceCfg
+	rejectsCfg
 }
 
 func main() {
@@ -56,6 +61,21 @@ func main() {
 		ShortUsage: "[flags]",
 		LongHelp:   "The Gno / TM2 source code extractor service",
 		FlagSet:    fs,
+		Subcommands: []*ffcli.Command{
+			newCallsCmd(),
+			newStatsCmd(),
+			newDepsCmd(),
+			newHistoryCmd(),
+			newFetchCmd(),
+			newJoinCmd(),
+			newVerifyCmd(),
+			newManifestCmd(),
+			newVerifyManifestCmd(),
+			newGenesisExportCmd(),
+			newBuildGenesisCmd(),
+			newBalancesCmd(),
+			newTransfersCmd(),
+		},
 		Exec: func(ctx context.Context, _ []string) error {
 			return execExtract(ctx, cfg)
 		},
@@ -71,19 +91,7 @@ func main() {
 
 // registerFlags registers the extractor service flag set
 func (c *extractorCfg) registerFlags(fs *flag.FlagSet) {
-	fs.StringVar(
-		&c.fileType,
-		"file-type",
-		".jsonl",
-		"the file type for analysis, with a preceding period (ie .jsonl)",
-	)
-
-	fs.StringVar(
-		&c.sourcePath,
-		"source-path",
-		"",
-		"the source file or folder containing transaction data",
-	)
+	c.sourceCfg.registerFlags(fs)
 
 	fs.StringVar(
 		&c.outputDir,
@@ -96,20 +104,45 @@ func (c *extractorCfg) registerFlags(fs *flag.FlagSet) {
 		&c.legacyMode,
 		"legacy-mode",
 		false,
-		"flag indicating if the legacy tx sheet mode should be used",
+		"deprecated, the tx sheet format is detected automatically for every line",
+	)
+
+	fs.BoolVar(
+		&c.includeRun,
+		"include-run",
+		false,
+		"flag indicating if the MsgRun packages should also be extracted",
+	)
+
+	fs.IntVar(
+		&c.workers,
+		"workers",
+		1,
+		"the number of source files decoded concurrently (the output does not depend on it)",
+	)
+
+	fs.BoolVar(
+		&c.incremental,
+		"incremental",
+		false,
+		"flag indicating if only the source content added since the last extraction should be extracted",
+	)
+
+	fs.BoolVar(
+		&c.genModules,
+		"gen-modules",
+		false,
+		"flag indicating if a gnomod.toml should be generated for packages deployed without one, pinning their imports to the extracted versions",
 	)
+
+	c.rejectsCfg.registerFlags(fs)
 }
 
 // execExtract runs the extract service for Gno source code
 func execExtract(ctx context.Context, cfg *extractorCfg) error {
-	// Check the file type is valid
-	if cfg.fileType == "" {
-		return errInvalidFileType
-	}
-
-	// Check the source dir is valid
-	if cfg.sourcePath == "" {
-		return errInvalidSourceDir
+	// Check the source is valid
+	if err := cfg.sourceCfg.validate(); err != nil {
+		return err
 	}
 
 	// Check the output dir is valid
@@ -117,111 +150,194 @@ func execExtract(ctx context.Context, cfg *extractorCfg) error {
 		return errInvalidOutputDir
 	}
 
-	// Check if source is valid
-	source, err := os.Stat(cfg.sourcePath)
+	// Check the number of workers is valid
+	if cfg.workers < 0 {
+		return errInvalidWorkers
+	}
+
+	sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
 	if err != nil {
-		return fmt.Errorf("unable to stat source path, %w", err)
+		return err
 	}
 
-	var sourceFiles []string
-	sourceFiles = append(sourceFiles, cfg.sourcePath)
+	// Extract the MsgRun packages only when requested
+	msgTypes := []string{msgTypeAddPackage}
+	if cfg.includeRun {
+		msgTypes = append(msgTypes, msgTypeRun)
+	}
+
+	rejects, err := newRejectHandler(cfg.rejectsCfg)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
 
-	// If source is dir, walk it and add to sourceFiles
-	if source.IsDir() {
-		var findErr error
-		sourceFiles, findErr = findFilePaths(cfg.sourcePath, cfg.fileType)
-		if findErr != nil {
-			return fmt.Errorf("unable to find file paths, %w", findErr)
+	// Find where the previous extraction stopped, if needed
+	var (
+		start    *resumePosition
+		previous *ExtractorState
+	)
+
+	if cfg.incremental {
+		previous, start, err = resumeExtraction(cfg.outputDir, cfg.sourcePath, sourceFiles, cfg.includeRun, cfg.genModules)
+		if err != nil {
+			return err
 		}
 	}
 
-	if len(sourceFiles) == 0 {
-		return errNoSourceFilesFound
+	// The state is only valid once the extraction is over
+	if err := removeExtractorState(cfg.outputDir); err != nil {
+		return err
 	}
 
 	var (
-		unwrapFn = func(data gnoland.TxWithMetadata) []std.Msg {
-			return data.Tx.Msgs
-		}
+		// Package versions are tracked across all source files
+		versions = newVersionStore(cfg.outputDir)
 
-		heightFn = func(_ gnoland.TxWithMetadata) uint64 {
-			return 0
-		}
+		// Source files left to extract
+		pending = sourceFiles
 
-		unwrapLegacyFn = func(tx std.Tx) []std.Msg {
-			return tx.Msgs
+		// Number of packages rejected by validation
+		rejected int
+
+		// The tx counters at the end of the last source file
+		end txCounters
+	)
+
+	versions.modules = cfg.genModules
+
+	if start != nil {
+		versions.incremental = true
+		pending = sourceFiles[start.fileIndex:]
+		end = previous.counters()
+	}
+
+	// Source files are decoded concurrently, but their messages are
+	// processed in (file, line) order, so the package versions are
+	// numbered the same way whatever the number of workers
+	files := orderedFiles(ctx, pending, cfg.workers, func(sourceFile string) iter.Seq2[TxMessage, error] {
+		var (
+			from  resumePosition
+			endFn func(txCounters)
+		)
+
+		if start != nil && sourceFile == pending[0] {
+			from = *start
 		}
 
-		heightLegacyFn = func(_ std.Tx) uint64 {
-			return 0
+		if sourceFile == pending[len(pending)-1] {
+			endFn = func(counters txCounters) {
+				end = counters
+			}
 		}
-	)
 
-	for _, sourceFile := range sourceFiles {
+		return fileMessagesFrom(sourceFile, from, endFn, rejects, msgTypes...)
+	})
+
+	for sourceFile, msgs := range files {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			sourceFile := sourceFile
-
-			// Extract messages
-			var (
-				msgs       []AddPackage
-				processErr error
-			)
-
-			if !cfg.legacyMode {
-				msgs, processErr = extractAddMessages(
-					sourceFile,
-					unwrapFn,
-					heightFn,
-				)
-			} else {
-				msgs, processErr = extractAddMessages(
-					sourceFile,
-					unwrapLegacyFn,
-					heightLegacyFn,
-				)
-			}
+			// Process the messages as they are extracted
+			for txMsg, processErr := range msgs {
+				if processErr != nil {
+					return processErr
+				}
 
-			if processErr != nil {
-				return processErr
-			}
+				if txMsg.Msg.Type() == msgTypeRun {
+					run, castErr := runPackageFromMsg(txMsg, sourceFile)
+					if castErr != nil {
+						return castErr
+					}
 
-			// Process messages
-			for _, msg := range msgs {
-				outputDir := filepath.Join(cfg.outputDir, strings.TrimLeft(msg.Package.Path, "gno.land/"))
+					// Chain data is untrusted, make sure
+					// nothing is written outside the output dir
+					if validateErr := validateFiles(run.Package.Files); validateErr != nil {
+						slog.Error(
+							"skipping invalid run package",
+							"file", sourceFile,
+							"height", run.Height,
+							"error", validateErr,
+						)
+
+						rejected++
+
+						continue
+					}
 
-				if !cfg.legacyMode {
-					if st, err := os.Stat(outputDir); err == nil && st.IsDir() {
-						outputDir += ":" + strconv.FormatUint(msg.Height, 10)
+					if writeErr := writeRunPackage(run, cfg.outputDir); writeErr != nil {
+						return writeErr
 					}
+
+					continue
 				}
 
-				// Write dir before writing files
-				if dirWriteErr := os.MkdirAll(outputDir, os.ModePerm); dirWriteErr != nil {
-					return fmt.Errorf("unable to write dir, %w", dirWriteErr)
+				msg, castErr := addPackageFromMsg(txMsg)
+				if castErr != nil {
+					return castErr
 				}
 
-				// Write the package source code
-				if writeErr := writePackageFiles(msg, outputDir); writeErr != nil {
-					return writeErr
+				// Chain data is untrusted, make sure
+				// nothing is written outside the output dir
+				if validateErr := validatePackage(msg); validateErr != nil {
+					slog.Error(
+						"skipping invalid package",
+						"file", sourceFile,
+						"height", msg.Height,
+						"error", validateErr,
+					)
+
+					rejected++
+
+					continue
 				}
 
-				// Write the package metadata
-				if writeErr := writePackageMetadata(metadataFromMsg(msg), outputDir); writeErr != nil {
+				outputDir, dirErr := packageDir(cfg.outputDir, msg.Package.Path)
+				if dirErr != nil {
+					return dirErr
+				}
+
+				if writeErr := versions.write(msg, outputDir); writeErr != nil {
 					return writeErr
 				}
 			}
 		}
 	}
 
-	return nil
+	if rejected > 0 {
+		slog.Warn("invalid packages were not extracted", "count", rejected)
+	}
+
+	// Point the generated module files at the final version dirs
+	if err := versions.linkModules(); err != nil {
+		return err
+	}
+
+	// Save the extraction checkpoint, for the next incremental run
+	state, err := newExtractorState(cfg.sourcePath, sourceFiles, cfg.includeRun, cfg.genModules, previous)
+	if err != nil {
+		return err
+	}
+
+	state.LastHeight = end.lastHeight
+	state.TxIndex = end.txIndex
+
+	if err := writeExtractorState(state, cfg.outputDir); err != nil {
+		return err
+	}
+
+	return rejects.close()
 }
 
-// writePackageFiles writes all files from a single package to the output directory
-func writePackageFiles(msg AddPackage, outputDir string) error {
+// writePackageFiles writes all files from a single package to the output directory,
+// along with the given module file when the package has none of its own
+func writePackageFiles(msg AddPackage, outputDir string, module *gnomod.File) error {
 	for _, file := range msg.Package.Files {
+		if err := validateFileName(file.Name); err != nil {
+			return err
+		}
+
 		// Get the output path
 		writePath := filepath.Join(outputDir, file.Name)
 
@@ -230,11 +346,15 @@ func writePackageFiles(msg AddPackage, outputDir string) error {
 		}
 	}
 
+	if module != nil {
+		return writeModuleFile(module, outputDir)
+	}
+
 	return nil
 }
 
 // writePackageMetadata writes the package metadata to the output directory
-func writePackageMetadata(metadata Metadata, outputDir string) error {
+func writePackageMetadata(metadata any, outputDir string) error {
 	// Get the output path
 	writePath := filepath.Join(outputDir, packageMetadataFile)
 
@@ -251,103 +371,141 @@ func writePackageMetadata(metadata Metadata, outputDir string) error {
 	return nil
 }
 
-// AddPackage contains a vm.MsgAddPackage, together with the block height where it appeared.
+// Define the message types the extractor handles
+const (
+	msgTypeAddPackage = "add_package"
+	msgTypeRun        = "run"
+	msgTypeCall       = "exec"
+	msgTypeSend       = "send"
+)
+
+// AddPackage contains a vm.MsgAddPackage, together with the transaction it belongs to
+// and the block height where it appeared.
 type AddPackage struct {
 	vm.MsgAddPackage
-	Height uint64
+	Height       uint64
+	HeightSource heightSource // where the block height comes from
+
+	Tx        std.Tx
+	TxHash    string // the base64 encoded SHA-256 of the amino encoded tx
+	Timestamp int64  // the block timestamp of the transaction (unix seconds), if known
+	TxIndex   int    // the index of the transaction within its block height
+	MsgIndex  int    // the index of the message within its transaction
 }
 
-// extractAddMessages extracts the AddPackage messages
-func extractAddMessages[T std.Tx | gnoland.TxWithMetadata](
-	filePath string,
-	unwrapFn func(T) []std.Msg,
-	heightFn func(T) uint64,
-) ([]AddPackage, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("unable to open file, %w", err)
-	}
+// TxMessage contains a single transaction message, together with the transaction it belongs to
+type TxMessage struct {
+	Msg          std.Msg
+	Tx           std.Tx
+	Height       uint64       // the block height of the transaction
+	HeightSource heightSource // where the block height comes from
+	Timestamp    int64        // the block timestamp of the transaction (unix seconds), if known
+	TxIndex      int          // the index of the transaction within its block height
+	MsgIndex     int          // the index of the message within its transaction
+}
 
-	cleanup := func() error {
-		if closeErr := file.Close(); closeErr != nil {
-			return fmt.Errorf("unable to gracefully close file, %w", closeErr)
-		}
-		return nil
+// addPackageFromMsg converts the transaction message into an AddPackage
+func addPackageFromMsg(txMsg TxMessage) (AddPackage, error) {
+	msgAddPkg, ok := txMsg.Msg.(vm.MsgAddPackage)
+	if !ok {
+		return AddPackage{}, errors.New("could not cast into MsgAddPackage")
 	}
 
-	reader := bufio.NewReader(file)
-
-	// Msg array to be returned for further processing
-	msgArr := make([]AddPackage, 0)
+	if msgAddPkg.Package == nil {
+		return AddPackage{}, errors.New("MsgAddPackage is nil")
+	}
 
-	// Buffer to handle lines longer than 64kb
-	tempBuf := make([]byte, 0)
+	hash, err := txHash(txMsg.Tx)
+	if err != nil {
+		return AddPackage{}, err
+	}
 
-	for {
-		var txData T
+	return AddPackage{
+		MsgAddPackage: msgAddPkg,
+		Height:        txMsg.Height,
+		HeightSource:  txMsg.HeightSource,
+		Tx:            txMsg.Tx,
+		TxHash:        hash,
+		Timestamp:     txMsg.Timestamp,
+		TxIndex:       txMsg.TxIndex,
+		MsgIndex:      txMsg.MsgIndex,
+	}, nil
+}
 
-		line, isPrefix, err := reader.ReadLine()
+// txHash returns the transaction hash, the base64 encoded SHA-256
+// of the amino (binary) encoded transaction, as broadcast to the chain
+func txHash(tx std.Tx) (string, error) {
+	raw, err := amino.Marshal(tx)
+	if err != nil {
+		return "", fmt.Errorf("unable to amino marshal tx, %w", err)
+	}
 
-		// Exit if no more lines in file
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("error reading lines; %w", err)
-		}
+	hash := sha256.Sum256(raw)
 
-		// If line is too long, save it in a temporary buffer and continue reading line
-		if isPrefix {
-			tempBuf = append(tempBuf, line...)
-			continue
-		}
+	return base64.StdEncoding.EncodeToString(hash[:]), nil
+}
 
-		// Handle long lines
-		if len(tempBuf) != 0 {
-			// Append last part of line to temporary buffer
-			tempBuf = append(tempBuf, line...)
+// extractAddMessages extracts the AddPackage messages
+func extractAddMessages[T archiveTx](
+	filePath string,
+	unwrapFn func(T) std.Tx,
+	heightFn func(T) uint64,
+) ([]AddPackage, error) {
+	unwrapper := txUnwrapper[T]{
+		txFn:     unwrapFn,
+		heightFn: heightFn,
+	}
 
-			// Use line variable to pass it on to amino
-			line = tempBuf
-		}
+	txMsgs, err := extractMessages(filePath, unwrapper.decode, nil, msgTypeAddPackage)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := amino.UnmarshalJSON(line, &txData); err != nil {
-			slog.Error("error while parsing amino JSON", "error", err, "line", line)
-			continue
-		}
+	// Msg array to be returned for further processing
+	msgArr := make([]AddPackage, 0, len(txMsgs))
 
-		// Reset tempBuf in case it was used for a long line
-		if tempBuf != nil {
-			tempBuf = nil
+	for _, txMsg := range txMsgs {
+		msg, err := addPackageFromMsg(txMsg)
+		if err != nil {
+			return nil, err
 		}
 
-		for _, msg := range unwrapFn(txData) {
-			// Only MsgAddPkg should be parsed
-			if msg.Type() != "add_package" {
-				continue
-			}
+		msgArr = append(msgArr, msg)
+	}
 
-			msgAddPkg, ok := msg.(vm.MsgAddPackage)
-			if !ok {
-				return nil, errors.New("could not cast into MsgAddPackage")
-			}
+	return msgArr, nil
+}
 
-			if msgAddPkg.Package == nil {
-				return nil, errors.New("MsgAddPackage is nil")
-			}
+// extractMessages extracts the transaction messages of the given types,
+// or all transaction messages if no type is given.
+// Lines that cannot be decoded are passed to the reject function (logged if nil),
+// which can abort the extraction by returning an error
+func extractMessages(
+	filePath string,
+	decodeFn txDecodeFn,
+	rejectFn func(LineError) error,
+	msgTypes ...string,
+) ([]TxMessage, error) {
+	// Msg array to be returned for further processing
+	msgArr := make([]TxMessage, 0)
 
-			msgArr = append(msgArr, AddPackage{
-				MsgAddPackage: msgAddPkg,
-				Height:        heightFn(txData),
-			})
+	for txMsg, err := range streamFileMessages(filePath, streamOpts{
+		decodeFn: decodeFn,
+		rejectFn: rejectFn,
+		msgTypes: msgTypes,
+	}) {
+		if err != nil {
+			return nil, err
 		}
+
+		msgArr = append(msgArr, txMsg)
 	}
 
-	return msgArr, cleanup()
+	return msgArr, nil
 }
 
 // findFilePaths gathers the file paths for specific file types
-func findFilePaths(startPath string, fileType string) ([]string, error) {
+func findFilePaths(startPath string, fileTypes ...string) ([]string, error) {
 	filePaths := make([]string, 0)
 
 	walkFn := func(path string, info os.FileInfo, err error) error {
@@ -361,7 +519,7 @@ func findFilePaths(startPath string, fileType string) ([]string, error) {
 		}
 
 		// Check if the file type matches
-		if !strings.HasSuffix(info.Name(), fileType) {
+		if !matchFileType(info.Name(), fileTypes) {
 			return nil
 		}
 
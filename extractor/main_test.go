@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"context"
 	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"github.com/gnolang/gno/gno.land/pkg/gnoland"
 	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
 	"github.com/gnolang/gno/tm2/pkg/amino"
 	"github.com/gnolang/gno/tm2/pkg/crypto"
+	"github.com/gnolang/gno/tm2/pkg/crypto/secp256k1"
 	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
 	"github.com/gnolang/gno/tm2/pkg/std"
 	"github.com/stretchr/testify/assert"
@@ -35,36 +37,32 @@ func TestExtractor_Errors(t *testing.T) {
 		{
 			"no source files",
 			&extractorCfg{
-				fileType:   ".log",
-				sourcePath: "./",
-				outputDir:  ".",
+				sourceCfg: sourceCfg{fileType: ".log", sourcePath: "./"},
+				outputDir: ".",
 			},
 			errNoSourceFilesFound,
 		},
 		{
 			"invalid filetype",
 			&extractorCfg{
-				fileType:   "",
-				sourcePath: ".",
-				outputDir:  ".",
+				sourceCfg: sourceCfg{fileType: "", sourcePath: "."},
+				outputDir: ".",
 			},
 			errInvalidFileType,
 		},
 		{
 			"invalid source dir",
 			&extractorCfg{
-				fileType:   ".log",
-				sourcePath: "",
-				outputDir:  ".",
+				sourceCfg: sourceCfg{fileType: ".log", sourcePath: ""},
+				outputDir: ".",
 			},
 			errInvalidSourceDir,
 		},
 		{
 			"invalid output dir",
 			&extractorCfg{
-				fileType:   ".log",
-				sourcePath: ".",
-				outputDir:  "",
+				sourceCfg: sourceCfg{fileType: ".log", sourcePath: "."},
+				outputDir: "",
 			},
 			errInvalidOutputDir,
 		},
@@ -100,14 +98,14 @@ func TestValidFlow_Dir(t *testing.T) {
 
 	// Set correct config
 	var cfg = &extractorCfg{
-		fileType:   sourceFileType,
-		sourcePath: sourceDir,
-		outputDir:  outputDir,
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		outputDir: outputDir,
 	}
 
 	// Generate mock messages & mock files
 	mockStdMsg, mockAddPkgMsg := generateMockMsgs(t)
-	_ = generateSourceFiles(t, sourceDir, mockStdMsg, 20)
+	sourceFiles := generateSourceFiles(t, sourceDir, mockStdMsg, 20)
+	expectedMetadata := sourceMetadata(t, sourceFiles)
 
 	// Perform extraction
 	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
@@ -116,7 +114,8 @@ func TestValidFlow_Dir(t *testing.T) {
 	require.NoError(t, execExtract(ctx, cfg))
 
 	for _, msg := range mockAddPkgMsg {
-		basePath := filepath.Join(outputDir, strings.TrimLeft(msg.Package.Path, "gno.land/"))
+		basePath, err := packageDir(outputDir, msg.Package.Path)
+		require.NoError(t, err)
 
 		// Get metadata path & open metadata file
 		metadataPath := filepath.Join(basePath, packageMetadataFile)
@@ -129,8 +128,10 @@ func TestValidFlow_Dir(t *testing.T) {
 		require.NoError(t, err)
 
 		// Compare metadata
-		expectedMetadata, err := json.Marshal(metadataFromMsg(AddPackage{MsgAddPackage: msg}))
-		assert.Equal(t, expectedMetadata, retrievedMetadata)
+		var metadata Metadata
+		require.NoError(t, json.Unmarshal(retrievedMetadata, &metadata))
+
+		assert.Equal(t, expectedMetadata[msg.Package.Path], metadata)
 
 		// Close metadata file
 		require.NoError(t, file.Close())
@@ -168,14 +169,14 @@ func TestValidFlow_File(t *testing.T) {
 
 	// Set correct config
 	var cfg = &extractorCfg{
-		fileType:   sourceFileType,
-		sourcePath: sourceDir,
-		outputDir:  outputDir,
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		outputDir: outputDir,
 	}
 
 	// Generate mock messages & mock files
 	mockStdMsg, mockAddPkgMsg := generateMockMsgs(t)
-	_ = generateSourceFiles(t, sourceDir, mockStdMsg, 1)
+	sourceFiles := generateSourceFiles(t, sourceDir, mockStdMsg, 1)
+	expectedMetadata := sourceMetadata(t, sourceFiles)
 
 	// Perform extraction
 	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
@@ -184,7 +185,8 @@ func TestValidFlow_File(t *testing.T) {
 	require.NoError(t, execExtract(ctx, cfg))
 
 	for _, msg := range mockAddPkgMsg {
-		basePath := filepath.Join(outputDir, strings.TrimLeft(msg.Package.Path, "gno.land/"))
+		basePath, err := packageDir(outputDir, msg.Package.Path)
+		require.NoError(t, err)
 
 		// Get metadata path & open metadata file
 		metadataPath := filepath.Join(basePath, packageMetadataFile)
@@ -197,8 +199,10 @@ func TestValidFlow_File(t *testing.T) {
 		require.NoError(t, err)
 
 		// Compare metadata
-		expectedMetadata, err := json.Marshal(metadataFromMsg(AddPackage{MsgAddPackage: msg}))
-		assert.Equal(t, expectedMetadata, retrievedMetadata)
+		var metadata Metadata
+		require.NoError(t, json.Unmarshal(retrievedMetadata, &metadata))
+
+		assert.Equal(t, expectedMetadata[msg.Package.Path], metadata)
 
 		// Close metadata file
 		require.NoError(t, file.Close())
@@ -280,7 +284,7 @@ func TestExtractAddMessages(t *testing.T) {
 	mockMsgs, mockMsgsAddPackage := generateMockMsgs(t)
 	sourceFiles := generateSourceFiles(t, tempDir, mockMsgs, 20)
 
-	unwrapFn := func(data gnoland.TxWithMetadata) []std.Msg { return data.Tx.Msgs }
+	unwrapFn := func(data gnoland.TxWithMetadata) std.Tx { return data.Tx }
 	heightFn := func(_ gnoland.TxWithMetadata) uint64 { return 0 }
 
 	var results []vm.MsgAddPackage
@@ -316,10 +320,11 @@ func TestWritePackageMetadata(t *testing.T) {
 		md := metadataFromMsg(AddPackage{MsgAddPackage: msg})
 
 		// Get output dir
-		outputDir := filepath.Join(tempDir, strings.TrimLeft(msg.Package.Path, "gno.land/"))
+		outputDir, err := packageDir(tempDir, msg.Package.Path)
+		require.NoError(t, err)
 
 		// Write dir before writing metadata
-		err := os.MkdirAll(outputDir, os.ModePerm)
+		err = os.MkdirAll(outputDir, os.ModePerm)
 		require.NoError(t, err)
 
 		// Write the metadata
@@ -354,14 +359,15 @@ func TestWritePackageFiles(t *testing.T) {
 
 	for _, msg := range mockMsgsAddPackage {
 		// Get output dir
-		outputDir := filepath.Join(tempDir, strings.TrimLeft(msg.Package.Path, "gno.land/"))
+		outputDir, err := packageDir(tempDir, msg.Package.Path)
+		require.NoError(t, err)
 
 		// Write dir before writing metadata
-		err := os.MkdirAll(outputDir, os.ModePerm)
+		err = os.MkdirAll(outputDir, os.ModePerm)
 		require.NoError(t, err)
 
 		// Write the metadata
-		err = writePackageFiles(AddPackage{MsgAddPackage: msg}, outputDir)
+		err = writePackageFiles(AddPackage{MsgAddPackage: msg}, outputDir, nil)
 		require.NoError(t, err)
 
 		// Read & compare file
@@ -382,13 +388,32 @@ func generateSourceFiles(t *testing.T, dir string, mockMsgs []std.Msg, numSource
 		mockTx          = make([]gnoland.TxWithMetadata, txPerSourceFile*numSourceFiles)
 		testFiles       = make([]string, numSourceFiles)
 		msgPerTx        = len(mockMsgs) / len(mockTx)
+		pubKey          = secp256k1.GenPrivKey().PubKey()
 	)
 
-	// Generate transactions to wrap messages
+	// Generate transactions to wrap messages, two per block height,
+	// so that the blocks of a source file are not shared with the next one
 	for i := range mockTx {
+		var (
+			fileIndex = i / txPerSourceFile
+			height    = fileIndex*txPerSourceFile + i%txPerSourceFile/2 + 1
+		)
+
 		mockTx[i] = gnoland.TxWithMetadata{
 			Tx: std.Tx{
 				Msgs: mockMsgs[:msgPerTx],
+				Fee: std.Fee{
+					GasWanted: int64(1_000_000 + i),
+					GasFee:    std.NewCoin("ugnot", int64(1_000+i)),
+				},
+				Signatures: []std.Signature{
+					{PubKey: pubKey, Signature: []byte("signature")},
+				},
+				Memo: "tx " + strconv.Itoa(i),
+			},
+			Metadata: &gnoland.GnoTxMetadata{
+				Timestamp:   int64(1_700_000_000 + height),
+				BlockHeight: int64(height),
 			},
 		}
 		mockMsgs = mockMsgs[msgPerTx:]
@@ -425,6 +450,76 @@ func generateSourceFiles(t *testing.T, dir string, mockMsgs []std.Msg, numSource
 	return testFiles
 }
 
+// sourceMetadata reads back the source files, and returns the metadata
+// of every package deployment, by package path
+func sourceMetadata(t *testing.T, sourceFiles []string) map[string]Metadata {
+	t.Helper()
+
+	metadata := make(map[string]Metadata)
+
+	for _, sourceFile := range sourceFiles {
+		raw, err := os.ReadFile(sourceFile)
+		require.NoError(t, err)
+
+		txIndexes := make(map[int64]int)
+
+		for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+			var txData gnoland.TxWithMetadata
+			require.NoError(t, amino.UnmarshalJSON([]byte(line), &txData))
+
+			var (
+				height  = txData.Metadata.BlockHeight
+				txIndex = txIndexes[height]
+			)
+
+			txIndexes[height]++
+
+			encoded, err := amino.Marshal(txData.Tx)
+			require.NoError(t, err)
+
+			hash := sha256.Sum256(encoded)
+
+			// The single signature belongs to the first signer
+			var signers []Signer
+			for i, address := range txData.Tx.GetSigners() {
+				signer := Signer{Address: address.String()}
+				if i == 0 {
+					signer.PubKey = txData.Tx.Signatures[0].PubKey.String()
+				}
+
+				signers = append(signers, signer)
+			}
+
+			for msgIndex, msg := range txData.Tx.Msgs {
+				addPkg, ok := msg.(vm.MsgAddPackage)
+				if !ok {
+					continue
+				}
+
+				metadata[addPkg.Package.Path] = Metadata{
+					SchemaVersion: metadataSchemaVersion,
+					Creator:       addPkg.Creator.String(),
+					Deposit:       addPkg.Send.String(),
+					Send:          addPkg.Send.String(),
+					MaxDeposit:    addPkg.MaxDeposit.String(),
+					Height:        uint64(height),
+					HeightSource:  string(heightSourceBlock),
+					Timestamp:     txData.Metadata.Timestamp,
+					TxHash:        base64.StdEncoding.EncodeToString(hash[:]),
+					TxIndex:       txIndex,
+					MsgIndex:      msgIndex,
+					Memo:          txData.Tx.Memo,
+					GasWanted:     txData.Tx.Fee.GasWanted,
+					GasFee:        txData.Tx.Fee.GasFee.String(),
+					Signers:       signers,
+				}
+			}
+		}
+	}
+
+	return metadata
+}
+
 func generateMockMsgs(t *testing.T) ([]std.Msg, []vm.MsgAddPackage) {
 	t.Helper()
 
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// manifestFile is the name of the chain directory manifest
+const manifestFile = "MANIFEST.json"
+
+var errManifestDrift = errors.New("archive files drifted from the manifest")
+
+// Define manifest config
+type manifestCfg struct {
+	fileType string
+}
+
+// newManifestCmd creates the manifest generation command
+func newManifestCmd() *ffcli.Command {
+	var (
+		cfg = &manifestCfg{}
+		fs  = flag.NewFlagSet("manifest", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "manifest",
+		ShortUsage: "manifest [flags] <chaindir>",
+		ShortHelp:  "writes the manifest of the chain archive files",
+		LongHelp:   "Writes the " + manifestFile + " of the chain directory, listing the block range, line count, size, SHA-256 and first / last tx timestamp of every archive file",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return execManifest(ctx, cfg, args)
+		},
+	}
+}
+
+// newVerifyManifestCmd creates the manifest verification command
+func newVerifyManifestCmd() *ffcli.Command {
+	var (
+		cfg = &manifestCfg{}
+		fs  = flag.NewFlagSet("verify-manifest", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "verify-manifest",
+		ShortUsage: "verify-manifest [flags] <chaindir>",
+		ShortHelp:  "verifies the chain archive files match their manifest",
+		LongHelp:   "Verifies the archive files of the chain directory match its " + manifestFile + ", and reports the missing, untracked and changed files. Fails on any drift",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return execVerifyManifest(ctx, cfg, args, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the manifest commands flag set
+func (c *manifestCfg) registerFlags(fs *flag.FlagSet) {
+	registerFileTypeFlag(fs, &c.fileType)
+}
+
+// execManifest runs the manifest generation
+func execManifest(ctx context.Context, cfg *manifestCfg, args []string) error {
+	chainDir, err := chainDirFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := buildManifest(ctx, chainDir, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal manifest, %w", err)
+	}
+
+	return writeFileAtomic(filepath.Join(chainDir, manifestFile), append(raw, '\n'))
+}
+
+// execVerifyManifest runs the manifest verification
+func execVerifyManifest(ctx context.Context, cfg *manifestCfg, args []string, stdout io.Writer) error {
+	chainDir, err := chainDirFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	raw, err := os.ReadFile(filepath.Join(chainDir, manifestFile))
+	if err != nil {
+		return fmt.Errorf("unable to read manifest, %w", err)
+	}
+
+	var expected Manifest
+
+	if err := json.Unmarshal(raw, &expected); err != nil {
+		return fmt.Errorf("unable to unmarshal manifest, %w", err)
+	}
+
+	actual, err := buildManifest(ctx, chainDir, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	drift := diffManifests(expected, actual)
+
+	if _, err := io.WriteString(stdout, renderManifestDrift(actual, drift)); err != nil {
+		return fmt.Errorf("unable to write manifest report, %w", err)
+	}
+
+	if len(drift) > 0 {
+		return errManifestDrift
+	}
+
+	return nil
+}
+
+// Manifest defines the manifest (MANIFEST.json) of the archive files of a chain directory
+type Manifest struct {
+	LatestBlockHeight uint64         `json:"latest_block_height,omitempty"` // the metadata.json latest block height, if any
+	Files             []ManifestFile `json:"files"`                         // the archive files, sorted by name
+}
+
+// ManifestFile defines the content of a single archive file
+type ManifestFile struct {
+	Name           string `json:"name"`                      // the slash separated path, relative to the chain directory
+	From           uint64 `json:"from,omitempty"`            // the first block of the file range, if named after it
+	To             uint64 `json:"to,omitempty"`              // the last block of the file range, if named after it
+	Lines          int    `json:"lines"`                     // the number of lines, once decompressed
+	Size           int64  `json:"size"`                      // the size of the file in bytes
+	SHA256         string `json:"sha256"`                    // the hex encoded SHA-256 of the file
+	FirstTimestamp int64  `json:"first_timestamp,omitempty"` // the first tx timestamp (unix seconds), if known
+	LastTimestamp  int64  `json:"last_timestamp,omitempty"`  // the last tx timestamp (unix seconds), if known
+}
+
+// buildManifest builds the manifest of the archive files of the chain directory
+func buildManifest(ctx context.Context, chainDir, fileType string) (Manifest, error) {
+	var manifest Manifest
+
+	// Not every chain directory tracks its latest block height
+	metadata, err := readChainMetadata(chainDir)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return Manifest{}, err
+	default:
+		if manifest.LatestBlockHeight, err = metadata.latestHeight(); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	paths, err := findFilePaths(chainDir, parseFileTypes(fileType)...)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("unable to find file paths, %w", err)
+	}
+
+	manifest.Files = make([]ManifestFile, 0, len(paths))
+
+	for _, path := range paths {
+		select {
+		case <-ctx.Done():
+			return Manifest{}, ctx.Err()
+		default:
+		}
+
+		file, err := manifestEntry(chainDir, path)
+		if err != nil {
+			return Manifest{}, err
+		}
+
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	slices.SortFunc(manifest.Files, func(a, b ManifestFile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return manifest, nil
+}
+
+// manifestEntry builds the manifest entry of a single archive file.
+// The size and checksum are the ones of the file as stored, while
+// the lines and timestamps are read from its decompressed content
+func manifestEntry(chainDir, path string) (ManifestFile, error) {
+	name, err := filepath.Rel(chainDir, path)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("unable to resolve %s, %w", path, err)
+	}
+
+	entry := ManifestFile{
+		Name: filepath.ToSlash(name),
+	}
+
+	if r, ok := blockRangeFromPath(path); ok {
+		entry.From = r.from
+		entry.To = r.to
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("unable to open %s, %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if entry.Size, err = io.Copy(hash, file); err != nil {
+		return ManifestFile{}, fmt.Errorf("unable to read %s, %w", path, err)
+	}
+
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	archive, err := openArchive(path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer archive.Close()
+
+	reader := newLineReader(archive, defaultMaxLineSize)
+
+	for {
+		line, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return ManifestFile{}, fmt.Errorf("unable to read %s, %w", path, err)
+		}
+
+		entry.Lines++
+
+		if len(line.data) == 0 || line.tooLong(reader.maxSize) {
+			continue
+		}
+
+		timestamp := lineTimestamp(line.data)
+		if timestamp == 0 {
+			continue
+		}
+
+		if entry.FirstTimestamp == 0 {
+			entry.FirstTimestamp = timestamp
+		}
+
+		entry.LastTimestamp = timestamp
+	}
+
+	return entry, nil
+}
+
+// lineTimestamp returns the metadata timestamp of a tx-archive line, or 0.
+// Only the timestamp is decoded, as the tx itself is left to verify
+func lineTimestamp(line []byte) int64 {
+	var tx struct {
+		Metadata *struct {
+			Timestamp int64 `json:"timestamp,string"` // amino encodes int64 as strings
+		} `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(line, &tx); err != nil || tx.Metadata == nil {
+		return 0
+	}
+
+	return tx.Metadata.Timestamp
+}
+
+// diffManifests lists the differences between the expected manifest
+// and the manifest of the archive files as they are
+func diffManifests(expected, actual Manifest) []string {
+	var drift []string
+
+	if expected.LatestBlockHeight != actual.LatestBlockHeight {
+		drift = append(drift, fmt.Sprintf(
+			"latest block height: %d, expected %d",
+			actual.LatestBlockHeight,
+			expected.LatestBlockHeight,
+		))
+	}
+
+	actualFiles := make(map[string]ManifestFile, len(actual.Files))
+	for _, file := range actual.Files {
+		actualFiles[file.Name] = file
+	}
+
+	expectedFiles := make(map[string]ManifestFile, len(expected.Files))
+	for _, file := range expected.Files {
+		expectedFiles[file.Name] = file
+	}
+
+	for _, want := range expected.Files {
+		got, ok := actualFiles[want.Name]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("%s: missing", want.Name))
+
+			continue
+		}
+
+		if changes := diffManifestFiles(want, got); len(changes) > 0 {
+			drift = append(drift, fmt.Sprintf("%s: changed %s", want.Name, strings.Join(changes, ", ")))
+		}
+	}
+
+	for _, got := range actual.Files {
+		if _, ok := expectedFiles[got.Name]; !ok {
+			drift = append(drift, fmt.Sprintf("%s: untracked", got.Name))
+		}
+	}
+
+	return drift
+}
+
+// diffManifestFiles lists the changed fields of a single archive file
+func diffManifestFiles(want, got ManifestFile) []string {
+	var changes []string
+
+	if want.Size != got.Size {
+		changes = append(changes, fmt.Sprintf("size %d (expected %d)", got.Size, want.Size))
+	}
+
+	if want.Lines != got.Lines {
+		changes = append(changes, fmt.Sprintf("lines %d (expected %d)", got.Lines, want.Lines))
+	}
+
+	if want.SHA256 != got.SHA256 {
+		changes = append(changes, "sha256")
+	}
+
+	if want.FirstTimestamp != got.FirstTimestamp || want.LastTimestamp != got.LastTimestamp {
+		changes = append(changes, "timestamps")
+	}
+
+	return changes
+}
+
+// renderManifestDrift renders the manifest verification report
+func renderManifestDrift(actual Manifest, drift []string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "files: %d\n", len(actual.Files))
+	fmt.Fprintf(&b, "drift: %d\n", len(drift))
+
+	for _, line := range drift {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+
+	return b.String()
+}
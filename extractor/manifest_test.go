@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+
+	// newArchive writes an archive with a plain, a compressed and a legacy file
+	newArchive := func(t *testing.T) string {
+		t.Helper()
+
+		chainDir := writeBackupFiles(t, map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100, 0, 110),
+			"backup_0000011-0000020.jsonl": txLines(t, 120),
+			"metadata.json":                `{"latest_block_height": 30}`,
+			"README.md":                    "# stats",
+		})
+
+		require.NoError(t, os.MkdirAll(filepath.Join(chainDir, "archive"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(chainDir, "archive", "txexport-aa.log"), []byte("{}\n{}"), 0o644))
+
+		source := filepath.Join(chainDir, "backup_0000011-0000020.jsonl")
+		compressFile(t, source, filepath.Join(chainDir, "backup_0000021-0000030.jsonl.gz"), compressionGzip)
+
+		return chainDir
+	}
+
+	manifest := func(t *testing.T, chainDir string) {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execManifest(ctx, &manifestCfg{fileType: ".jsonl,.log"}, []string{chainDir}))
+	}
+
+	verifyManifest := func(t *testing.T, chainDir string) (string, error) {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		var out bytes.Buffer
+
+		err := execVerifyManifest(ctx, &manifestCfg{fileType: ".jsonl,.log"}, []string{chainDir}, &out)
+
+		return out.String(), err
+	}
+
+	t.Run("manifest", func(t *testing.T) {
+		t.Parallel()
+
+		chainDir := newArchive(t)
+		manifest(t, chainDir)
+
+		raw, err := os.ReadFile(filepath.Join(chainDir, manifestFile))
+		require.NoError(t, err)
+
+		var m Manifest
+		require.NoError(t, json.Unmarshal(raw, &m))
+
+		checksum := func(name string) string {
+			content, err := os.ReadFile(filepath.Join(chainDir, name))
+			require.NoError(t, err)
+
+			sum := sha256.Sum256(content)
+
+			return hex.EncodeToString(sum[:])
+		}
+
+		size := func(name string) int64 {
+			info, err := os.Stat(filepath.Join(chainDir, name))
+			require.NoError(t, err)
+
+			return info.Size()
+		}
+
+		file := func(name string, from, to uint64, lines int, first, last int64) ManifestFile {
+			return ManifestFile{
+				Name:           name,
+				From:           from,
+				To:             to,
+				Lines:          lines,
+				Size:           size(name),
+				SHA256:         checksum(name),
+				FirstTimestamp: first,
+				LastTimestamp:  last,
+			}
+		}
+
+		assert.Equal(t, Manifest{
+			LatestBlockHeight: 30,
+			Files: []ManifestFile{
+				file("archive/txexport-aa.log", 0, 0, 2, 0, 0),
+				file("backup_0000001-0000010.jsonl", 1, 10, 3, 100, 110),
+				file("backup_0000011-0000020.jsonl", 11, 20, 1, 120, 120),
+				file("backup_0000021-0000030.jsonl.gz", 21, 30, 1, 120, 120),
+			},
+		}, m)
+
+		out, err := verifyManifest(t, chainDir)
+		require.NoError(t, err)
+
+		assert.Equal(t, "files: 4\ndrift: 0\n", out)
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		t.Parallel()
+
+		chainDir := newArchive(t)
+		manifest(t, chainDir)
+
+		// Truncate a file, remove another, add a new one and bump the metadata
+		path := filepath.Join(chainDir, "backup_0000001-0000010.jsonl")
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, content[:len(content)/2], 0o644))
+
+		require.NoError(t, os.Remove(filepath.Join(chainDir, "backup_0000021-0000030.jsonl.gz")))
+		require.NoError(t, os.WriteFile(filepath.Join(chainDir, "backup_0000031-0000040.jsonl"), nil, 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(chainDir, "metadata.json"), []byte(`{"latest_block_height": 40}`), 0o644))
+
+		out, err := verifyManifest(t, chainDir)
+		assert.ErrorIs(t, err, errManifestDrift)
+
+		expected := "files: 4\n" +
+			"drift: 4\n" +
+			"  latest block height: 40, expected 30\n" +
+			"  backup_0000001-0000010.jsonl: changed size " +
+			strconv.Itoa(len(content)/2) + " (expected " + strconv.Itoa(len(content)) + "), lines 2 (expected 3), sha256, timestamps\n" +
+			"  backup_0000021-0000030.jsonl.gz: missing\n" +
+			"  backup_0000031-0000040.jsonl: untracked\n"
+
+		assert.Equal(t, expected, out)
+
+		// The manifest follows the archive once regenerated
+		manifest(t, chainDir)
+
+		_, err = verifyManifest(t, chainDir)
+		require.NoError(t, err)
+	})
+
+	t.Run("missing manifest", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := verifyManifest(t, newArchive(t))
+		assert.ErrorContains(t, err, "unable to read manifest")
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		t.Parallel()
+
+		err := execManifest(context.Background(), &manifestCfg{fileType: ".jsonl"}, nil)
+		assert.ErrorIs(t, err, errMissingChainDir)
+
+		err = execManifest(context.Background(), &manifestCfg{fileType: ".jsonl"}, []string{filepath.Join(t.TempDir(), "missing")})
+		assert.ErrorIs(t, err, errInvalidChainDir)
+	})
+}
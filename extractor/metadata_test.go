@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/crypto/secp256k1"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidFlow_Metadata(t *testing.T) {
+	t.Parallel()
+
+	var (
+		outputDir = t.TempDir()
+		sourceDir = t.TempDir()
+
+		pubKey  = secp256k1.GenPrivKey().PubKey()
+		creator = pubKey.Address()
+
+		pkg = &std.MemPackage{
+			Name: "foo",
+			Path: "gno.land/r/demo/foo",
+			Files: []*std.MemFile{
+				{Name: "foo.gno", Body: "package foo"},
+			},
+		}
+
+		// The deployment is the second message of the second tx of its block
+		tx = std.Tx{
+			Msgs: []std.Msg{
+				vm.MsgCall{
+					Caller:  creator,
+					PkgPath: "gno.land/r/demo/bar",
+					Func:    "Bar",
+				},
+				vm.MsgAddPackage{
+					Creator:    creator,
+					Package:    pkg,
+					Send:       std.NewCoins(std.NewCoin("ugnot", 10)),
+					MaxDeposit: std.NewCoins(std.NewCoin("ugnot", 2_000)),
+				},
+			},
+			Fee: std.Fee{
+				GasWanted: 5_000_000,
+				GasFee:    std.NewCoin("ugnot", 1_000_000),
+			},
+			Signatures: []std.Signature{
+				{PubKey: pubKey, Signature: []byte("signature")},
+			},
+			Memo: "deploy foo",
+		}
+	)
+
+	file, err := os.Create(filepath.Join(sourceDir, "backup_0000001-0001000.jsonl"))
+	require.NoError(t, err)
+
+	for _, txData := range []std.Tx{
+		{Msgs: []std.Msg{vm.MsgCall{Caller: creator, PkgPath: "gno.land/r/demo/bar", Func: "Bar"}}},
+		tx,
+	} {
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx: txData,
+			Metadata: &gnoland.GnoTxMetadata{
+				Timestamp:   1786139574,
+				BlockHeight: 42,
+			},
+		}, file))
+	}
+
+	require.NoError(t, file.Close())
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFn()
+
+	require.NoError(t, execExtract(ctx, &extractorCfg{
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		outputDir: outputDir,
+	}))
+
+	basePath, err := packageDir(outputDir, pkg.Path)
+	require.NoError(t, err)
+
+	raw, err := amino.Marshal(tx)
+	require.NoError(t, err)
+
+	hash := sha256.Sum256(raw)
+
+	assert.Equal(t, Metadata{
+		SchemaVersion: metadataSchemaVersion,
+		Creator:       creator.String(),
+		Deposit:       "10ugnot",
+		Send:          "10ugnot",
+		MaxDeposit:    "2000ugnot",
+		Height:        42,
+		HeightSource:  "block",
+		Timestamp:     1786139574,
+		TxHash:        base64.StdEncoding.EncodeToString(hash[:]),
+		TxIndex:       1,
+		MsgIndex:      1,
+		Memo:          "deploy foo",
+		GasWanted:     5_000_000,
+		GasFee:        "1000000ugnot",
+		Signers: []Signer{
+			{Address: creator.String(), PubKey: pubKey.String()},
+		},
+	}, readMetadata(t, basePath))
+}
+
+func TestSignersFromTx(t *testing.T) {
+	t.Parallel()
+
+	var (
+		first  = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+		second = addressFromString(t, "g1f4v282mwyhu29afke4vq5r2xzcm6z3ftnugcnv")
+	)
+
+	// Duplicate signers are listed once, and the public key is optional
+	signers := signersFromTx(std.Tx{
+		Msgs: []std.Msg{
+			vm.MsgCall{Caller: first},
+			vm.MsgCall{Caller: second},
+			vm.MsgCall{Caller: first},
+		},
+	})
+
+	assert.Equal(t, []Signer{
+		{Address: first.String()},
+		{Address: second.String()},
+	}, signers)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gno/gnovm/pkg/gnolang"
+	"github.com/gnolang/gno/gnovm/pkg/gnomod"
+	"github.com/gnolang/gno/tm2/pkg/std"
+)
+
+// Define the module file names
+const (
+	moduleFile           = "gnomod.toml"
+	deprecatedModuleFile = "gno.mod"
+)
+
+// hasModuleFile checks if the package files include a module file
+func hasModuleFile(files []*std.MemFile) bool {
+	for _, file := range files {
+		if file.Name == moduleFile || file.Name == deprecatedModuleFile {
+			return true
+		}
+	}
+
+	return false
+}
+
+// packageImports returns the sorted import paths of the package source files,
+// optionally including the test files. Files that don't parse are skipped
+func packageImports(files []*std.MemFile, includeTests bool) []string {
+	var (
+		fset    = token.NewFileSet()
+		imports = make(map[string]struct{})
+	)
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name, ".gno") {
+			continue
+		}
+
+		if !includeTests && isTestFile(file.Name) {
+			continue
+		}
+
+		parsed, err := parser.ParseFile(fset, file.Name, file.Body, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+
+		for _, spec := range parsed.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+
+			imports[importPath] = struct{}{}
+		}
+	}
+
+	return slices.Sorted(maps.Keys(imports))
+}
+
+// isTestFile checks if the package file is a test (or filetest) file
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.gno") || strings.HasSuffix(name, "_filetest.gno")
+}
+
+// Requirement defines an extracted package version imported by another package
+type Requirement struct {
+	Path    string `json:"path"`    // the imported package path
+	Version int    `json:"version"` // the version of the imported package at the deployment height
+}
+
+// requirements returns the extracted version of every package the given package
+// imports. Messages are written in order, so the latest version of each
+// is the one that existed at the deployment height
+func (s *versionStore) requirements(msg AddPackage) []Requirement {
+	var requires []Requirement
+
+	// Tests are built along with the package
+	for _, importPath := range packageImports(msg.Package.Files, true) {
+		if importPath == msg.Package.Path {
+			continue
+		}
+
+		// Standard libraries have no package dir
+		dir, err := packageDir(s.rootDir, importPath)
+		if err != nil {
+			continue
+		}
+
+		history, err := s.extracted(dir)
+		if err != nil || history == nil || history.Latest == 0 {
+			// The package was never extracted (ie deployed at genesis)
+			continue
+		}
+
+		requires = append(requires, Requirement{
+			Path:    importPath,
+			Version: history.Latest,
+		})
+	}
+
+	return requires
+}
+
+// extracted returns the version history of an already extracted package dir, or nil.
+// Full runs only know of the packages they wrote, while incremental runs
+// also know of the packages extracted by the previous runs
+func (s *versionStore) extracted(dir string) (*PackageVersions, error) {
+	if history, ok := s.histories[dir]; ok {
+		return history, nil
+	}
+
+	if !s.incremental {
+		return nil, nil
+	}
+
+	history, err := readPackageVersions(dir)
+	if err != nil || history == nil {
+		return nil, err
+	}
+
+	s.histories[dir] = history
+
+	return history, nil
+}
+
+// moduleFile builds the module file of a package version kept in the given dir.
+// Every requirement is replaced by the relative dir currently holding its version
+func (s *versionStore) moduleFile(pkgPath, dir string, requires []Requirement) *gnomod.File {
+	module := &gnomod.File{
+		Module: pkgPath,
+		Gno:    gnolang.GnoVerDefault,
+	}
+
+	for _, req := range requires {
+		depDir, err := packageDir(s.rootDir, req.Path)
+		if err != nil {
+			continue
+		}
+
+		history, ok := s.histories[depDir]
+		if !ok || req.Version < 1 || req.Version > len(history.Versions) {
+			continue
+		}
+
+		target := filepath.Join(filepath.Dir(depDir), history.Versions[req.Version-1].Dir)
+
+		rel, err := filepath.Rel(dir, target)
+		if err != nil {
+			continue
+		}
+
+		module.AddReplace(req.Path, filepath.ToSlash(rel))
+	}
+
+	return module
+}
+
+// linkModules rewrites the generated module files once the extraction is over.
+// Superseded versions are moved to their own version dir, so the
+// requirements pointing at them have to follow
+func (s *versionStore) linkModules() error {
+	if !s.modules {
+		return nil
+	}
+
+	// Packages of previous runs may require the versions this run moved
+	if s.incremental {
+		if err := s.loadHistories(); err != nil {
+			return err
+		}
+	}
+
+	for outputDir, history := range s.histories {
+		for _, version := range history.Versions {
+			if !version.Module {
+				continue
+			}
+
+			dir := filepath.Join(filepath.Dir(outputDir), version.Dir)
+
+			if err := writeModuleFile(s.moduleFile(history.Path, dir, version.Requires), dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadHistories loads the version index of every package in the output root
+func (s *versionStore) loadHistories() error {
+	err := filepath.WalkDir(s.rootDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || entry.Name() != packageVersionsFile {
+			return nil
+		}
+
+		_, err = s.extracted(filepath.Dir(path))
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to load package versions, %w", err)
+	}
+
+	return nil
+}
+
+// writeModuleFile writes the module file to the package dir, unless it is up to date
+func writeModuleFile(module *gnomod.File, outputDir string) error {
+	var (
+		writePath = filepath.Join(outputDir, moduleFile)
+		moduleRaw = []byte(module.WriteString())
+	)
+
+	existing, err := os.ReadFile(writePath)
+	if err == nil && bytes.Equal(existing, moduleRaw) {
+		return nil
+	}
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to read module file, %w", err)
+	}
+
+	if err := os.WriteFile(writePath, moduleRaw, 0o644); err != nil {
+		return fmt.Errorf("unable to write module file, %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateModuleSourceFiles generates two backup files deploying
+// packages that import each other, across several versions
+func generateModuleSourceFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	var (
+		creator = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+
+		app = `package app
+
+import (
+	"strings"
+
+	"gno.land/p/demo/dep"
+	"gno.land/p/demo/genesis"
+)
+`
+		packages = []*std.MemPackage{
+			{
+				Name:  "dep",
+				Path:  "gno.land/p/demo/dep",
+				Files: []*std.MemFile{{Name: "dep.gno", Body: "package dep // v1"}},
+			},
+			{
+				Name:  "app",
+				Path:  "gno.land/r/demo/app",
+				Files: []*std.MemFile{{Name: "app.gno", Body: app}},
+			},
+			{
+				Name:  "dep",
+				Path:  "gno.land/p/demo/dep",
+				Files: []*std.MemFile{{Name: "dep.gno", Body: "package dep // v2"}},
+			},
+			{
+				Name:  "app",
+				Path:  "gno.land/r/demo/app",
+				Files: []*std.MemFile{{Name: "app.gno", Body: app + "// v2"}},
+			},
+			{
+				Name: "own",
+				Path: "gno.land/r/demo/own",
+				Files: []*std.MemFile{
+					{Name: "own.gno", Body: "package own\n\nimport \"gno.land/p/demo/dep\"\n"},
+					{Name: moduleFile, Body: "module = \"gno.land/r/demo/own\"\ngno = \"0.9\"\n"},
+				},
+			},
+		}
+	)
+
+	for fileIndex, deployments := range [][]*std.MemPackage{packages[:2], packages[2:]} {
+		var (
+			from = uint64(fileIndex*1000 + 1)
+			name = fmt.Sprintf("backup_%07d-%07d.jsonl", from, from+999)
+		)
+
+		file, err := os.Create(filepath.Join(dir, name))
+		require.NoError(t, err)
+
+		for i, pkg := range deployments {
+			require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+				Tx: std.Tx{
+					Msgs: []std.Msg{
+						vm.MsgAddPackage{
+							Creator: creator,
+							Package: pkg,
+						},
+					},
+				},
+				Metadata: &gnoland.GnoTxMetadata{
+					BlockHeight: int64(from) + int64(i),
+				},
+			}, file))
+		}
+
+		require.NoError(t, file.Close())
+	}
+}
+
+func TestValidFlow_Modules(t *testing.T) {
+	t.Parallel()
+
+	sourceDir := t.TempDir()
+	generateModuleSourceFiles(t, sourceDir)
+
+	sourceFiles, err := findFilePaths(sourceDir, sourceFileType)
+	require.NoError(t, err)
+	require.Len(t, sourceFiles, 2)
+
+	extract := func(sourceDir, outputDir string, incremental bool) map[string]string {
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg:   sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputDir:   outputDir,
+			incremental: incremental,
+			genModules:  true,
+		}))
+
+		return readTree(t, outputDir)
+	}
+
+	tree := extract(sourceDir, t.TempDir(), false)
+
+	// Every version points at the dep version that existed at its height
+	assert.Equal(t, `module = "gno.land/r/demo/app"
+gno = "0.9"
+
+[[replace]]
+  old = "gno.land/p/demo/dep"
+  new = "../../../p/demo/dep:v1"
+`, tree["r/demo/app:v1/"+moduleFile])
+
+	assert.Equal(t, `module = "gno.land/r/demo/app"
+gno = "0.9"
+
+[[replace]]
+  old = "gno.land/p/demo/dep"
+  new = "../../../p/demo/dep"
+`, tree["r/demo/app/"+moduleFile])
+
+	// Packages without extracted imports have no requirement
+	assert.Equal(t, "module = \"gno.land/p/demo/dep\"\ngno = \"0.9\"\n", tree["p/demo/dep:v1/"+moduleFile])
+	assert.Equal(t, "module = \"gno.land/p/demo/dep\"\ngno = \"0.9\"\n", tree["p/demo/dep/"+moduleFile])
+
+	// Module files of the package are kept as is
+	assert.Equal(t, "module = \"gno.land/r/demo/own\"\ngno = \"0.9\"\n", tree["r/demo/own/"+moduleFile])
+
+	t.Run("versions", func(t *testing.T) {
+		t.Parallel()
+
+		outputDir := t.TempDir()
+		extract(sourceDir, outputDir, false)
+
+		app, err := readPackageVersions(filepath.Join(outputDir, "r", "demo", "app"))
+		require.NoError(t, err)
+		require.Len(t, app.Versions, 2)
+
+		assert.True(t, app.Versions[0].Module)
+		assert.Equal(t, []Requirement{{Path: "gno.land/p/demo/dep", Version: 1}}, app.Versions[0].Requires)
+		assert.Equal(t, []Requirement{{Path: "gno.land/p/demo/dep", Version: 2}}, app.Versions[1].Requires)
+
+		own, err := readPackageVersions(filepath.Join(outputDir, "r", "demo", "own"))
+		require.NoError(t, err)
+		require.Len(t, own.Versions, 1)
+
+		assert.False(t, own.Versions[0].Module)
+		assert.Empty(t, own.Versions[0].Requires)
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			partialDir = t.TempDir()
+			outputDir  = t.TempDir()
+		)
+
+		// The dep is superseded by the second file only
+		copySourceFiles(t, filepath.Join(partialDir, filepath.Base(sourceFiles[0])), sourceFiles[0])
+		extract(partialDir, outputDir, true)
+
+		copySourceFiles(t, filepath.Join(partialDir, filepath.Base(sourceFiles[1])), sourceFiles[1])
+
+		assert.Equal(t, extract(partialDir, t.TempDir(), false), extract(partialDir, outputDir, true))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		outputDir := t.TempDir()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputDir: outputDir,
+		}))
+
+		tree := readTree(t, outputDir)
+
+		assert.NotContains(t, tree, "r/demo/app/"+moduleFile)
+		assert.Contains(t, tree, "r/demo/own/"+moduleFile)
+	})
+}
+
+func TestPackageImports(t *testing.T) {
+	t.Parallel()
+
+	files := []*std.MemFile{
+		{Name: "a.gno", Body: "package a\n\nimport (\n\t\"strings\"\n\tfoo \"gno.land/p/demo/foo\"\n)\n"},
+		{Name: "a_test.gno", Body: "package a\n\nimport \"gno.land/p/demo/bar\"\nimport \"strings\"\n"},
+		{Name: "README.md", Body: "import \"gno.land/p/demo/readme\""},
+		{Name: "broken.gno", Body: "not gno"},
+	}
+
+	assert.Equal(t, []string{"gno.land/p/demo/bar", "gno.land/p/demo/foo", "strings"}, packageImports(files, true))
+	assert.Equal(t, []string{"gno.land/p/demo/foo", "strings"}, packageImports(files, false))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gnolang/gno/tm2/pkg/std"
+)
+
+// defaultDomain is the package domain that maps directly to the output root
+const defaultDomain = "gno.land"
+
+// maxFileNameLength is the maximum length of a single package file name
+const maxFileNameLength = 255
+
+var (
+	errInvalidPackagePath = errors.New("invalid package path")
+	errInvalidFileName    = errors.New("invalid package file name")
+)
+
+var (
+	// domainRegex matches package path domains (ie gno.land)
+	domainRegex = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*(?:\.[a-z0-9]+(?:-[a-z0-9]+)*)+$`)
+
+	// segmentRegex matches a single package path element, or a file name.
+	// Leading periods are rejected, so "." and ".." never match
+	segmentRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+)
+
+// packageDir returns the output directory for the given package path.
+// Packages of the default domain (gno.land/r/..., gno.land/p/...) are kept
+// directly under the output root, while packages of any other domain
+// are kept under their own domain directory (ie example.com/r/...).
+// The default domain paths starting with a domain are rejected,
+// so that they can't collide with the packages of that domain
+func packageDir(outputRoot, pkgPath string) (string, error) {
+	if err := validatePackagePath(pkgPath); err != nil {
+		return "", err
+	}
+
+	domain, rest, _ := strings.Cut(pkgPath, "/")
+	if domain == defaultDomain {
+		return filepath.Join(outputRoot, filepath.FromSlash(rest)), nil
+	}
+
+	return filepath.Join(outputRoot, filepath.FromSlash(pkgPath)), nil
+}
+
+// validatePackagePath checks that the package path is a domain,
+// followed by one or more clean path elements. The first element
+// of a default domain path can't be a domain itself
+func validatePackagePath(pkgPath string) error {
+	elements := strings.Split(pkgPath, "/")
+	if len(elements) < 2 {
+		return fmt.Errorf("%w %q, missing domain or package name", errInvalidPackagePath, pkgPath)
+	}
+
+	if !domainRegex.MatchString(elements[0]) {
+		return fmt.Errorf("%w %q, invalid domain %q", errInvalidPackagePath, pkgPath, elements[0])
+	}
+
+	if elements[0] == defaultDomain && domainRegex.MatchString(elements[1]) {
+		return fmt.Errorf("%w %q, domain element %q", errInvalidPackagePath, pkgPath, elements[1])
+	}
+
+	for _, element := range elements[1:] {
+		if !segmentRegex.MatchString(element) {
+			return fmt.Errorf("%w %q, invalid element %q", errInvalidPackagePath, pkgPath, element)
+		}
+	}
+
+	return nil
+}
+
+// validateFileName checks that the package file name is a plain file name,
+// that cannot escape the package output directory, nor overwrite the files
+// the extractor writes next to the package files
+func validateFileName(name string) error {
+	if len(name) > maxFileNameLength || !segmentRegex.MatchString(name) {
+		return fmt.Errorf("%w %q", errInvalidFileName, name)
+	}
+
+	if name == packageMetadataFile || name == packageVersionsFile {
+		return fmt.Errorf("%w %q, reserved name", errInvalidFileName, name)
+	}
+
+	return nil
+}
+
+// validatePackage checks the package path and all package file names
+func validatePackage(msg AddPackage) error {
+	if err := validatePackagePath(msg.Package.Path); err != nil {
+		return err
+	}
+
+	return validateFiles(msg.Package.Files)
+}
+
+// validateFiles checks all package file names
+func validateFiles(files []*std.MemFile) error {
+	for _, file := range files {
+		if err := validateFileName(file.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
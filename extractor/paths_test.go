@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageDir(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name        string
+		pkgPath     string
+		expectedDir string
+		expectedErr error
+	}{
+		{
+			"realm",
+			"gno.land/r/demo/boards",
+			filepath.Join("out", "r", "demo", "boards"),
+			nil,
+		},
+		{
+			"pure package",
+			"gno.land/p/nt/avl",
+			filepath.Join("out", "p", "nt", "avl"),
+			nil,
+		},
+		{
+			"element starting with domain characters",
+			"gno.land/dao/foo",
+			filepath.Join("out", "dao", "foo"),
+			nil,
+		},
+		{
+			"domain element",
+			"gno.land/example.com/r/demo/boards",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"other domain",
+			"example.com/r/demo/boards",
+			filepath.Join("out", "example.com", "r", "demo", "boards"),
+			nil,
+		},
+		{
+			"parent traversal",
+			"gno.land/r/../../etc",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"current dir element",
+			"gno.land/r/./foo",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"absolute path",
+			"/etc/passwd",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"quoted path",
+			"'gno.land/r/isma_test'",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"empty element",
+			"gno.land/r//foo",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"version separator",
+			"gno.land/r/foo:v1",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"missing package",
+			"gno.land",
+			"",
+			errInvalidPackagePath,
+		},
+		{
+			"empty path",
+			"",
+			"",
+			errInvalidPackagePath,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir, err := packageDir("out", testCase.pkgPath)
+
+			assert.ErrorIs(t, err, testCase.expectedErr)
+			assert.Equal(t, testCase.expectedDir, dir)
+		})
+	}
+}
+
+func TestValidateFileName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"foo.gno", "foo_test.gno", "gnomod.toml", "README.md", "LICENSE"} {
+		assert.NoError(t, validateFileName(name), name)
+	}
+
+	for _, name := range []string{"", ".", "..", "../foo.gno", "/etc/passwd", "a/b.gno", `a\b.gno`, ".hidden", "foo bar.gno", packageMetadataFile, packageVersionsFile} {
+		assert.ErrorIs(t, validateFileName(name), errInvalidFileName, name)
+	}
+}
+
+func TestValidFlow_RejectsTraversal(t *testing.T) {
+	t.Parallel()
+
+	var (
+		root      = t.TempDir()
+		outputDir = filepath.Join(root, "out")
+		sourceDir = filepath.Join(root, "source")
+
+		creator = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+	)
+
+	require.NoError(t, os.MkdirAll(sourceDir, os.ModePerm))
+
+	msgs := []std.Msg{
+		vm.MsgAddPackage{
+			Creator: creator,
+			Package: &std.MemPackage{
+				Name:  "evil",
+				Path:  "gno.land/r/../../evil",
+				Files: []*std.MemFile{{Name: "evil.gno", Body: "evil"}},
+			},
+		},
+		vm.MsgAddPackage{
+			Creator: creator,
+			Package: &std.MemPackage{
+				Name:  "evil",
+				Path:  "gno.land/r/evil",
+				Files: []*std.MemFile{{Name: "../../../evil.gno", Body: "evil"}},
+			},
+		},
+		vm.MsgAddPackage{
+			Creator: creator,
+			Package: &std.MemPackage{
+				Name:  "good",
+				Path:  "gno.land/r/good",
+				Files: []*std.MemFile{{Name: "good.gno", Body: "good"}},
+			},
+		},
+	}
+
+	file, err := os.Create(filepath.Join(sourceDir, "backup_0000001-0000001.jsonl"))
+	require.NoError(t, err)
+	require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{Tx: std.Tx{Msgs: msgs}}, file))
+	require.NoError(t, file.Close())
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFn()
+
+	require.NoError(t, execExtract(ctx, &extractorCfg{
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		outputDir: outputDir,
+	}))
+
+	// Only the valid package is extracted
+	assert.FileExists(t, filepath.Join(outputDir, "r", "good", "good.gno"))
+	assert.NoDirExists(t, filepath.Join(outputDir, "r", "evil"))
+	assert.NoFileExists(t, filepath.Join(root, "evil.gno"))
+	assert.NoDirExists(t, filepath.Join(root, "evil"))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// fileBufferSize is the number of decoded messages buffered for each file
+// that is decoded ahead of the file currently being processed
+const fileBufferSize = 64
+
+// txResult is a single decoded message (or error) of a source file stream
+type txResult struct {
+	msg TxMessage
+	err error
+}
+
+// orderedFiles yields the message stream of every source file, in source file order.
+// With more than one worker, the files are decoded concurrently (up to workers files
+// at once) ahead of the file being processed, while the messages of every file are
+// still yielded in their line order. The memory usage stays bounded, as every
+// file decoded ahead only buffers up to fileBufferSize messages
+func orderedFiles(
+	ctx context.Context,
+	sourceFiles []string,
+	workers int,
+	msgsFn func(sourceFile string) iter.Seq2[TxMessage, error],
+) iter.Seq2[string, iter.Seq2[TxMessage, error]] {
+	if workers <= 1 {
+		return func(yield func(string, iter.Seq2[TxMessage, error]) bool) {
+			for _, sourceFile := range sourceFiles {
+				if !yield(sourceFile, msgsFn(sourceFile)) {
+					return
+				}
+			}
+		}
+	}
+
+	return func(yield func(string, iter.Seq2[TxMessage, error]) bool) {
+		var (
+			wg      sync.WaitGroup
+			slots   = make(chan struct{}, workers)
+			results = make([]chan txResult, len(sourceFiles))
+		)
+
+		ctx, cancelFn := context.WithCancel(ctx)
+
+		// Stop the workers, and wait for them to close their files
+		defer func() {
+			cancelFn()
+			wg.Wait()
+		}()
+
+		for i := range results {
+			results[i] = make(chan txResult, fileBufferSize)
+		}
+
+		// Files are started in order, so the file being processed
+		// always holds a worker slot, and can't be starved by the files ahead
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i, sourceFile := range sourceFiles {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				wg.Add(1)
+
+				go func() {
+					defer func() {
+						close(results[i])
+						<-slots
+						wg.Done()
+					}()
+
+					for msg, err := range msgsFn(sourceFile) {
+						select {
+						case results[i] <- txResult{msg: msg, err: err}:
+						case <-ctx.Done():
+							return
+						}
+
+						if err != nil {
+							return
+						}
+					}
+				}()
+			}
+		}()
+
+		for i, sourceFile := range sourceFiles {
+			msgs := func(yield func(TxMessage, error) bool) {
+				for {
+					select {
+					case result, ok := <-results[i]:
+						if !ok {
+							return
+						}
+
+						if !yield(result.msg, result.err) || result.err != nil {
+							return
+						}
+					case <-ctx.Done():
+						yield(TxMessage{}, ctx.Err())
+
+						return
+					}
+				}
+			}
+
+			if !yield(sourceFile, msgs) {
+				return
+			}
+
+			// Discard whatever was not consumed,
+			// so the file releases its worker slot
+			if !drain(ctx, results[i]) {
+				return
+			}
+		}
+	}
+}
+
+// drain discards the results until the channel is closed.
+// Returns false if the context was cancelled first
+func drain(ctx context.Context, results <-chan txResult) bool {
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
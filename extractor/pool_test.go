@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateVersionedSourceFiles generates backup files redeploying
+// the same few package paths over and over, across all files
+func generateVersionedSourceFiles(t *testing.T, dir string, numFiles, txPerFile int) {
+	t.Helper()
+
+	var (
+		creator = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+		paths   = []string{
+			"gno.land/r/demo/foo",
+			"gno.land/r/demo/foo/nested",
+			"gno.land/p/demo/bar",
+			"example.com/r/demo/baz",
+		}
+	)
+
+	for fileIndex := range numFiles {
+		var (
+			from = uint64(fileIndex*1000 + 1)
+			name = fmt.Sprintf("backup_%07d-%07d.jsonl", from, from+999)
+		)
+
+		file, err := os.Create(filepath.Join(dir, name))
+		require.NoError(t, err)
+
+		for txIndex := range txPerFile {
+			path := paths[(fileIndex+txIndex)%len(paths)]
+
+			require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+				Tx: std.Tx{
+					Msgs: []std.Msg{
+						vm.MsgAddPackage{
+							Creator: creator,
+							Package: &std.MemPackage{
+								Name: filepath.Base(path),
+								Path: path,
+								Files: []*std.MemFile{
+									{
+										Name: "file.gno",
+										Body: fmt.Sprintf("package %s // %d-%d", filepath.Base(path), fileIndex, txIndex),
+									},
+								},
+							},
+						},
+					},
+				},
+				Metadata: &gnoland.GnoTxMetadata{
+					BlockHeight: int64(from) + int64(txIndex),
+				},
+			}, file))
+		}
+
+		require.NoError(t, file.Close())
+	}
+}
+
+// readTree reads every file of the given directory tree,
+// keyed by their path relative to the tree root
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+
+	tree := make(map[string]string)
+
+	require.NoError(t, filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			tree[rel+"/"] = ""
+
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		tree[rel] = string(content)
+
+		return nil
+	}))
+
+	return tree
+}
+
+func TestValidFlow_Workers(t *testing.T) {
+	t.Parallel()
+
+	sourceDir := t.TempDir()
+	generateVersionedSourceFiles(t, sourceDir, 16, 25)
+
+	extract := func(workers int) map[string]string {
+		outputDir := t.TempDir()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputDir: outputDir,
+			workers:   workers,
+		}))
+
+		return readTree(t, outputDir)
+	}
+
+	sequential := extract(1)
+
+	// Every deployment is kept as its own version
+	basePath, err := packageDir("", "gno.land/r/demo/foo")
+	require.NoError(t, err)
+
+	assert.Contains(t, sequential, filepath.Join(basePath, packageVersionsFile))
+	assert.Contains(t, sequential, filepath.Join(versionDir(basePath, 99), "file.gno"))
+
+	for _, workers := range []int{2, 4, 16, 32} {
+		t.Run(strconv.Itoa(workers)+" workers", func(t *testing.T) {
+			assert.Equal(t, sequential, extract(workers))
+		})
+	}
+}
+
+func TestExtractor_InvalidWorkers(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFn()
+
+	err := execExtract(ctx, &extractorCfg{
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: "."},
+		outputDir: t.TempDir(),
+		workers:   -1,
+	})
+
+	assert.ErrorIs(t, err, errInvalidWorkers)
+}
+
+func TestOrderedFiles(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sourceFiles = []string{"0", "1", "2", "3", "4", "5", "6", "7"}
+		errDecode   = errors.New("decode error")
+	)
+
+	// msgsFn yields 200 messages per file, with the file number as height,
+	// and fails on file 5
+	msgsFn := func(sourceFile string) iter.Seq2[TxMessage, error] {
+		return func(yield func(TxMessage, error) bool) {
+			height, _ := strconv.ParseUint(sourceFile, 10, 64)
+
+			for i := range 200 {
+				if height == 5 && i == 100 {
+					yield(TxMessage{}, errDecode)
+
+					return
+				}
+
+				if !yield(TxMessage{Height: height, TxIndex: i}, nil) {
+					return
+				}
+			}
+		}
+	}
+
+	t.Run("ordered", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		collect := func(workers int) ([]string, error) {
+			var entries []string
+
+			for sourceFile, msgs := range orderedFiles(ctx, sourceFiles, workers, msgsFn) {
+				for msg, err := range msgs {
+					if err != nil {
+						return entries, err
+					}
+
+					assert.Equal(t, sourceFile, strconv.FormatUint(msg.Height, 10))
+
+					entries = append(entries, sourceFile+":"+strconv.Itoa(msg.TxIndex))
+				}
+			}
+
+			return entries, nil
+		}
+
+		expected, err := collect(1)
+		require.ErrorIs(t, err, errDecode)
+
+		actual, err := collect(3)
+		require.ErrorIs(t, err, errDecode)
+
+		assert.Len(t, actual, 5*200+100)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("skipped files", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		// Files that are not consumed don't block the files ahead
+		var heights []uint64
+
+		for _, msgs := range orderedFiles(ctx, sourceFiles[:5], 2, msgsFn) {
+			for msg, msgErr := range msgs {
+				require.NoError(t, msgErr)
+
+				heights = append(heights, msg.Height)
+
+				break
+			}
+		}
+
+		assert.Equal(t, []uint64{0, 1, 2, 3, 4}, heights)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithCancel(context.Background())
+		cancelFn()
+
+		var err error
+
+		for _, msgs := range orderedFiles(ctx, sourceFiles, 4, msgsFn) {
+			for _, msgErr := range msgs {
+				if msgErr != nil {
+					err = msgErr
+				}
+			}
+
+			if err != nil {
+				break
+			}
+		}
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxSnippetLength is the maximum length of the line snippet in a reject record
+const maxSnippetLength = 256
+
+var errInvalidTxLine = errors.New("unable to decode tx line")
+
+// LineError defines a tx archive line that could not be decoded
+type LineError struct {
+	File    string `json:"file"`    // the source file
+	Line    int    `json:"line"`    // the line number, starting from 1
+	Offset  int64  `json:"offset"`  // the byte offset of the line start
+	Err     string `json:"error"`   // the decoding error
+	Snippet string `json:"snippet"` // the beginning of the line
+}
+
+// newLineError creates a new line error, with a truncated line snippet
+func newLineError(file string, line int, offset int64, raw []byte, err error) LineError {
+	snippet := raw
+	if len(snippet) > maxSnippetLength {
+		snippet = snippet[:maxSnippetLength]
+	}
+
+	return LineError{
+		File:    file,
+		Line:    line,
+		Offset:  offset,
+		Err:     err.Error(),
+		Snippet: strings.ToValidUTF8(string(snippet), ""),
+	}
+}
+
+// rejectsCfg defines how undecodable tx lines are handled
+type rejectsCfg struct {
+	strict      bool
+	rejectsPath string
+	maxLineSize int
+}
+
+// registerFlags registers the rejects flag set
+func (c *rejectsCfg) registerFlags(fs *flag.FlagSet) {
+	fs.BoolVar(
+		&c.strict,
+		"strict",
+		false,
+		"flag indicating if the command should fail on the first tx line that cannot be decoded",
+	)
+
+	fs.StringVar(
+		&c.rejectsPath,
+		"rejects-path",
+		"",
+		"the output JSONL file for the tx lines that cannot be decoded (lenient mode only)",
+	)
+
+	fs.IntVar(
+		&c.maxLineSize,
+		"max-line-size",
+		defaultMaxLineSize,
+		"the maximum size of a single tx line in bytes, longer lines are rejected",
+	)
+}
+
+// rejectHandler handles the tx lines that cannot be decoded.
+// In strict mode, the first reject fails the command; otherwise
+// rejects are logged, counted, and optionally saved to the rejects file.
+// The handler is safe for concurrent use
+type rejectHandler struct {
+	strict      bool
+	maxLineSize int
+
+	mu sync.Mutex
+
+	file    io.Closer
+	encoder *json.Encoder
+
+	count  int
+	closed bool
+}
+
+// newRejectHandler creates a new reject handler, creating the rejects file if needed
+func newRejectHandler(cfg rejectsCfg) (*rejectHandler, error) {
+	h := &rejectHandler{
+		strict:      cfg.strict,
+		maxLineSize: cfg.maxLineSize,
+	}
+
+	if cfg.strict || cfg.rejectsPath == "" {
+		return h, nil
+	}
+
+	file, err := os.Create(cfg.rejectsPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create rejects file, %w", err)
+	}
+
+	h.file = file
+	h.encoder = json.NewEncoder(file)
+
+	return h, nil
+}
+
+// reject handles a single undecodable tx line
+func (h *rejectHandler) reject(lineErr LineError) error {
+	if h.strict {
+		return fmt.Errorf(
+			"%w %s:%d (offset %d), %s",
+			errInvalidTxLine,
+			lineErr.File,
+			lineErr.Line,
+			lineErr.Offset,
+			lineErr.Err,
+		)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count++
+
+	slog.Error(
+		"error while parsing amino JSON",
+		"file", lineErr.File,
+		"line", lineErr.Line,
+		"error", lineErr.Err,
+	)
+
+	if h.encoder == nil {
+		return nil
+	}
+
+	if err := h.encoder.Encode(lineErr); err != nil {
+		return fmt.Errorf("unable to write reject, %w", err)
+	}
+
+	return nil
+}
+
+// close reports the number of rejected lines, and closes the rejects file.
+// Closing the handler more than once is a no-op
+func (h *rejectHandler) close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+
+	h.closed = true
+
+	if h.count > 0 {
+		slog.Warn("tx lines could not be decoded and were skipped", "count", h.count)
+	}
+
+	if h.file == nil {
+		return nil
+	}
+
+	if err := h.file.Close(); err != nil {
+		return fmt.Errorf("unable to close rejects file, %w", err)
+	}
+
+	return nil
+}
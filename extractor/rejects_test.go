@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateRejectSource generates a source file with two valid txs
+// around a long undecodable line, and returns the source dir
+func generateRejectSource(t *testing.T) (string, []byte, []byte) {
+	t.Helper()
+
+	_, mockAddPkgMsg := generateMockMsgs(t)
+	require.GreaterOrEqual(t, len(mockAddPkgMsg), 2)
+
+	var (
+		sourceDir = t.TempDir()
+		lines     = make([][]byte, 0, 3)
+	)
+
+	valid := func(msg std.Msg) []byte {
+		data, err := amino.MarshalJSON(gnoland.TxWithMetadata{Tx: std.Tx{Msgs: []std.Msg{msg}}})
+		require.NoError(t, err)
+
+		return data
+	}
+
+	// The invalid line is longer than the bufio buffer, so a reader
+	// that does not reset its line buffer would glue it to the next line
+	invalid := []byte(`{"tx":{"msg":[],"unknown":"` + strings.Repeat("a", 100_000) + `"}}`)
+
+	lines = append(lines, valid(mockAddPkgMsg[0]), invalid, valid(mockAddPkgMsg[1]))
+
+	var content []byte
+	for _, line := range lines {
+		content = append(content, line...)
+		content = append(content, '\r', '\n')
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "backup_0000001-0000100.jsonl"), content, 0o644))
+
+	return sourceDir, lines[0], invalid
+}
+
+func TestRejects_Strict(t *testing.T) {
+	t.Parallel()
+
+	sourceDir, _, _ := generateRejectSource(t)
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFn()
+
+	err := execExtract(ctx, &extractorCfg{
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		outputDir: t.TempDir(),
+		rejectsCfg: rejectsCfg{
+			strict: true,
+		},
+	})
+
+	require.ErrorIs(t, err, errInvalidTxLine)
+	assert.Contains(t, err.Error(), "backup_0000001-0000100.jsonl:2")
+}
+
+func TestRejects_Lenient(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sourceDir, first, invalid = generateRejectSource(t)
+
+		outputDir   = t.TempDir()
+		rejectsPath = filepath.Join(t.TempDir(), "rejects.jsonl")
+	)
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFn()
+
+	require.NoError(t, execExtract(ctx, &extractorCfg{
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		outputDir: outputDir,
+		rejectsCfg: rejectsCfg{
+			rejectsPath: rejectsPath,
+		},
+	}))
+
+	// Both valid packages around the invalid line are extracted
+	numPackages := 0
+
+	require.NoError(t, filepath.WalkDir(outputDir, func(path string, _ fs.DirEntry, err error) error {
+		if err == nil && filepath.Base(path) == packageMetadataFile {
+			numPackages++
+		}
+
+		return err
+	}))
+
+	assert.Equal(t, 2, numPackages)
+
+	// Check the rejects file
+	file, err := os.Open(rejectsPath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	require.True(t, scanner.Scan())
+
+	var lineErr LineError
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &lineErr))
+
+	assert.Equal(t, filepath.Join(sourceDir, "backup_0000001-0000100.jsonl"), lineErr.File)
+	assert.Equal(t, 2, lineErr.Line)
+	assert.Equal(t, int64(len(first)+2), lineErr.Offset)
+	assert.Contains(t, lineErr.Err, "unknown")
+	assert.Equal(t, string(invalid[:maxSnippetLength]), lineErr.Snippet)
+
+	assert.False(t, scanner.Scan(), fmt.Sprintf("unexpected reject %s", scanner.Text()))
+}
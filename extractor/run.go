@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+)
+
+// runOutputDir is the output sub-directory for the extracted MsgRun packages
+const runOutputDir = "run"
+
+// RunPackage contains a vm.MsgRun, together with the transaction context where it appeared
+type RunPackage struct {
+	vm.MsgRun
+	Memo         string
+	Height       uint64
+	HeightSource heightSource // where the block height comes from
+	TxIndex      int
+	MsgIndex     int
+	SourceFile   string // the archive file holding the transaction
+}
+
+// runPackageFromMsg converts the transaction message of the source file into a RunPackage
+func runPackageFromMsg(txMsg TxMessage, sourceFile string) (RunPackage, error) {
+	msgRun, ok := txMsg.Msg.(vm.MsgRun)
+	if !ok {
+		return RunPackage{}, errors.New("could not cast into MsgRun")
+	}
+
+	if msgRun.Package == nil {
+		return RunPackage{}, errors.New("MsgRun is nil")
+	}
+
+	return RunPackage{
+		MsgRun:       msgRun,
+		Memo:         txMsg.Tx.Memo,
+		Height:       txMsg.Height,
+		HeightSource: txMsg.HeightSource,
+		TxIndex:      txMsg.TxIndex,
+		MsgIndex:     txMsg.MsgIndex,
+		SourceFile:   sourceFile,
+	}, nil
+}
+
+// RunMetadata defines the metadata info that accompanies
+// the gno source code of a MsgRun package
+type RunMetadata struct {
+	Caller       string `json:"caller"`        // the caller running the package
+	Send         string `json:"send"`          // the coins sent along with the run
+	MaxDeposit   string `json:"max_deposit"`   // the maximum storage deposit of the run
+	Memo         string `json:"memo"`          // the memo of the transaction
+	Height       uint64 `json:"height"`        // the block height of the run
+	HeightSource string `json:"height_source"` // block, file_range (the height is a lower bound) or unknown
+}
+
+// runMetadataFromMsg extracts the metadata from a MsgRun message
+func runMetadataFromMsg(msg RunPackage) RunMetadata {
+	return RunMetadata{
+		Caller:       msg.Caller.String(),
+		Send:         msg.Send.String(),
+		MaxDeposit:   msg.MaxDeposit.String(),
+		Memo:         msg.Memo,
+		Height:       msg.Height,
+		HeightSource: string(msg.HeightSource),
+	}
+}
+
+// runPackageDir returns the output directory for the given MsgRun package:
+// run/<caller>/<height>-<txindex>, with a -<msgindex> suffix
+// for every message except the first one in the transaction.
+// Without an exact block height, the tx index is the position of the tx
+// within its source file, so the dir is run/<caller>/<file>-<txindex> instead
+// (ie txexport-a-3), the file name being stripped of its extensions
+func runPackageDir(outputRoot string, run RunPackage) string {
+	name := strconv.FormatUint(run.Height, 10) + "-" + strconv.Itoa(run.TxIndex)
+
+	if run.HeightSource == heightSourceFileRange || run.HeightSource == heightSourceUnknown {
+		name = runSourceName(run.SourceFile) + "-" + strconv.Itoa(run.TxIndex)
+	}
+
+	if run.MsgIndex > 0 {
+		name += "-" + strconv.Itoa(run.MsgIndex)
+	}
+
+	return filepath.Join(outputRoot, runOutputDir, run.Caller.String(), name)
+}
+
+// runSourceName returns the source file name, without its compression and file type extensions
+func runSourceName(sourceFile string) string {
+	name := trimCompressionExt(filepath.Base(sourceFile))
+
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// writeRunPackage writes the MsgRun package files and metadata to the output directory
+func writeRunPackage(run RunPackage, outputRoot string) error {
+	outputDir := runPackageDir(outputRoot, run)
+
+	// Drop whatever a previous run left behind
+	if err := os.RemoveAll(outputDir); err != nil {
+		return fmt.Errorf("unable to clean run dir, %w", err)
+	}
+
+	// Write dir before writing files
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write dir, %w", err)
+	}
+
+	// Write the package source code
+	for _, file := range run.Package.Files {
+		if err := validateFileName(file.Name); err != nil {
+			return err
+		}
+
+		writePath := filepath.Join(outputDir, file.Name)
+
+		if err := os.WriteFile(writePath, []byte(file.Body), 0o644); err != nil {
+			return fmt.Errorf("unable to write file %s, %w", file.Name, err)
+		}
+	}
+
+	// Write the package metadata
+	return writePackageMetadata(runMetadataFromMsg(run), outputDir)
+}
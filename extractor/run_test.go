@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidFlow_Run(t *testing.T) {
+	t.Parallel()
+
+	var (
+		caller = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+
+		runMsg = vm.MsgRun{
+			Caller:     caller,
+			Send:       std.NewCoins(std.NewCoin("ugnot", 10)),
+			MaxDeposit: std.NewCoins(std.NewCoin("ugnot", 20)),
+			Package: &std.MemPackage{
+				Name: "main",
+				Files: []*std.MemFile{
+					{Name: "script.gno", Body: "package main\n\nfunc main() {}\n"},
+				},
+			},
+		}
+	)
+
+	generateSource := func(t *testing.T) string {
+		t.Helper()
+
+		sourceDir := t.TempDir()
+
+		file, err := os.Create(filepath.Join(sourceDir, "backup_0000001-0000100.jsonl"))
+		require.NoError(t, err)
+
+		// Two runs in the same block, the second one as the second tx message
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx:       std.Tx{Msgs: []std.Msg{runMsg}, Memo: "first"},
+			Metadata: &gnoland.GnoTxMetadata{BlockHeight: 42},
+		}, file))
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx:       std.Tx{Msgs: []std.Msg{runMsg, runMsg}, Memo: "second"},
+			Metadata: &gnoland.GnoTxMetadata{BlockHeight: 42},
+		}, file))
+		require.NoError(t, file.Close())
+
+		return sourceDir
+	}
+
+	t.Run("run packages are extracted", func(t *testing.T) {
+		t.Parallel()
+
+		outputDir := t.TempDir()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg:  sourceCfg{fileType: sourceFileType, sourcePath: generateSource(t)},
+			outputDir:  outputDir,
+			includeRun: true,
+		}))
+
+		callerDir := filepath.Join(outputDir, runOutputDir, caller.String())
+
+		for dir, memo := range map[string]string{
+			"42-0":   "first",
+			"42-1":   "second",
+			"42-1-1": "second",
+		} {
+			body, err := os.ReadFile(filepath.Join(callerDir, dir, "script.gno"))
+			require.NoError(t, err)
+			assert.Equal(t, runMsg.Package.Files[0].Body, string(body))
+
+			raw, err := os.ReadFile(filepath.Join(callerDir, dir, packageMetadataFile))
+			require.NoError(t, err)
+
+			var md RunMetadata
+			require.NoError(t, json.Unmarshal(raw, &md))
+
+			assert.Equal(t, RunMetadata{
+				Caller:       caller.String(),
+				Send:         "10ugnot",
+				MaxDeposit:   "20ugnot",
+				Memo:         memo,
+				Height:       42,
+				HeightSource: string(heightSourceBlock),
+			}, md)
+		}
+	})
+
+	t.Run("runs without block height are keyed by file", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			outputDir = t.TempDir()
+			sourceDir = t.TempDir()
+		)
+
+		// Bare tx lines carry no block height, so both runs are the first tx of their file
+		line, err := amino.MarshalJSON(std.Tx{Msgs: []std.Msg{runMsg}, Memo: "bare"})
+		require.NoError(t, err)
+
+		for _, name := range []string{"txexport-a.log", "txexport-b.log"} {
+			require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), append(line, '\n'), 0o644))
+		}
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg:  sourceCfg{fileType: ".log", sourcePath: sourceDir},
+			outputDir:  outputDir,
+			includeRun: true,
+		}))
+
+		entries, err := os.ReadDir(filepath.Join(outputDir, runOutputDir, caller.String()))
+		require.NoError(t, err)
+
+		dirs := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			dirs = append(dirs, entry.Name())
+		}
+
+		assert.Equal(t, []string{"txexport-a-0", "txexport-b-0"}, dirs)
+	})
+
+	t.Run("run packages are opt-in", func(t *testing.T) {
+		t.Parallel()
+
+		outputDir := t.TempDir()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: generateSource(t)},
+			outputDir: outputDir,
+		}))
+
+		assert.NoDirExists(t, filepath.Join(outputDir, runOutputDir))
+	})
+}
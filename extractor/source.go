@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"iter"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+)
+
+// archiveTx is the set of transaction formats found in the tx archives
+type archiveTx interface {
+	std.Tx | gnoland.TxWithMetadata | LegacyTx
+}
+
+// LegacyTx defines the tx sheet format written by the early
+// tx-archive versions (test2 - test4), where the block number
+// is stored alongside the transaction
+type LegacyTx struct {
+	Tx       std.Tx `json:"tx"`
+	BlockNum uint64 `json:"blockNum"`
+}
+
+// txFormat is the format of a single tx archive line
+type txFormat string
+
+// Define the tx archive line formats
+const (
+	// txFormatTx is a bare std.Tx (test1, test3 archive)
+	txFormatTx txFormat = "tx"
+
+	// txFormatLegacyTx is a LegacyTx, {"tx": ..., "blockNum": ...} (test2 - test4)
+	txFormatLegacyTx txFormat = "legacy_tx"
+
+	// txFormatTxWithMetadata is a gnoland.TxWithMetadata, {"tx": ..., "metadata": ...}
+	txFormatTxWithMetadata txFormat = "tx_with_metadata"
+)
+
+// archiveEntry is a single decoded tx archive line
+type archiveEntry struct {
+	tx           std.Tx
+	height       uint64       // the block height of the transaction, if known
+	heightSource heightSource // where the block height comes from
+	timestamp    int64        // the block timestamp of the transaction (unix seconds), if known
+
+	metadata *gnoland.GnoTxMetadata // the tx-archive metadata of the transaction, if any
+}
+
+// txDecodeFn decodes a single tx archive line
+type txDecodeFn func(line []byte) (archiveEntry, error)
+
+// txUnwrapper defines how the transactions of a single archive format are unwrapped
+type txUnwrapper[T archiveTx] struct {
+	txFn           func(T) std.Tx       // returns the transaction itself
+	heightFn       func(T) uint64       // returns the block height of the transaction
+	heightSourceFn func(T) heightSource // returns where the block height comes from (optional)
+	timestampFn    func(T) int64        // returns the block timestamp of the transaction (optional)
+
+	metadataFn func(T) *gnoland.GnoTxMetadata // returns the tx-archive metadata of the transaction (optional)
+}
+
+// decode decodes a single tx archive line of the unwrapper format
+func (u txUnwrapper[T]) decode(line []byte) (archiveEntry, error) {
+	var txData T
+
+	if err := amino.UnmarshalJSON(line, &txData); err != nil {
+		return archiveEntry{}, err
+	}
+
+	entry := archiveEntry{
+		tx:     u.txFn(txData),
+		height: u.heightFn(txData),
+	}
+
+	entry.heightSource = heightSourceOf(entry.height)
+	if u.heightSourceFn != nil {
+		entry.heightSource = u.heightSourceFn(txData)
+	}
+
+	if u.timestampFn != nil {
+		entry.timestamp = u.timestampFn(txData)
+	}
+
+	if u.metadataFn != nil {
+		entry.metadata = u.metadataFn(txData)
+	}
+
+	return entry, nil
+}
+
+// sniffTxFormat detects the format of a single tx archive line,
+// from its top level JSON fields
+func sniffTxFormat(line []byte) txFormat {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		// Let the tx-archive decoder report the error
+		return txFormatTxWithMetadata
+	}
+
+	if _, ok := fields["tx"]; !ok {
+		if _, ok := fields["msg"]; ok {
+			return txFormatTx
+		}
+
+		return txFormatTxWithMetadata
+	}
+
+	if _, ok := fields["blockNum"]; ok {
+		return txFormatLegacyTx
+	}
+
+	return txFormatTxWithMetadata
+}
+
+// autoDecoder decodes the lines of a single source file,
+// detecting the format of every line
+type autoDecoder struct {
+	// Block height used when the line has none,
+	// the start of the file block range
+	fallbackHeight uint64
+
+	formats map[txFormat]int // number of lines per detected format
+}
+
+// newAutoDecoder creates a new line decoder for the given source file
+func newAutoDecoder(sourceFile string) *autoDecoder {
+	d := &autoDecoder{
+		formats: make(map[txFormat]int),
+	}
+
+	if r, ok := blockRangeFromPath(sourceFile); ok {
+		d.fallbackHeight = r.from
+	}
+
+	return d
+}
+
+// decode detects the format of the line, and decodes it
+func (d *autoDecoder) decode(line []byte) (archiveEntry, error) {
+	format := sniffTxFormat(line)
+	d.formats[format]++
+
+	switch format {
+	case txFormatTx:
+		return txUnwrapper[std.Tx]{
+			txFn: func(tx std.Tx) std.Tx {
+				return tx
+			},
+			heightFn: func(_ std.Tx) uint64 {
+				// Bare txs carry no block information
+				return 0
+			},
+		}.decode(line)
+	case txFormatLegacyTx:
+		return txUnwrapper[LegacyTx]{
+			txFn: func(tx LegacyTx) std.Tx {
+				return tx.Tx
+			},
+			heightFn: func(tx LegacyTx) uint64 {
+				return tx.BlockNum
+			},
+		}.decode(line)
+	default:
+		return txUnwrapper[gnoland.TxWithMetadata]{
+			txFn: func(tx gnoland.TxWithMetadata) std.Tx {
+				return tx.Tx
+			},
+			heightFn: func(tx gnoland.TxWithMetadata) uint64 {
+				return heightFromMetadata(tx, d.fallbackHeight)
+			},
+			heightSourceFn: func(tx gnoland.TxWithMetadata) heightSource {
+				return heightSourceFromMetadata(tx, d.fallbackHeight)
+			},
+			timestampFn: func(tx gnoland.TxWithMetadata) int64 {
+				if tx.Metadata == nil {
+					return 0
+				}
+
+				return tx.Metadata.Timestamp
+			},
+			metadataFn: func(tx gnoland.TxWithMetadata) *gnoland.GnoTxMetadata {
+				return tx.Metadata
+			},
+		}.decode(line)
+	}
+}
+
+// format returns the detected format of the whole file:
+// the single format of all lines, "mixed" if there is more than one, or "empty"
+func (d *autoDecoder) format() string {
+	if len(d.formats) == 0 {
+		return "empty"
+	}
+
+	formats := make([]string, 0, len(d.formats))
+	for format := range d.formats {
+		formats = append(formats, string(format))
+	}
+
+	if len(formats) == 1 {
+		return formats[0]
+	}
+
+	sort.Strings(formats)
+
+	return "mixed (" + strings.Join(formats, ", ") + ")"
+}
+
+// parseFileTypes parses the comma separated file type patterns
+func parseFileTypes(fileType string) []string {
+	fileTypes := make([]string, 0)
+
+	for _, pattern := range strings.Split(fileType, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			fileTypes = append(fileTypes, pattern)
+		}
+	}
+
+	return fileTypes
+}
+
+// matchFileType checks if the file name matches any of the file type patterns.
+// A pattern is either a file name suffix (ie .jsonl), or a glob (ie backup_*.jsonl).
+// Compressed files match the patterns of their uncompressed name (ie .jsonl.gz matches .jsonl)
+func matchFileType(name string, fileTypes []string) bool {
+	names := []string{name}
+	if trimmed := trimCompressionExt(name); trimmed != name {
+		names = append(names, trimmed)
+	}
+
+	for _, fileType := range fileTypes {
+		for _, name := range names {
+			if !strings.ContainsAny(fileType, "*?[") {
+				if strings.HasSuffix(name, fileType) {
+					return true
+				}
+
+				continue
+			}
+
+			if matched, _ := filepath.Match(fileType, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Define source config, shared by the commands reading a tx archive
+type sourceCfg struct {
+	fileType   string
+	sourcePath string
+}
+
+// registerFlags registers the source flag set
+func (c *sourceCfg) registerFlags(fs *flag.FlagSet) {
+	registerFileTypeFlag(fs, &c.fileType)
+
+	fs.StringVar(
+		&c.sourcePath,
+		"source-path",
+		"",
+		"the source file or folder containing transaction data",
+	)
+}
+
+// validate checks the file type and source path are set
+func (c *sourceCfg) validate() error {
+	// Check the file type is valid
+	if c.fileType == "" {
+		return errInvalidFileType
+	}
+
+	// Check the source dir is valid
+	if c.sourcePath == "" {
+		return errInvalidSourceDir
+	}
+
+	return nil
+}
+
+// registerFileTypeFlag registers the archive file type flag
+func registerFileTypeFlag(fs *flag.FlagSet, fileType *string) {
+	fs.StringVar(
+		fileType,
+		"file-type",
+		".jsonl",
+		"the comma separated file types for analysis, with a preceding period (ie .jsonl,.log), compressed files of these types (.gz, .zst, .xz) are included",
+	)
+}
+
+// findSourceFiles gathers the source files from the source path,
+// which can either be a single file or a directory.
+// The file type can hold several comma separated patterns (ie .jsonl,.log).
+// The staging balances file of a directory shares the archive file type,
+// but holds no tx, and is left out
+func findSourceFiles(sourcePath, fileType string) ([]string, error) {
+	// Check if source is valid
+	source, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat source path, %w", err)
+	}
+
+	sourceFiles := []string{sourcePath}
+
+	// If source is dir, walk it and add to sourceFiles
+	if source.IsDir() {
+		sourceFiles, err = findFilePaths(sourcePath, parseFileTypes(fileType)...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find file paths, %w", err)
+		}
+
+		sourceFiles = slices.DeleteFunc(sourceFiles, isStagingBalancesFile)
+	}
+
+	if len(sourceFiles) == 0 {
+		return nil, errNoSourceFilesFound
+	}
+
+	return sourceFiles, nil
+}
+
+// isStagingBalancesFile checks if the path is the staging balances file,
+// compressed or not
+func isStagingBalancesFile(path string) bool {
+	return trimCompressionExt(filepath.Base(path)) == stagingBalancesFile
+}
+
+// fileMessages yields the transaction messages of the given types
+// from a single source file, detecting the archive format of every line.
+// Undecodable lines are passed to the reject handler, if any
+func fileMessages(sourceFile string, rejects *rejectHandler, msgTypes ...string) iter.Seq2[TxMessage, error] {
+	return fileMessagesFrom(sourceFile, resumePosition{}, nil, rejects, msgTypes...)
+}
+
+// filePackages yields the package deployments of a single source file,
+// the streaming counterpart of extractAddMessages
+func filePackages(sourceFile string, rejects *rejectHandler) iter.Seq2[AddPackage, error] {
+	return func(yield func(AddPackage, error) bool) {
+		for txMsg, err := range fileMessages(sourceFile, rejects, msgTypeAddPackage) {
+			if err != nil {
+				yield(AddPackage{}, err)
+
+				return
+			}
+
+			msg, err := addPackageFromMsg(txMsg)
+			if !yield(msg, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// fileMessagesFrom yields the transaction messages of the given types
+// from a single source file, starting at the given position.
+// The end function is called with the tx counters at the end of the file
+func fileMessagesFrom(
+	sourceFile string,
+	start resumePosition,
+	endFn func(txCounters),
+	rejects *rejectHandler,
+	msgTypes ...string,
+) iter.Seq2[TxMessage, error] {
+	var (
+		decoder = newAutoDecoder(sourceFile)
+		opts    = streamOpts{
+			decodeFn: decoder.decode,
+			msgTypes: msgTypes,
+			offset:   start.offset,
+			line:     start.line,
+			counters: start.counters,
+			endFn:    endFn,
+		}
+	)
+
+	if rejects != nil {
+		opts.rejectFn = rejects.reject
+		opts.maxLineSize = rejects.maxLineSize
+	}
+
+	return func(yield func(TxMessage, error) bool) {
+		for txMsg, err := range streamFileMessages(sourceFile, opts) {
+			if !yield(txMsg, err) || err != nil {
+				return
+			}
+		}
+
+		slog.Info("detected tx sheet format", "file", sourceFile, "format", decoder.format())
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffTxFormat(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		line     string
+		expected txFormat
+	}{
+		{
+			"bare tx",
+			`{"msg":[],"fee":{"gas_wanted":"1","gas_fee":"1ugnot"},"signatures":null,"memo":""}`,
+			txFormatTx,
+		},
+		{
+			"legacy tx",
+			`{"tx":{"msg":[]},"blockNum":"2"}`,
+			txFormatLegacyTx,
+		},
+		{
+			"tx with metadata",
+			`{"tx":{"msg":[]},"metadata":{"timestamp":"1786139574"}}`,
+			txFormatTxWithMetadata,
+		},
+		{
+			"tx without metadata",
+			`{"tx":{"msg":[]}}`,
+			txFormatTxWithMetadata,
+		},
+		{
+			"invalid JSON",
+			`{"tx":`,
+			txFormatTxWithMetadata,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, sniffTxFormat([]byte(testCase.line)))
+		})
+	}
+}
+
+func TestExtractFileMessages_MixedFormats(t *testing.T) {
+	t.Parallel()
+
+	_, mockAddPkgMsg := generateMockMsgs(t)
+	require.GreaterOrEqual(t, len(mockAddPkgMsg), 3)
+
+	var (
+		filePath = filepath.Join(t.TempDir(), "backup_0000010-0000100.jsonl")
+		lines    []byte
+	)
+
+	for _, tx := range []any{
+		std.Tx{Msgs: []std.Msg{mockAddPkgMsg[0]}},
+		LegacyTx{Tx: std.Tx{Msgs: []std.Msg{mockAddPkgMsg[1]}}, BlockNum: 20},
+		gnoland.TxWithMetadata{
+			Tx:       std.Tx{Msgs: []std.Msg{mockAddPkgMsg[2]}},
+			Metadata: &gnoland.GnoTxMetadata{Timestamp: 1786139574},
+		},
+	} {
+		data, err := amino.MarshalJSON(tx)
+		require.NoError(t, err)
+
+		lines = append(lines, data...)
+		lines = append(lines, '\n')
+	}
+
+	require.NoError(t, os.WriteFile(filePath, lines, 0o644))
+
+	decoder := newAutoDecoder(filePath)
+
+	msgs, err := extractMessages(filePath, decoder.decode, nil, msgTypeAddPackage)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+
+	assert.Equal(t, "mixed (legacy_tx, tx, tx_with_metadata)", decoder.format())
+
+	for i, expected := range []struct {
+		height    uint64
+		timestamp int64
+	}{
+		{0, 0},
+		{20, 0},
+		{10, 1786139574},
+	} {
+		assert.Equal(t, mockAddPkgMsg[i], msgs[i].Msg)
+		assert.Equal(t, expected.height, msgs[i].Height)
+		assert.Equal(t, expected.timestamp, msgs[i].Timestamp)
+	}
+}
+
+func TestFindSourceFiles_StagingBalances(t *testing.T) {
+	t.Parallel()
+
+	sourceDir := writeBackupFiles(t, map[string]string{
+		"backup_0000001-0000010.jsonl": "",
+		stagingBalancesFile:            "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5=10ugnot\n",
+	})
+
+	// The staging balances file holds no tx
+	sourceFiles, err := findSourceFiles(sourceDir, ".jsonl")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join(sourceDir, "backup_0000001-0000010.jsonl")}, sourceFiles)
+
+	// A file source path is kept as is
+	balancesPath := filepath.Join(sourceDir, stagingBalancesFile)
+
+	sourceFiles, err = findSourceFiles(balancesPath, ".jsonl")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{balancesPath}, sourceFiles)
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Define the extractor state constants
+const (
+	extractorStateFile    = "extractor-state.json"
+	extractorStateVersion = 2
+)
+
+// ExtractorState is the checkpoint of the last extraction (extractor-state.json),
+// kept in the output directory. The extracted content is the concatenation of
+// all source files, in order. The next incremental extraction resumes right after
+// it, as long as it is still the beginning of the source files: new files and
+// content appended to the last file (ie by make join) are then all that is extracted
+type ExtractorState struct {
+	Version    int         `json:"version"`
+	IncludeRun bool        `json:"include_run"`
+	GenModules bool        `json:"gen_modules"`
+	Size       int64       `json:"size"`        // the size of the extracted content
+	LastHeight uint64      `json:"last_height"` // the block height of the last extracted tx
+	TxIndex    int         `json:"tx_index"`    // the index of the next tx, if it has the last block height
+	Files      []StateFile `json:"files"`       // the extracted source files, in order
+}
+
+// StateFile is a single extracted source file
+type StateFile struct {
+	Path           string `json:"path"` // relative to the source path
+	Size           int64  `json:"size"` // the size of the (decompressed) content
+	Lines          int    `json:"lines"`
+	LastLineOffset int64  `json:"last_line_offset"` // the byte offset of the last line start
+	LastLineSHA256 string `json:"last_line_sha256"` // the hash of the last line, with its line ending
+	FileSize       int64  `json:"file_size"`        // the size of the file on disk
+	ModTime        int64  `json:"mod_time"`         // the modification time of the file (unix nanoseconds)
+}
+
+// resumePosition is the position in the source files where an extraction resumes
+type resumePosition struct {
+	fileIndex int        // the index of the first source file with content left
+	offset    int64      // the byte offset of the content left in that file
+	line      int        // the number of lines before the offset
+	counters  txCounters // the tx counters at the offset
+}
+
+// readExtractorState reads the extractor state from the output directory,
+// returning nil if there is none
+func readExtractorState(outputDir string) (*ExtractorState, error) {
+	raw, err := os.ReadFile(filepath.Join(outputDir, extractorStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read extractor state, %w", err)
+	}
+
+	var state ExtractorState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("unable to JSON unmarshal extractor state, %w", err)
+	}
+
+	return &state, nil
+}
+
+// removeExtractorState removes the extractor state from the output directory, if any
+func removeExtractorState(outputDir string) error {
+	err := os.Remove(filepath.Join(outputDir, extractorStateFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove extractor state, %w", err)
+	}
+
+	return nil
+}
+
+// resumeExtraction returns the position the incremental extraction resumes at,
+// or nil if the source files have to be extracted from the start.
+// The state of the previous extraction is returned as well, if it is still valid
+func resumeExtraction(
+	outputDir,
+	sourcePath string,
+	sourceFiles []string,
+	includeRun,
+	genModules bool,
+) (*ExtractorState, *resumePosition, error) {
+	state, err := readExtractorState(outputDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if state == nil {
+		slog.Info("no extractor state found, extracting all source files")
+
+		return nil, nil, nil
+	}
+
+	if state.Version != extractorStateVersion || state.IncludeRun != includeRun || state.GenModules != genModules {
+		slog.Info("extractor state is outdated, extracting all source files")
+
+		return nil, nil, nil
+	}
+
+	pos, ok, err := state.resume(sourcePath, sourceFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !ok {
+		slog.Info("extracted source content was changed, extracting all source files")
+
+		return state, nil, nil
+	}
+
+	if pos.fileIndex == len(sourceFiles) {
+		slog.Info("no new source content since the last extraction")
+	} else {
+		slog.Info(
+			"resuming extraction",
+			"file", sourceFiles[pos.fileIndex],
+			"line", pos.line+1,
+			"offset", pos.offset,
+		)
+	}
+
+	return state, &pos, nil
+}
+
+// counters returns the tx counters at the end of the extracted content
+func (s *ExtractorState) counters() txCounters {
+	return txCounters{
+		lastHeight: s.LastHeight,
+		txIndex:    s.TxIndex,
+	}
+}
+
+// writeExtractorState atomically writes the extractor state to the output directory
+func writeExtractorState(state *ExtractorState, outputDir string) error {
+	stateRaw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to JSON marshal extractor state, %w", err)
+	}
+
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write dir, %w", err)
+	}
+
+	var (
+		writePath = filepath.Join(outputDir, extractorStateFile)
+		tmpPath   = writePath + ".tmp"
+	)
+
+	if err := os.WriteFile(tmpPath, stateRaw, 0o644); err != nil {
+		return fmt.Errorf("unable to write extractor state, %w", err)
+	}
+
+	if err := os.Rename(tmpPath, writePath); err != nil {
+		return fmt.Errorf("unable to write extractor state, %w", err)
+	}
+
+	return nil
+}
+
+// newExtractorState computes the state of an extraction of all the given source files.
+// The files unchanged on disk since the previous state keep their previous record,
+// the other ones are scanned
+func newExtractorState(
+	sourcePath string,
+	sourceFiles []string,
+	includeRun,
+	genModules bool,
+	previous *ExtractorState,
+) (*ExtractorState, error) {
+	var (
+		known = make(map[string]StateFile)
+		state = &ExtractorState{
+			Version:    extractorStateVersion,
+			IncludeRun: includeRun,
+			GenModules: genModules,
+			Files:      make([]StateFile, 0, len(sourceFiles)),
+		}
+	)
+
+	if previous != nil {
+		for _, file := range previous.Files {
+			known[file.Path] = file
+		}
+	}
+
+	for _, sourceFile := range sourceFiles {
+		info, err := os.Stat(sourceFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to stat source file %s, %w", sourceFile, err)
+		}
+
+		path := relativeSourcePath(sourcePath, sourceFile)
+
+		file, ok := known[path]
+		if !ok || !file.unchanged(info) {
+			if file, err = scanSourceFile(sourceFile); err != nil {
+				return nil, err
+			}
+
+			file.Path = path
+			file.FileSize = info.Size()
+			file.ModTime = info.ModTime().UnixNano()
+		}
+
+		state.Size += file.Size
+		state.Files = append(state.Files, file)
+	}
+
+	return state, nil
+}
+
+// scanSourceFile counts the lines of the (decompressed) content of a single
+// source file, and hashes its last line
+func scanSourceFile(sourceFile string) (StateFile, error) {
+	archive, err := openArchive(sourceFile)
+	if err != nil {
+		return StateFile{}, err
+	}
+	defer archive.Close()
+
+	var (
+		reader    = bufio.NewReader(archive)
+		lineHash  = sha256.New()
+		lineStart = true
+
+		file StateFile
+	)
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+
+		if len(chunk) > 0 {
+			if lineStart {
+				lineHash.Reset()
+
+				file.LastLineOffset = file.Size
+				file.Lines++
+			}
+
+			lineHash.Write(chunk)
+
+			file.Size += int64(len(chunk))
+			lineStart = chunk[len(chunk)-1] == '\n'
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return StateFile{}, fmt.Errorf("unable to scan source file %s, %w", sourceFile, err)
+		}
+	}
+
+	file.LastLineSHA256 = hex.EncodeToString(lineHash.Sum(nil))
+
+	return file, nil
+}
+
+// relativeSourcePath returns the path of the source file, relative to the source path
+func relativeSourcePath(sourcePath, sourceFile string) string {
+	rel, err := filepath.Rel(sourcePath, sourceFile)
+	if err != nil || rel == "." {
+		return filepath.Base(sourceFile)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// unchanged checks if the file on disk is the same as when it was extracted
+func (f StateFile) unchanged(info os.FileInfo) bool {
+	return f.FileSize == info.Size() && f.ModTime == info.ModTime().UnixNano()
+}
+
+// resume finds where the extraction of the given source files resumes.
+// The source files unchanged on disk are skipped, while the other ones
+// are checked to still start with the extracted files, comparing their last lines.
+// Returns false if the extracted content is no longer the beginning
+// of the source files (ie an extracted file was changed or removed)
+func (s *ExtractorState) resume(sourcePath string, sourceFiles []string) (resumePosition, bool, error) {
+	extracted := s.Files
+
+	for i, sourceFile := range sourceFiles {
+		if len(extracted) == 0 {
+			return resumePosition{fileIndex: i}, true, nil
+		}
+
+		info, err := os.Stat(sourceFile)
+		if err != nil {
+			return resumePosition{}, false, fmt.Errorf("unable to stat source file %s, %w", sourceFile, err)
+		}
+
+		if extracted[0].Path == relativeSourcePath(sourcePath, sourceFile) && extracted[0].unchanged(info) {
+			extracted = extracted[1:]
+
+			continue
+		}
+
+		found, pos, more, ok, err := matchExtracted(sourceFile, extracted)
+		if err != nil || !ok {
+			return resumePosition{}, false, err
+		}
+
+		extracted = extracted[found:]
+
+		if !more {
+			continue
+		}
+
+		if len(extracted) > 0 {
+			// New content comes before the rest of the extracted content
+			return resumePosition{}, false, nil
+		}
+
+		pos.fileIndex = i
+		pos.counters = s.counters()
+
+		return pos, true, nil
+	}
+
+	if len(extracted) > 0 {
+		// Extracted content was removed
+		return resumePosition{}, false, nil
+	}
+
+	return resumePosition{fileIndex: len(sourceFiles)}, true, nil
+}
+
+// matchExtracted checks that the source file starts with the content of the
+// given extracted files (more than one once joined), comparing their last lines.
+// Returns the number of extracted files found, the position right after them,
+// and whether the source file has content left
+func matchExtracted(sourceFile string, extracted []StateFile) (int, resumePosition, bool, bool, error) {
+	archive, err := openArchive(sourceFile)
+	if err != nil {
+		return 0, resumePosition{}, false, false, err
+	}
+	defer archive.Close()
+
+	var (
+		reader = bufio.NewReader(archive)
+
+		found int
+		pos   resumePosition
+	)
+
+	for found < len(extracted) {
+		file := extracted[found]
+
+		// Only the last line of the extracted file is compared
+		if _, err := reader.Discard(int(file.LastLineOffset)); err != nil {
+			return 0, resumePosition{}, false, false, readMismatch(sourceFile, err)
+		}
+
+		lastLine := make([]byte, file.Size-file.LastLineOffset)
+		if _, err := io.ReadFull(reader, lastLine); err != nil {
+			return 0, resumePosition{}, false, false, readMismatch(sourceFile, err)
+		}
+
+		hash := sha256.Sum256(lastLine)
+		if hex.EncodeToString(hash[:]) != file.LastLineSHA256 {
+			return 0, resumePosition{}, false, false, nil
+		}
+
+		found++
+
+		pos.offset += file.Size
+		pos.line += file.Lines
+
+		// Check if the file has content left
+		_, err := reader.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return found, pos, false, true, nil
+		}
+
+		if err != nil {
+			return 0, resumePosition{}, false, false, fmt.Errorf("unable to read source file %s, %w", sourceFile, err)
+		}
+
+		// The extraction can only resume at the start of a line
+		if len(lastLine) > 0 && lastLine[len(lastLine)-1] != '\n' {
+			return 0, resumePosition{}, false, false, nil
+		}
+	}
+
+	return found, pos, true, true, nil
+}
+
+// readMismatch returns the read error, unless the source file is
+// simply shorter than the extracted content (a mismatch, not an error)
+func readMismatch(sourceFile string, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+
+	return fmt.Errorf("unable to read source file %s, %w", sourceFile, err)
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copySourceFiles concatenates the given source files into the destination file
+func copySourceFiles(t *testing.T, destination string, sources ...string) {
+	t.Helper()
+
+	var content []byte
+
+	for _, source := range sources {
+		raw, err := os.ReadFile(source)
+		require.NoError(t, err)
+
+		content = append(content, raw...)
+	}
+
+	require.NoError(t, os.WriteFile(destination, content, 0o644))
+}
+
+func TestValidFlow_Incremental(t *testing.T) {
+	t.Parallel()
+
+	// backup_0000001-0001000.jsonl ... backup_0003001-0004000.jsonl
+	generatedDir := t.TempDir()
+	generateVersionedSourceFiles(t, generatedDir, 4, 10)
+
+	generated, err := findFilePaths(generatedDir, sourceFileType)
+	require.NoError(t, err)
+	require.Len(t, generated, 4)
+
+	extract := func(sourceDir, outputDir string, incremental bool) map[string]string {
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		require.NoError(t, execExtract(ctx, &extractorCfg{
+			sourceCfg:   sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+			outputDir:   outputDir,
+			incremental: incremental,
+		}))
+
+		return readTree(t, outputDir)
+	}
+
+	// setup extracts the first three generated files incrementally
+	setup := func(t *testing.T) (string, string) {
+		t.Helper()
+
+		var (
+			sourceDir = t.TempDir()
+			outputDir = t.TempDir()
+		)
+
+		for _, sourceFile := range generated[:3] {
+			copySourceFiles(t, filepath.Join(sourceDir, filepath.Base(sourceFile)), sourceFile)
+		}
+
+		extract(sourceDir, outputDir, true)
+
+		return sourceDir, outputDir
+	}
+
+	t.Run("new file", func(t *testing.T) {
+		t.Parallel()
+
+		sourceDir, outputDir := setup(t)
+
+		copySourceFiles(t, filepath.Join(sourceDir, filepath.Base(generated[3])), generated[3])
+
+		assert.Equal(t, extract(sourceDir, t.TempDir(), false), extract(sourceDir, outputDir, true))
+	})
+
+	t.Run("joined files", func(t *testing.T) {
+		t.Parallel()
+
+		sourceDir, outputDir := setup(t)
+
+		// make join merges the last extracted file with the new one
+		lastFile := filepath.Join(sourceDir, filepath.Base(generated[2]))
+
+		require.NoError(t, os.Remove(lastFile))
+		copySourceFiles(t, filepath.Join(sourceDir, "backup_0002001-0004000.jsonl"), generated[2], generated[3])
+
+		sourceFiles, err := findFilePaths(sourceDir, sourceFileType)
+		require.NoError(t, err)
+
+		state, err := readExtractorState(outputDir)
+		require.NoError(t, err)
+		require.NotNil(t, state)
+
+		lastInfo, err := os.Stat(generated[2])
+		require.NoError(t, err)
+
+		pos, ok, err := state.resume(sourceDir, sourceFiles)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		// The last extracted tx is the tenth one of the third file
+		assert.Equal(t, resumePosition{
+			fileIndex: 2,
+			offset:    lastInfo.Size(),
+			line:      10,
+			counters:  txCounters{lastHeight: 2010, txIndex: 1},
+		}, pos)
+
+		assert.Equal(t, extract(sourceDir, t.TempDir(), false), extract(sourceDir, outputDir, true))
+	})
+
+	t.Run("appended txs of the last block", func(t *testing.T) {
+		t.Parallel()
+
+		sourceDir, outputDir := setup(t)
+
+		// The tx indexes carry on from the last extracted tx of block 2010
+		file, err := os.OpenFile(filepath.Join(sourceDir, filepath.Base(generated[2])), os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+
+		for _, name := range []string{"qux", "quux"} {
+			require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+				Tx: std.Tx{
+					Msgs: []std.Msg{
+						vm.MsgAddPackage{
+							Creator: addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5"),
+							Package: &std.MemPackage{
+								Name:  name,
+								Path:  "gno.land/r/demo/" + name,
+								Files: []*std.MemFile{{Name: "file.gno", Body: "package " + name}},
+							},
+						},
+					},
+				},
+				Metadata: &gnoland.GnoTxMetadata{
+					BlockHeight: 2010,
+				},
+			}, file))
+		}
+
+		require.NoError(t, file.Close())
+
+		incremental := extract(sourceDir, outputDir, true)
+
+		assert.Equal(t, extract(sourceDir, t.TempDir(), false), incremental)
+		assert.Equal(t, 2, readMetadata(t, filepath.Join(outputDir, "r", "demo", "quux")).TxIndex)
+	})
+
+	t.Run("changed file", func(t *testing.T) {
+		t.Parallel()
+
+		sourceDir, outputDir := setup(t)
+
+		// The first file is replaced by another one
+		copySourceFiles(t, filepath.Join(sourceDir, filepath.Base(generated[0])), generated[3])
+
+		assert.Equal(t, extract(sourceDir, t.TempDir(), false), extract(sourceDir, outputDir, true))
+	})
+
+	t.Run("no new content", func(t *testing.T) {
+		t.Parallel()
+
+		sourceDir, outputDir := setup(t)
+
+		expected := readTree(t, outputDir)
+
+		assert.Equal(t, expected, extract(sourceDir, outputDir, true))
+	})
+}
+
+func TestExtractorState_Resume(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name      string
+		extracted []string
+		current   []string
+		expected  resumePosition
+		valid     bool
+	}{
+		{
+			"no change",
+			[]string{"a\nb\n"},
+			[]string{"a\nb\n"},
+			resumePosition{fileIndex: 1},
+			true,
+		},
+		{
+			"new file",
+			[]string{"a\n", "b\n"},
+			[]string{"a\n", "b\n", "c\n"},
+			resumePosition{fileIndex: 2},
+			true,
+		},
+		{
+			"appended content",
+			[]string{"a\n", "b\n"},
+			[]string{"a\n", "b\nc\nd\n"},
+			resumePosition{fileIndex: 1, offset: 2, line: 1},
+			true,
+		},
+		{
+			"joined files",
+			[]string{"a\n", "b\n"},
+			[]string{"a\nb\nc\n"},
+			resumePosition{fileIndex: 0, offset: 4, line: 2},
+			true,
+		},
+		{
+			"unterminated line",
+			[]string{"a\n", "b"},
+			[]string{"a\n", "bc\n"},
+			resumePosition{},
+			false,
+		},
+		{
+			"changed content",
+			[]string{"a\n", "b\n"},
+			[]string{"a\n", "c\n", "d\n"},
+			resumePosition{fileIndex: 2},
+			false,
+		},
+		{
+			"removed content",
+			[]string{"a\n", "b\n"},
+			[]string{"a\n"},
+			resumePosition{},
+			false,
+		},
+	}
+
+	// The files of a single test case have the same names, but
+	// their modification times differ, so the content is compared
+	writeFiles := func(t *testing.T, contents []string, modTime time.Time) []string {
+		t.Helper()
+
+		var (
+			dir   = t.TempDir()
+			files = make([]string, 0, len(contents))
+		)
+
+		for i, content := range contents {
+			file := filepath.Join(dir, string(rune('a'+i))+sourceFileType)
+			require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+			require.NoError(t, os.Chtimes(file, modTime, modTime))
+
+			files = append(files, file)
+		}
+
+		return files
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				extracted = writeFiles(t, testCase.extracted, time.Unix(1_700_000_000, 0))
+				current   = writeFiles(t, testCase.current, time.Unix(1_700_000_060, 0))
+			)
+
+			state, err := newExtractorState(filepath.Dir(extracted[0]), extracted, false, false, nil)
+			require.NoError(t, err)
+
+			pos, ok, err := state.resume(filepath.Dir(current[0]), current)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.valid, ok)
+
+			if testCase.valid {
+				assert.Equal(t, testCase.expected, pos)
+			}
+		})
+	}
+}
+
+func TestNewExtractorState(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir  = t.TempDir()
+		file = filepath.Join(dir, "nested", "backup_0000001-0001000.jsonl")
+	)
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+	require.NoError(t, os.WriteFile(file, []byte("first\nsecond\nthird"), 0o644))
+
+	state, err := newExtractorState(dir, []string{file}, true, false, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, extractorStateVersion, state.Version)
+	assert.True(t, state.IncludeRun)
+	assert.Equal(t, int64(18), state.Size)
+
+	require.Len(t, state.Files, 1)
+	assert.Equal(t, "nested/backup_0000001-0001000.jsonl", state.Files[0].Path)
+	assert.Equal(t, int64(18), state.Files[0].Size)
+	assert.Equal(t, 3, state.Files[0].Lines)
+	assert.Equal(t, int64(13), state.Files[0].LastLineOffset)
+
+	lastLine := sha256.Sum256([]byte("third"))
+	assert.Equal(t, hex.EncodeToString(lastLine[:]), state.Files[0].LastLineSHA256)
+
+	// A file unchanged on disk is not scanned again
+	known := state.Files[0]
+	known.Lines = 42
+
+	state, err = newExtractorState(dir, []string{file}, true, false, &ExtractorState{Files: []StateFile{known}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []StateFile{known}, state.Files)
+
+	// A changed file is
+	require.NoError(t, os.WriteFile(file, []byte("first\nsecond\nthird\nfourth\n"), 0o644))
+	require.NoError(t, os.Chtimes(file, time.Unix(1_700_000_000, 0), time.Unix(1_700_000_000, 0)))
+
+	state, err = newExtractorState(dir, []string{file}, true, false, &ExtractorState{Files: []StateFile{known}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, state.Files[0].Lines)
+	assert.Equal(t, int64(19), state.Files[0].LastLineOffset)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define the stats output formats
+const (
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+)
+
+// Define stats defaults
+const (
+	// defaultFaucetAddress is the test2-era faucet address
+	defaultFaucetAddress = "g127jydsh6cms3lrtdenydxsckh23a8d6emqcvfa"
+
+	// defaultFaucetMinAmount is the minimum total amount (in ugnot)
+	// for a faucet requester to be listed in the stats
+	defaultFaucetMinAmount = 500_000_000
+
+	faucetDenom = "ugnot"
+)
+
+// Define stats config
+type statsCfg struct {
+	outputPath string
+	format     string
+	title      string
+
+	faucetAddress   string
+	faucetMinAmount int64
+
+	sourceCfg
+	rejectsCfg
+}
+
+// newStatsCmd creates the archive stats command
+func newStatsCmd() *ffcli.Command {
+	var (
+		cfg = &statsCfg{}
+		fs  = flag.NewFlagSet("stats", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "stats",
+		ShortUsage: "stats [flags]",
+		ShortHelp:  "computes the transaction data stats",
+		LongHelp:   "Computes the tx count, addpkg counts, top realm calls and top faucet requesters of the transaction data, as Markdown or JSON",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execStats(ctx, cfg, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the stats command flag set
+func (c *statsCfg) registerFlags(fs *flag.FlagSet) {
+	c.sourceCfg.registerFlags(fs)
+
+	fs.StringVar(
+		&c.outputPath,
+		"output-path",
+		"",
+		"the output file for the stats (stdout if empty)",
+	)
+
+	fs.StringVar(
+		&c.format,
+		"format",
+		formatMarkdown,
+		fmt.Sprintf("the stats output format (%s, %s)", formatMarkdown, formatJSON),
+	)
+
+	fs.StringVar(
+		&c.title,
+		"title",
+		"",
+		"the title of the Markdown stats (ie the chain remote)",
+	)
+
+	fs.StringVar(
+		&c.faucetAddress,
+		"faucet",
+		defaultFaucetAddress,
+		"the faucet address for the top faucet requesters",
+	)
+
+	fs.Int64Var(
+		&c.faucetMinAmount,
+		"faucet-min",
+		defaultFaucetMinAmount,
+		"the minimum total amount (in ugnot) for a faucet requester to be listed",
+	)
+
+	c.rejectsCfg.registerFlags(fs)
+}
+
+// execStats runs the archive stats
+func execStats(ctx context.Context, cfg *statsCfg, stdout io.Writer) error {
+	// Check the source is valid
+	if err := cfg.sourceCfg.validate(); err != nil {
+		return err
+	}
+
+	// Check the output format is valid
+	if cfg.format != formatMarkdown && cfg.format != formatJSON {
+		return fmt.Errorf("%w %q", errInvalidFormat, cfg.format)
+	}
+
+	sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	rejects, err := newRejectHandler(cfg.rejectsCfg)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
+
+	var (
+		addPkgs  = make(map[string]int)
+		calls    = make(map[string]int)
+		requests = make(map[string]*FaucetRequester)
+
+		stats = Stats{
+			Title: cfg.title,
+		}
+	)
+
+	for _, sourceFile := range sourceFiles {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			// Every message type is needed for the tx count
+			for txMsg, err := range fileMessages(sourceFile, rejects) {
+				if err != nil {
+					return err
+				}
+
+				if txMsg.MsgIndex == 0 {
+					stats.Txs++
+				}
+
+				switch msg := txMsg.Msg.(type) {
+				case vm.MsgAddPackage:
+					if msg.Package != nil {
+						addPkgs[msg.Package.Path]++
+					}
+				case vm.MsgCall:
+					calls[msg.PkgPath]++
+				case bank.MsgSend:
+					if msg.FromAddress.String() != cfg.faucetAddress {
+						continue
+					}
+
+					to := msg.ToAddress.String()
+
+					requester, ok := requests[to]
+					if !ok {
+						requester = &FaucetRequester{
+							Address: to,
+						}
+
+						requests[to] = requester
+					}
+
+					requester.Requests++
+					requester.Amount += msg.Amount.AmountOf(faucetDenom)
+				}
+			}
+		}
+	}
+
+	if err := rejects.close(); err != nil {
+		return err
+	}
+
+	stats.AddPackages = sortedPathCounts(addPkgs)
+	stats.RealmCalls = sortedPathCounts(calls)
+	stats.FaucetRequesters = topFaucetRequesters(requests, cfg.faucetMinAmount)
+
+	return writeOutput(cfg.outputPath, stdout, func(out io.Writer) error {
+		if cfg.format == formatJSON {
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+
+			if err := encoder.Encode(stats); err != nil {
+				return fmt.Errorf("unable to write stats, %w", err)
+			}
+
+			return nil
+		}
+
+		if _, err := io.WriteString(out, renderStatsMarkdown(stats)); err != nil {
+			return fmt.Errorf("unable to write stats, %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Stats defines the transaction data stats of a chain archive
+type Stats struct {
+	Title            string            `json:"title"`             // the stats title (ie the chain remote)
+	Txs              int               `json:"txs"`               // the number of transactions
+	AddPackages      []PathCount       `json:"addpkgs"`           // the number of deployments per package path
+	RealmCalls       []PathCount       `json:"realm_calls"`       // the number of calls per package path
+	FaucetRequesters []FaucetRequester `json:"faucet_requesters"` // the top faucet requesters
+}
+
+// PathCount defines the number of messages for a single package path
+type PathCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// FaucetRequester defines the faucet transfers to a single address
+type FaucetRequester struct {
+	Address  string `json:"address"`  // the requester address
+	Requests int    `json:"requests"` // the number of faucet transfers
+	Amount   int64  `json:"amount"`   // the total transferred amount, in ugnot
+}
+
+// sortedPathCounts sorts the path counts by count (descending), then by path
+func sortedPathCounts(counts map[string]int) []PathCount {
+	sorted := make([]PathCount, 0, len(counts))
+	for path, count := range counts {
+		sorted = append(sorted, PathCount{
+			Path:  path,
+			Count: count,
+		})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	return sorted
+}
+
+// topFaucetRequesters returns the faucet requesters that received at least
+// the minimum amount, sorted by request count (descending), then by address
+func topFaucetRequesters(requests map[string]*FaucetRequester, minAmount int64) []FaucetRequester {
+	top := make([]FaucetRequester, 0)
+	for _, requester := range requests {
+		if requester.Amount < minAmount {
+			continue
+		}
+
+		top = append(top, *requester)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+
+		return top[i].Address < top[j].Address
+	})
+
+	return top
+}
+
+// renderStatsMarkdown renders the stats as the chain README Markdown
+func renderStatsMarkdown(stats Stats) string {
+	var b strings.Builder
+
+	section := func(title string, writeFn func()) {
+		fmt.Fprintf(&b, "## %s\n```\n", title)
+		writeFn()
+		b.WriteString("```\n\n")
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n", stats.Title)
+
+	section("TXs", func() {
+		fmt.Fprintf(&b, "%d\n", stats.Txs)
+	})
+
+	section("addpkgs", func() {
+		for _, pc := range stats.AddPackages {
+			fmt.Fprintf(&b, "%7d %s\n", pc.Count, quoteJSON(pc.Path))
+		}
+	})
+
+	section("top realm calls", func() {
+		for _, pc := range stats.RealmCalls {
+			fmt.Fprintf(&b, "%7d %s\n", pc.Count, quoteJSON(pc.Path))
+		}
+	})
+
+	section("top faucet requesters", func() {
+		for _, requester := range stats.FaucetRequesters {
+			fmt.Fprintf(&b, "%-15s\t%d\t%d\n", requester.Address, requester.Requests, requester.Amount)
+		}
+	})
+
+	return b.String()
+}
+
+// quoteJSON quotes the string as a JSON string, without HTML escaping
+func quoteJSON(s string) string {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	// Encoding a string never fails
+	_ = encoder.Encode(s)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
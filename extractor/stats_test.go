@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/crypto"
+	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sourceDir = t.TempDir()
+
+		faucet = addressFromString(t, defaultFaucetAddress)
+		userA  = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+		userB  = addressFromString(t, "g1f4v282mwyhu29afke4vq5r2xzcm6z3ftnugcnv")
+		userC  = addressFromString(t, "g1u7y667z64x2h7vc6fmpcprgey4ck233jaww9zq")
+
+		addPkg = func(path string) std.Msg {
+			return vm.MsgAddPackage{
+				Creator: userA,
+				Package: &std.MemPackage{
+					Name:  filepath.Base(path),
+					Path:  path,
+					Files: []*std.MemFile{{Name: "a.gno", Body: "package a"}},
+				},
+			}
+		}
+
+		call = func(path string) std.Msg {
+			return vm.MsgCall{
+				Caller:  userA,
+				PkgPath: path,
+				Func:    "Render",
+			}
+		}
+
+		send = func(from, to crypto.Address, amount int64) std.Msg {
+			return bank.MsgSend{
+				FromAddress: from,
+				ToAddress:   to,
+				Amount:      std.NewCoins(std.NewCoin(faucetDenom, amount)),
+			}
+		}
+	)
+
+	txs := [][]std.Msg{
+		{addPkg("gno.land/p/demo/avl"), addPkg("gno.land/r/demo/<boards>")},
+		{addPkg("gno.land/r/demo/<boards>")},
+		{call("gno.land/r/demo/users"), call("gno.land/r/demo/<boards>")},
+		{call("gno.land/r/demo/users")},
+		{send(faucet, userB, 300_000_000)},
+		{send(faucet, userB, 300_000_000)},
+		{send(faucet, userC, 600_000_000)},
+		{send(faucet, userA, 100)},
+		{send(userB, userC, 1_000_000_000)},
+	}
+
+	file, err := os.Create(filepath.Join(sourceDir, "backup_0000001-0000100.jsonl"))
+	require.NoError(t, err)
+
+	for _, msgs := range txs {
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{Tx: std.Tx{Msgs: msgs}}, file))
+	}
+
+	require.NoError(t, file.Close())
+
+	// The staging balances file is not part of the archive
+	require.NoError(t, os.WriteFile(
+		filepath.Join(sourceDir, stagingBalancesFile),
+		[]byte(userA.String()+"=1000ugnot\n"),
+		0o644,
+	))
+
+	cfg := &statsCfg{
+		sourceCfg:       sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		title:           "https://rpc.example.gno.land",
+		faucetAddress:   defaultFaucetAddress,
+		faucetMinAmount: defaultFaucetMinAmount,
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		mdCfg := *cfg
+		mdCfg.format = formatMarkdown
+
+		var out bytes.Buffer
+		require.NoError(t, execStats(ctx, &mdCfg, &out))
+
+		expected := "# https://rpc.example.gno.land\n\n" +
+			"## TXs\n```\n9\n```\n\n" +
+			"## addpkgs\n```\n" +
+			"      2 \"gno.land/r/demo/<boards>\"\n" +
+			"      1 \"gno.land/p/demo/avl\"\n" +
+			"```\n\n" +
+			"## top realm calls\n```\n" +
+			"      2 \"gno.land/r/demo/users\"\n" +
+			"      1 \"gno.land/r/demo/<boards>\"\n" +
+			"```\n\n" +
+			"## top faucet requesters\n```\n" +
+			userB.String() + "\t2\t600000000\n" +
+			userC.String() + "\t1\t600000000\n" +
+			"```\n\n"
+
+		assert.Equal(t, expected, out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		jsonCfg := *cfg
+		jsonCfg.format = formatJSON
+		jsonCfg.outputPath = filepath.Join(t.TempDir(), "stats.json")
+
+		require.NoError(t, execStats(ctx, &jsonCfg, &bytes.Buffer{}))
+
+		raw, err := os.ReadFile(jsonCfg.outputPath)
+		require.NoError(t, err)
+
+		var stats Stats
+		require.NoError(t, json.Unmarshal(raw, &stats))
+
+		assert.Equal(t, 9, stats.Txs)
+		assert.Equal(t, []PathCount{
+			{Path: "gno.land/r/demo/<boards>", Count: 2},
+			{Path: "gno.land/p/demo/avl", Count: 1},
+		}, stats.AddPackages)
+		assert.Len(t, stats.RealmCalls, 2)
+		assert.Equal(t, []FaucetRequester{
+			{Address: userB.String(), Requests: 2, Amount: 600_000_000},
+			{Address: userC.String(), Requests: 1, Amount: 600_000_000},
+		}, stats.FaucetRequesters)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		strictCfg := *cfg
+		strictCfg.format = formatJSON
+		strictCfg.strict = true
+
+		var out bytes.Buffer
+		require.NoError(t, execStats(ctx, &strictCfg, &out))
+
+		var stats Stats
+		require.NoError(t, json.Unmarshal(out.Bytes(), &stats))
+
+		assert.Equal(t, 9, stats.Txs)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		invalidCfg := *cfg
+		invalidCfg.format = "html"
+
+		assert.ErrorIs(t, execStats(ctx, &invalidCfg, &bytes.Buffer{}), errInvalidFormat)
+	})
+}
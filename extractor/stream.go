@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"slices"
+)
+
+// defaultMaxLineSize is the default maximum size of a single tx line.
+// The largest lines in the archives (genesis style package deployments) are
+// well below a megabyte, the limit only keeps the memory usage bounded
+const defaultMaxLineSize = 64 << 20 // 64 MiB
+
+var errLineTooLong = errors.New("tx line too long")
+
+// rawLine is a single line read from a tx archive
+type rawLine struct {
+	data   []byte // the line, without the line ending (truncated if too long)
+	num    int    // the line number, starting from 1
+	offset int64  // the byte offset of the line start
+	size   int64  // the full size of the line, with the line ending
+}
+
+// lineReader reads the lines of a tx archive one by one,
+// reusing a single line buffer of at most maxSize bytes.
+// The rest of the lines longer than maxSize is discarded
+type lineReader struct {
+	reader  *bufio.Reader
+	maxSize int
+	buf     []byte
+
+	num    int
+	offset int64
+}
+
+// newLineReader creates a new line reader for the given archive
+func newLineReader(r io.Reader, maxSize int) *lineReader {
+	if maxSize <= 0 {
+		maxSize = defaultMaxLineSize
+	}
+
+	return &lineReader{
+		reader:  bufio.NewReader(r),
+		maxSize: maxSize,
+	}
+}
+
+// next reads the next line. The returned line data is only valid
+// until the following call. Returns io.EOF when there are no more lines
+func (r *lineReader) next() (rawLine, error) {
+	var (
+		// Room for the line ending (\r\n)
+		limit = r.maxSize + 2
+
+		size int64
+	)
+
+	r.buf = r.buf[:0]
+
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		size += int64(len(chunk))
+
+		if room := limit - len(r.buf); room > 0 {
+			r.buf = append(r.buf, chunk[:min(room, len(chunk))]...)
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return rawLine{}, fmt.Errorf("error reading lines; %w", err)
+		}
+
+		if size == 0 {
+			return rawLine{}, io.EOF
+		}
+
+		break
+	}
+
+	r.num++
+
+	line := rawLine{
+		data:   bytes.TrimRight(r.buf, "\r\n"),
+		num:    r.num,
+		offset: r.offset,
+		size:   size,
+	}
+
+	r.offset += size
+
+	return line, nil
+}
+
+// tooLong returns true if the line was truncated by the line reader
+func (l rawLine) tooLong(maxSize int) bool {
+	return len(l.data) > maxSize || int64(len(l.data)) < l.size-2
+}
+
+// streamOpts defines how the transaction messages of a single archive are streamed
+type streamOpts struct {
+	decodeFn    txDecodeFn
+	rejectFn    func(LineError) error // called for every undecodable line (logged if nil)
+	maxLineSize int                   // the maximum line size (defaultMaxLineSize if 0)
+	msgTypes    []string              // the message types to yield (all if empty)
+
+	// The position the stream starts at, at the beginning of a line.
+	// The content before it is skipped
+	offset   int64
+	line     int
+	counters txCounters // the tx counters at the start position
+
+	endFn func(txCounters) // called with the tx counters once the whole archive is streamed (optional)
+}
+
+// txCounters locate the next tx within its block height.
+// The txs of a block height are counted from the first one,
+// so a resumed stream carries on from the counters of the previous one
+type txCounters struct {
+	lastHeight uint64 // the block height of the last tx
+	txIndex    int    // the index of the next tx, if it has the same block height
+}
+
+// streamMessages yields the transaction messages of the given archive,
+// as soon as they are decoded. Only a single line is kept in memory at once.
+// Lines that cannot be decoded, or are too long, are passed to the reject
+// function, which can stop the stream by returning an error
+func streamMessages(r io.Reader, name string, opts streamOpts) iter.Seq2[TxMessage, error] {
+	rejectFn := opts.rejectFn
+	if rejectFn == nil {
+		rejectFn = func(lineErr LineError) error {
+			slog.Error("error while parsing amino JSON", "file", lineErr.File, "line", lineErr.Line, "error", lineErr.Err)
+
+			return nil
+		}
+	}
+
+	return func(yield func(TxMessage, error) bool) {
+		if opts.offset > 0 {
+			if _, err := io.CopyN(io.Discard, r, opts.offset); err != nil {
+				yield(TxMessage{}, fmt.Errorf("unable to skip to offset %d, %w", opts.offset, err))
+
+				return
+			}
+		}
+
+		var (
+			reader = newLineReader(r, opts.maxLineSize)
+
+			// Position of the current tx within its block height
+			lastHeight = opts.counters.lastHeight
+			txIndex    = opts.counters.txIndex
+		)
+
+		reader.num = opts.line
+		reader.offset = opts.offset
+
+		for {
+			line, err := reader.next()
+			if errors.Is(err, io.EOF) {
+				if opts.endFn != nil {
+					opts.endFn(txCounters{lastHeight: lastHeight, txIndex: txIndex})
+				}
+
+				return
+			}
+
+			if err != nil {
+				yield(TxMessage{}, err)
+
+				return
+			}
+
+			if line.tooLong(reader.maxSize) {
+				lineErr := fmt.Errorf("%w, %d bytes (max %d)", errLineTooLong, line.size, reader.maxSize)
+
+				if rejectErr := rejectFn(newLineError(name, line.num, line.offset, line.data, lineErr)); rejectErr != nil {
+					yield(TxMessage{}, rejectErr)
+
+					return
+				}
+
+				continue
+			}
+
+			if len(bytes.TrimSpace(line.data)) == 0 {
+				continue
+			}
+
+			entry, err := opts.decodeFn(line.data)
+			if err != nil {
+				if rejectErr := rejectFn(newLineError(name, line.num, line.offset, line.data, err)); rejectErr != nil {
+					yield(TxMessage{}, rejectErr)
+
+					return
+				}
+
+				continue
+			}
+
+			if entry.height != lastHeight {
+				lastHeight = entry.height
+				txIndex = 0
+			}
+
+			for msgIndex, msg := range entry.tx.Msgs {
+				// Only the requested message types should be yielded (all if none)
+				if len(opts.msgTypes) > 0 && !slices.Contains(opts.msgTypes, msg.Type()) {
+					continue
+				}
+
+				txMsg := TxMessage{
+					Msg:          msg,
+					Tx:           entry.tx,
+					Height:       entry.height,
+					HeightSource: entry.heightSource,
+					Timestamp:    entry.timestamp,
+					TxIndex:      txIndex,
+					MsgIndex:     msgIndex,
+				}
+
+				if !yield(txMsg, nil) {
+					return
+				}
+			}
+
+			txIndex++
+		}
+	}
+}
+
+// streamFileMessages yields the transaction messages of the given archive file,
+// decompressing it if needed, and closing the file once the stream is over
+func streamFileMessages(filePath string, opts streamOpts) iter.Seq2[TxMessage, error] {
+	return func(yield func(TxMessage, error) bool) {
+		file, err := openArchive(filePath)
+		if err != nil {
+			yield(TxMessage{}, err)
+
+			return
+		}
+
+		stopped := false
+
+		for txMsg, err := range streamMessages(file, filePath, opts) {
+			if !yield(txMsg, err) {
+				stopped = true
+
+				break
+			}
+		}
+
+		if closeErr := file.Close(); closeErr != nil && !stopped {
+			yield(TxMessage{}, fmt.Errorf("unable to gracefully close file, %w", closeErr))
+		}
+	}
+}
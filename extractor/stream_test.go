@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// largeInputEnv enables the multi-GB decoding tests
+const largeInputEnv = "EXTRACTOR_LARGE_INPUT"
+
+// syntheticReader generates a synthetic tx archive of the given parts,
+// without ever holding it in memory. Each part is a line repeated count times,
+// or a single line of size bytes filled with a repeated byte
+type syntheticReader struct {
+	parts []syntheticPart
+	pos   int64 // position within the current part
+
+	read int64 // total number of bytes read
+}
+
+type syntheticPart struct {
+	line  []byte // the line to repeat, with its line ending
+	count int64
+
+	fill byte // the filler of a generated line, if line is nil
+	size int64
+}
+
+// len returns the total size of the part
+func (p syntheticPart) len() int64 {
+	if p.line == nil {
+		return p.size
+	}
+
+	return int64(len(p.line)) * p.count
+}
+
+func (r *syntheticReader) Read(b []byte) (int, error) {
+	n := 0
+
+	for n < len(b) && len(r.parts) > 0 {
+		part := r.parts[0]
+
+		if r.pos == part.len() {
+			r.parts = r.parts[1:]
+			r.pos = 0
+
+			continue
+		}
+
+		var written int
+
+		if part.line == nil {
+			chunk := b[n:min(int64(len(b)), int64(n)+part.len()-r.pos)]
+
+			for i := range chunk {
+				chunk[i] = part.fill
+			}
+
+			// The generated line ends with a line feed
+			if r.pos+int64(len(chunk)) == part.len() {
+				chunk[len(chunk)-1] = '\n'
+			}
+
+			written = len(chunk)
+		} else {
+			offset := r.pos % int64(len(part.line))
+			written = copy(b[n:], part.line[offset:])
+		}
+
+		n += written
+		r.pos += int64(written)
+	}
+
+	r.read += int64(n)
+
+	if n == 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// addPackageLine returns a tx archive line deploying the given package,
+// with a single file of the given body size
+func addPackageLine(t *testing.T, path string, bodySize int) []byte {
+	t.Helper()
+
+	tx := gnoland.TxWithMetadata{
+		Tx: std.Tx{
+			Msgs: []std.Msg{
+				vm.MsgAddPackage{
+					Creator: addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5"),
+					Package: &std.MemPackage{
+						Name: "pkg",
+						Path: path,
+						Files: []*std.MemFile{
+							{
+								Name: "pkg.gno",
+								Body: "package pkg\n\n// " + strings.Repeat("a", bodySize),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := amino.MarshalJSON(tx)
+	require.NoError(t, err)
+
+	return append(data, '\n')
+}
+
+// decodeTxWithMetadata decodes a single gnoland.TxWithMetadata line
+func decodeTxWithMetadata(line []byte) (archiveEntry, error) {
+	return txUnwrapper[gnoland.TxWithMetadata]{
+		txFn: func(tx gnoland.TxWithMetadata) std.Tx {
+			return tx.Tx
+		},
+		heightFn: func(_ gnoland.TxWithMetadata) uint64 {
+			return 0
+		},
+	}.decode(line)
+}
+
+func TestLineReader(t *testing.T) {
+	t.Parallel()
+
+	reader := newLineReader(strings.NewReader("first\r\n\nsecond line\nthird"), 6)
+
+	expected := []struct {
+		data    string
+		offset  int64
+		size    int64
+		tooLong bool
+	}{
+		{"first", 0, 7, false},
+		{"", 7, 1, false},
+		{"second l", 8, 12, true},
+		{"third", 20, 5, false},
+	}
+
+	for i, exp := range expected {
+		line, err := reader.next()
+		require.NoError(t, err)
+
+		assert.Equal(t, exp.data, string(line.data))
+		assert.Equal(t, i+1, line.num)
+		assert.Equal(t, exp.offset, line.offset)
+		assert.Equal(t, exp.size, line.size)
+		assert.Equal(t, exp.tooLong, line.tooLong(reader.maxSize))
+	}
+
+	_, err := reader.next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamMessages_Lazy(t *testing.T) {
+	t.Parallel()
+
+	var (
+		line   = addPackageLine(t, "gno.land/r/demo/lazy", 1024)
+		reader = &syntheticReader{
+			parts: []syntheticPart{{line: line, count: 100_000}},
+		}
+	)
+
+	for txMsg, err := range streamMessages(reader, "synthetic", streamOpts{decodeFn: decodeTxWithMetadata}) {
+		require.NoError(t, err)
+
+		msg, err := addPackageFromMsg(txMsg)
+		require.NoError(t, err)
+
+		assert.Equal(t, "gno.land/r/demo/lazy", msg.Package.Path)
+
+		break
+	}
+
+	// Only the first buffered chunk of the archive was read
+	assert.Less(t, reader.read, int64(len(line))*10)
+}
+
+// TestStreamMessages_LargeInput is not run in parallel,
+// so the memory stats only account for the stream itself
+func TestStreamMessages_LargeInput(t *testing.T) {
+	const (
+		gib = int64(1) << 30
+
+		// Memory the stream may use, whatever the input size
+		maxAlloc = 64 << 20
+	)
+
+	t.Run("oversized line", func(t *testing.T) {
+		var (
+			first  = addPackageLine(t, "gno.land/r/demo/first", 16)
+			last   = addPackageLine(t, "gno.land/r/demo/last", 16)
+			reader = &syntheticReader{
+				parts: []syntheticPart{
+					{line: first, count: 1},
+					{fill: 'a', size: 4 * gib},
+					{line: last, count: 1},
+				},
+			}
+
+			rejects []LineError
+			paths   []string
+		)
+
+		before := allocatedBytes()
+
+		for txMsg, err := range streamMessages(reader, "synthetic", streamOpts{
+			decodeFn: decodeTxWithMetadata,
+			rejectFn: func(lineErr LineError) error {
+				rejects = append(rejects, lineErr)
+
+				return nil
+			},
+			maxLineSize: 1 << 20,
+		}) {
+			require.NoError(t, err)
+
+			msg, err := addPackageFromMsg(txMsg)
+			require.NoError(t, err)
+
+			paths = append(paths, msg.Package.Path)
+		}
+
+		assert.Less(t, allocatedBytes()-before, uint64(maxAlloc))
+
+		assert.Equal(t, []string{"gno.land/r/demo/first", "gno.land/r/demo/last"}, paths)
+		assert.Equal(t, int64(len(first))+4*gib+int64(len(last)), reader.read)
+
+		require.Len(t, rejects, 1)
+		assert.Equal(t, 2, rejects[0].Line)
+		assert.Equal(t, int64(len(first)), rejects[0].Offset)
+		assert.Contains(t, rejects[0].Err, errLineTooLong.Error())
+		assert.Len(t, rejects[0].Snippet, maxSnippetLength)
+	})
+
+	t.Run("many packages", func(t *testing.T) {
+		// Decoding is CPU bound (close to a minute per GiB), so the multi-GB
+		// input is only decoded when explicitly requested
+		size := 64 * (gib >> 10)
+		if os.Getenv(largeInputEnv) != "" {
+			size = 2 * gib
+		}
+
+		var (
+			line  = addPackageLine(t, "gno.land/r/demo/large", 1<<20)
+			count = size / int64(len(line))
+
+			reader = &syntheticReader{
+				parts: []syntheticPart{{line: line, count: count}},
+			}
+
+			yielded int64
+		)
+
+		for txMsg, err := range streamMessages(reader, "synthetic", streamOpts{decodeFn: decodeTxWithMetadata}) {
+			require.NoError(t, err)
+
+			yielded++
+
+			// Every message is dropped right away, so the live heap
+			// should never hold more than a few lines at once
+			if yielded%256 == 0 {
+				assert.Less(t, heapInUse(), uint64(maxAlloc)*4)
+			}
+
+			_ = txMsg
+		}
+
+		assert.Equal(t, count, yielded)
+	})
+}
+
+func TestStreamMessages_RejectStops(t *testing.T) {
+	t.Parallel()
+
+	var (
+		errStop = errors.New("stop")
+		input   = bytes.Join([][]byte{
+			addPackageLine(t, "gno.land/r/demo/first", 16),
+			[]byte("invalid\n"),
+			addPackageLine(t, "gno.land/r/demo/last", 16),
+		}, nil)
+
+		msgs int
+	)
+
+	for _, err := range streamMessages(bytes.NewReader(input), "synthetic", streamOpts{
+		decodeFn: decodeTxWithMetadata,
+		rejectFn: func(_ LineError) error {
+			return errStop
+		},
+	}) {
+		if err != nil {
+			require.ErrorIs(t, err, errStop)
+
+			break
+		}
+
+		msgs++
+	}
+
+	assert.Equal(t, 1, msgs)
+}
+
+// allocatedBytes returns the cumulative number of bytes allocated by the process
+func allocatedBytes() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return stats.TotalAlloc
+}
+
+// heapInUse returns the number of bytes in use by the heap
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return stats.HeapInuse
+}
@@ -0,0 +1,720 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gno/tm2/pkg/crypto"
+	"github.com/gnolang/gno/tm2/pkg/sdk/bank"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define the transfer graph output formats
+const (
+	formatEdges   = "edges"
+	formatGraphML = "graphml"
+)
+
+var errInvalidAddress = errors.New("invalid address")
+
+// transfersCSVHeader is the header row of the CSV transfer ledger
+var transfersCSVHeader = []string{
+	"height",
+	"timestamp",
+	"from",
+	"to",
+	"coins",
+}
+
+// transferEdgesCSVHeader is the header row of the CSV transfer graph edges
+var transferEdgesCSVHeader = []string{
+	"from",
+	"to",
+	"transfers",
+	"coins",
+	"amount",
+}
+
+// Define transfers config
+type transfersCfg struct {
+	outputPath  string
+	format      string
+	title       string
+	fromAddress string
+	denom       string
+
+	sourceCfg
+	rejectsCfg
+}
+
+// newTransfersCmd creates the bank transfers command
+func newTransfersCmd() *ffcli.Command {
+	var (
+		cfg = &transfersCfg{}
+		fs  = flag.NewFlagSet("transfers", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "transfers",
+		ShortUsage: "transfers [flags]",
+		ShortHelp:  "exports the bank.MsgSend ledger and the address flow graph",
+		LongHelp: "Exports one record per bank.MsgSend found in the transaction data, as JSONL or CSV, or the " +
+			"graph of the sends between addresses with the net flow of every address, as Markdown, JSON, " +
+			"CSV edges or GraphML",
+		FlagSet: fs,
+		Exec: func(ctx context.Context, _ []string) error {
+			return execTransfers(ctx, cfg, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the transfers command flag set
+func (c *transfersCfg) registerFlags(fs *flag.FlagSet) {
+	c.sourceCfg.registerFlags(fs)
+
+	fs.StringVar(
+		&c.outputPath,
+		"output-path",
+		"",
+		"the output file for the ledger or graph (stdout if empty)",
+	)
+
+	fs.StringVar(
+		&c.format,
+		"format",
+		formatJSONL,
+		fmt.Sprintf(
+			"the output format, the ledger (%s, %s) or the graph (%s, %s, %s, %s)",
+			formatJSONL,
+			formatCSV,
+			formatMarkdown,
+			formatJSON,
+			formatEdges,
+			formatGraphML,
+		),
+	)
+
+	fs.StringVar(
+		&c.title,
+		"title",
+		"",
+		"the title of the Markdown graph summary (ie the chain remote)",
+	)
+
+	fs.StringVar(
+		&c.fromAddress,
+		"from-address",
+		"",
+		"only include the sends from these comma separated addresses (ie the faucet "+defaultFaucetAddress+")",
+	)
+
+	fs.StringVar(
+		&c.denom,
+		"denom",
+		faucetDenom,
+		"the denom the graph addresses are ranked, and the edges weighted, by",
+	)
+
+	c.rejectsCfg.registerFlags(fs)
+}
+
+// execTransfers runs the bank transfers export
+func execTransfers(ctx context.Context, cfg *transfersCfg, stdout io.Writer) error {
+	// Check the source is valid
+	if err := cfg.sourceCfg.validate(); err != nil {
+		return err
+	}
+
+	// Check the output format is valid
+	if !slices.Contains(
+		[]string{formatJSONL, formatCSV, formatMarkdown, formatJSON, formatEdges, formatGraphML},
+		cfg.format,
+	) {
+		return fmt.Errorf("%w %q", errInvalidFormat, cfg.format)
+	}
+
+	senders, err := parseAddresses(cfg.fromAddress)
+	if err != nil {
+		return err
+	}
+
+	sourceFiles, err := findSourceFiles(cfg.sourcePath, cfg.fileType)
+	if err != nil {
+		return err
+	}
+
+	// The ledger is in chain order
+	sortArchiveFiles(sourceFiles)
+
+	rejects, err := newRejectHandler(cfg.rejectsCfg)
+	if err != nil {
+		return err
+	}
+	defer rejects.close()
+
+	return writeOutput(cfg.outputPath, stdout, func(out io.Writer) error {
+		var (
+			ledger transferWriter
+			graph  = newTransferGraph()
+		)
+
+		if cfg.format == formatJSONL || cfg.format == formatCSV {
+			ledger = newTransferWriter(cfg.format, out)
+		}
+
+		for transfer, err := range archiveBankTransfers(ctx, sourceFiles, rejects) {
+			if err != nil {
+				return err
+			}
+
+			if len(senders) > 0 && !slices.Contains(senders, transfer.from) {
+				continue
+			}
+
+			if ledger == nil {
+				graph.add(transfer)
+
+				continue
+			}
+
+			if err := ledger.write(transfer.record()); err != nil {
+				return fmt.Errorf("unable to write transfer record, %w", err)
+			}
+		}
+
+		if err := rejects.close(); err != nil {
+			return err
+		}
+
+		if ledger != nil {
+			if err := ledger.flush(); err != nil {
+				return fmt.Errorf("unable to flush transfer ledger, %w", err)
+			}
+
+			return nil
+		}
+
+		exported := graph.export(cfg.title, cfg.denom)
+
+		var output string
+
+		switch cfg.format {
+		case formatJSON:
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+
+			if err := encoder.Encode(exported); err != nil {
+				return fmt.Errorf("unable to write graph, %w", err)
+			}
+
+			return nil
+		case formatEdges:
+			if err := writeTransferEdgesCSV(exported, out); err != nil {
+				return fmt.Errorf("unable to write graph, %w", err)
+			}
+
+			return nil
+		case formatGraphML:
+			output = renderTransfersGraphML(exported)
+		default:
+			output = renderTransfersMarkdown(exported)
+		}
+
+		if _, err := io.WriteString(out, output); err != nil {
+			return fmt.Errorf("unable to write graph, %w", err)
+		}
+
+		return nil
+	})
+}
+
+// parseAddresses parses the comma separated bech32 addresses
+func parseAddresses(addresses string) ([]crypto.Address, error) {
+	var parsed []crypto.Address
+
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address == "" {
+			continue
+		}
+
+		addr, err := crypto.AddressFromBech32(address)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q, %w", errInvalidAddress, address, err)
+		}
+
+		parsed = append(parsed, addr)
+	}
+
+	return parsed, nil
+}
+
+// TransferRecord defines a single bank.MsgSend record of the transfer ledger
+type TransferRecord struct {
+	Height    uint64 `json:"height"`    // the block height of the send
+	Timestamp int64  `json:"timestamp"` // the block timestamp of the send (unix seconds)
+	From      string `json:"from"`      // the sender address
+	To        string `json:"to"`        // the recipient address
+	Coins     string `json:"coins"`     // the sent coins
+}
+
+// bankTransfer is a single bank send of the tx archive
+type bankTransfer struct {
+	height    uint64
+	timestamp int64
+	from      crypto.Address
+	to        crypto.Address
+	coins     std.Coins
+}
+
+// record returns the ledger record of the bank send
+func (t bankTransfer) record() TransferRecord {
+	return TransferRecord{
+		Height:    t.height,
+		Timestamp: t.timestamp,
+		From:      t.from.String(),
+		To:        t.to.String(),
+		Coins:     t.coins.String(),
+	}
+}
+
+// archiveBankTransfers yields the bank sends of the source files, in file order
+func archiveBankTransfers(ctx context.Context, sourceFiles []string, rejects *rejectHandler) iter.Seq2[bankTransfer, error] {
+	return func(yield func(bankTransfer, error) bool) {
+		for _, sourceFile := range sourceFiles {
+			select {
+			case <-ctx.Done():
+				yield(bankTransfer{}, ctx.Err())
+
+				return
+			default:
+			}
+
+			for transfer, err := range fileBankTransfers(sourceFile, rejects) {
+				if !yield(transfer, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// fileBankTransfers yields the bank sends of a single source file. The sent
+// coins are parsed back from their string form, which validates and sorts
+// them, and the sends with invalid coins are skipped
+func fileBankTransfers(sourceFile string, rejects *rejectHandler) iter.Seq2[bankTransfer, error] {
+	return func(yield func(bankTransfer, error) bool) {
+		for txMsg, err := range fileMessages(sourceFile, rejects, msgTypeSend) {
+			if err != nil {
+				yield(bankTransfer{}, err)
+
+				return
+			}
+
+			msg, ok := txMsg.Msg.(bank.MsgSend)
+			if !ok {
+				continue
+			}
+
+			coins, err := std.ParseCoins(msg.Amount.String())
+			if err != nil {
+				slog.Warn(
+					"skipping bank send with invalid coins",
+					"file", sourceFile,
+					"height", txMsg.Height,
+					"error", err,
+				)
+
+				continue
+			}
+
+			transfer := bankTransfer{
+				height:    txMsg.Height,
+				timestamp: txMsg.Timestamp,
+				from:      msg.FromAddress,
+				to:        msg.ToAddress,
+				coins:     coins,
+			}
+
+			if !yield(transfer, nil) {
+				return
+			}
+		}
+	}
+}
+
+// addressFlow is the sum of the bank sends of a single address
+type addressFlow struct {
+	received      std.Coins
+	sent          std.Coins
+	receivedCount int
+	sentCount     int
+}
+
+// net returns the received coins, minus the sent coins
+func (f *addressFlow) net() std.Coins {
+	return f.received.SubUnsafe(f.sent)
+}
+
+// volume returns the received and sent amount of the denom
+func (f *addressFlow) volume(denom string) int64 {
+	return f.received.AmountOf(denom) + f.sent.AmountOf(denom)
+}
+
+// transferEdge is the sum of the bank sends from a sender to a recipient
+type transferEdge struct {
+	count int
+	coins std.Coins
+}
+
+// transferGraph sums the bank sends per address, and per sender and recipient
+type transferGraph struct {
+	count int
+	flows map[crypto.Address]*addressFlow
+	edges map[[2]crypto.Address]*transferEdge
+}
+
+// newTransferGraph creates an empty transfer graph
+func newTransferGraph() *transferGraph {
+	return &transferGraph{
+		flows: make(map[crypto.Address]*addressFlow),
+		edges: make(map[[2]crypto.Address]*transferEdge),
+	}
+}
+
+// add adds the bank send to the graph
+func (g *transferGraph) add(transfer bankTransfer) {
+	g.count++
+
+	sender := g.flow(transfer.from)
+	sender.sent = sender.sent.AddUnsafe(transfer.coins)
+	sender.sentCount++
+
+	recipient := g.flow(transfer.to)
+	recipient.received = recipient.received.AddUnsafe(transfer.coins)
+	recipient.receivedCount++
+
+	key := [2]crypto.Address{transfer.from, transfer.to}
+
+	edge, ok := g.edges[key]
+	if !ok {
+		edge = &transferEdge{}
+		g.edges[key] = edge
+	}
+
+	edge.count++
+	edge.coins = edge.coins.AddUnsafe(transfer.coins)
+}
+
+// flow returns the flow of the address, creating it if needed
+func (g *transferGraph) flow(address crypto.Address) *addressFlow {
+	flow, ok := g.flows[address]
+	if !ok {
+		flow = &addressFlow{}
+		g.flows[address] = flow
+	}
+
+	return flow
+}
+
+// sortByVolume sorts the addresses by their received and sent amount of the
+// denom (descending), then by address
+func (g *transferGraph) sortByVolume(addresses []crypto.Address, denom string) {
+	slices.SortFunc(addresses, func(a, b crypto.Address) int {
+		volumeA, volumeB := g.flows[a].volume(denom), g.flows[b].volume(denom)
+
+		switch {
+		case volumeA > volumeB:
+			return -1
+		case volumeA < volumeB:
+			return 1
+		default:
+			return strings.Compare(a.String(), b.String())
+		}
+	})
+}
+
+// TransferGraph defines the bank send graph between the addresses of a chain
+type TransferGraph struct {
+	Title     string         `json:"title"`     // the graph title (ie the chain remote)
+	Denom     string         `json:"denom"`     // the denom the addresses are ranked by
+	Transfers int            `json:"transfers"` // the number of bank sends
+	Addresses []AddressFlow  `json:"addresses"` // the flows per address, by the moved amount of the denom
+	Edges     []TransferEdge `json:"edges"`     // the sends per sender and recipient, sorted by address
+}
+
+// AddressFlow defines the bank sends of a single address
+type AddressFlow struct {
+	Address       string `json:"address"`
+	Received      string `json:"received"`       // the received coins
+	ReceivedCount int    `json:"received_count"` // the number of received sends
+	Sent          string `json:"sent"`           // the sent coins
+	SentCount     int    `json:"sent_count"`     // the number of sent sends
+	Net           string `json:"net"`            // the received coins, minus the sent coins, with an explicit sign
+}
+
+// TransferEdge defines the bank sends from a sender to a recipient
+type TransferEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Transfers int    `json:"transfers"` // the number of sends
+	Coins     string `json:"coins"`     // the sent coins
+	Amount    int64  `json:"amount"`    // the sent amount of the graph denom
+}
+
+// export returns the exported graph, with the addresses ranked by the denom
+func (g *transferGraph) export(title, denom string) TransferGraph {
+	graph := TransferGraph{
+		Title:     title,
+		Denom:     denom,
+		Transfers: g.count,
+		Addresses: make([]AddressFlow, 0, len(g.flows)),
+		Edges:     make([]TransferEdge, 0, len(g.edges)),
+	}
+
+	addresses := make([]crypto.Address, 0, len(g.flows))
+	for address := range g.flows {
+		addresses = append(addresses, address)
+	}
+
+	g.sortByVolume(addresses, denom)
+
+	for _, address := range addresses {
+		flow := g.flows[address]
+
+		graph.Addresses = append(graph.Addresses, AddressFlow{
+			Address:       address.String(),
+			Received:      formatCoins(flow.received),
+			ReceivedCount: flow.receivedCount,
+			Sent:          formatCoins(flow.sent),
+			SentCount:     flow.sentCount,
+			Net:           formatCoinsDelta(flow.net()),
+		})
+	}
+
+	for key, edge := range g.edges {
+		graph.Edges = append(graph.Edges, TransferEdge{
+			From:      key[0].String(),
+			To:        key[1].String(),
+			Transfers: edge.count,
+			Coins:     edge.coins.String(),
+			Amount:    edge.coins.AmountOf(denom),
+		})
+	}
+
+	slices.SortFunc(graph.Edges, func(a, b TransferEdge) int {
+		if c := strings.Compare(a.From, b.From); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.To, b.To)
+	})
+
+	return graph
+}
+
+// transferWriter writes transfer records in a specific output format
+type transferWriter interface {
+	write(TransferRecord) error
+	flush() error
+}
+
+// newTransferWriter creates a transfer writer for the given output format
+func newTransferWriter(format string, out io.Writer) transferWriter {
+	if format == formatCSV {
+		return &csvTransferWriter{
+			writer: csv.NewWriter(out),
+		}
+	}
+
+	return &jsonlTransferWriter{
+		encoder: json.NewEncoder(out),
+	}
+}
+
+// jsonlTransferWriter writes one JSON transfer record per line
+type jsonlTransferWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlTransferWriter) write(record TransferRecord) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlTransferWriter) flush() error {
+	return nil
+}
+
+// csvTransferWriter writes the transfer records as CSV rows, with a header row
+type csvTransferWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvTransferWriter) write(record TransferRecord) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	return w.writer.Write([]string{
+		strconv.FormatUint(record.Height, 10),
+		strconv.FormatInt(record.Timestamp, 10),
+		record.From,
+		record.To,
+		record.Coins,
+	})
+}
+
+func (w *csvTransferWriter) flush() error {
+	// Always write the header, even for an empty ledger
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+// writeHeader writes the header row, if not written yet
+func (w *csvTransferWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+
+	w.headerWritten = true
+
+	return w.writer.Write(transfersCSVHeader)
+}
+
+// writeTransferEdgesCSV writes the graph edges as CSV rows, with a header row.
+// The amount column is the sent amount of the graph denom
+func writeTransferEdgesCSV(graph TransferGraph, out io.Writer) error {
+	writer := csv.NewWriter(out)
+
+	if err := writer.Write(transferEdgesCSVHeader); err != nil {
+		return err
+	}
+
+	for _, edge := range graph.Edges {
+		err := writer.Write([]string{
+			edge.From,
+			edge.To,
+			strconv.Itoa(edge.Transfers),
+			edge.Coins,
+			strconv.FormatInt(edge.Amount, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// renderTransfersMarkdown renders the graph summary, with the net flow of every
+// address, as Markdown
+func renderTransfersMarkdown(graph TransferGraph) string {
+	var b strings.Builder
+
+	section := func(title string, writeFn func()) {
+		fmt.Fprintf(&b, "## %s\n```\n", title)
+		writeFn()
+		b.WriteString("```\n\n")
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n", graph.Title)
+
+	section("transfers", func() {
+		fmt.Fprintf(&b, "%d\n", graph.Transfers)
+	})
+
+	section("addresses", func() {
+		fmt.Fprintf(&b, "%d\n", len(graph.Addresses))
+	})
+
+	section(fmt.Sprintf("net flows (by moved %s)", graph.Denom), func() {
+		for _, flow := range graph.Addresses {
+			fmt.Fprintf(
+				&b,
+				"%s received %s (%d), sent %s (%d), net %s\n",
+				flow.Address,
+				flow.Received,
+				flow.ReceivedCount,
+				flow.Sent,
+				flow.SentCount,
+				flow.Net,
+			)
+		}
+	})
+
+	return b.String()
+}
+
+// renderTransfersGraphML renders the graph in the GraphML format, with the
+// flows of the address nodes and the sends of the edges as data
+func renderTransfersGraphML(graph TransferGraph) string {
+	var b strings.Builder
+
+	data := func(key, value string) {
+		fmt.Fprintf(&b, "      <data key=%q>%s</data>\n", key, escapeXML(value))
+	}
+
+	b.WriteString(xml.Header)
+	b.WriteString("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	b.WriteString("  <key id=\"received\" for=\"node\" attr.name=\"received\" attr.type=\"string\"/>\n")
+	b.WriteString("  <key id=\"sent\" for=\"node\" attr.name=\"sent\" attr.type=\"string\"/>\n")
+	b.WriteString("  <key id=\"net\" for=\"node\" attr.name=\"net\" attr.type=\"string\"/>\n")
+	b.WriteString("  <key id=\"transfers\" for=\"edge\" attr.name=\"transfers\" attr.type=\"int\"/>\n")
+	b.WriteString("  <key id=\"coins\" for=\"edge\" attr.name=\"coins\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(&b, "  <key id=\"amount\" for=\"edge\" attr.name=%q attr.type=\"long\"/>\n", escapeXML(graph.Denom))
+	b.WriteString("  <graph id=\"transfers\" edgedefault=\"directed\">\n")
+
+	for _, flow := range graph.Addresses {
+		fmt.Fprintf(&b, "    <node id=%q>\n", escapeXML(flow.Address))
+		data("received", flow.Received)
+		data("sent", flow.Sent)
+		data("net", flow.Net)
+		b.WriteString("    </node>\n")
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "    <edge source=%q target=%q>\n", escapeXML(edge.From), escapeXML(edge.To))
+		data("transfers", strconv.Itoa(edge.Transfers))
+		data("coins", edge.Coins)
+		data("amount", strconv.FormatInt(edge.Amount, 10))
+		b.WriteString("    </edge>\n")
+	}
+
+	b.WriteString("  </graph>\n")
+	b.WriteString("</graphml>\n")
+
+	return b.String()
+}
+
+// escapeXML escapes the XML special characters of the text
+func escapeXML(s string) string {
+	var b strings.Builder
+
+	// Writing to a strings.Builder never fails
+	_ = xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
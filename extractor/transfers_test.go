@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransfers(t *testing.T) {
+	t.Parallel()
+
+	const recipient = "g1q6jrp203fq0239pv38sdq3y3urvd6vt5azacpv"
+
+	// The second file comes first in chain order
+	sourceDir := writeBackupFiles(t, map[string]string{
+		"backup_0000011-0000020.jsonl": sendLine(t, testHolderB, testHolderA, "3foo,20ugnot", 15) +
+			sendLine(t, testHolderB, recipient, "1ugnot", 16),
+		"backup_0000001-0000010.jsonl": sendLine(t, testHolderA, testHolderB, "100ugnot", 2) +
+			"{\"tx\": \n" +
+			sendLine(t, testHolderA, testHolderB, "50ugnot", 4),
+		stagingBalancesFile: testHolderA + "=1000ugnot\n",
+	})
+
+	transfers := func(t *testing.T, cfg *transfersCfg) string {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		cfg.fileType = ".jsonl"
+		cfg.sourcePath = sourceDir
+
+		if cfg.denom == "" {
+			cfg.denom = faucetDenom
+		}
+
+		var out bytes.Buffer
+
+		require.NoError(t, execTransfers(ctx, cfg, &out))
+
+		return out.String()
+	}
+
+	t.Run("jsonl ledger", func(t *testing.T) {
+		t.Parallel()
+
+		var records []TransferRecord
+
+		for _, line := range strings.Split(strings.TrimSpace(transfers(t, &transfersCfg{format: formatJSONL})), "\n") {
+			var record TransferRecord
+
+			require.NoError(t, json.Unmarshal([]byte(line), &record))
+
+			records = append(records, record)
+		}
+
+		require.Len(t, records, 4)
+
+		assert.Equal(t, TransferRecord{
+			Height:    2,
+			Timestamp: 1717236002,
+			From:      testHolderA,
+			To:        testHolderB,
+			Coins:     "100ugnot",
+		}, records[0])
+
+		assert.Equal(t, uint64(4), records[1].Height)
+		assert.Equal(t, uint64(15), records[2].Height)
+		assert.Equal(t, "3foo,20ugnot", records[2].Coins)
+		assert.Equal(t, uint64(16), records[3].Height)
+	})
+
+	t.Run("csv ledger", func(t *testing.T) {
+		t.Parallel()
+
+		rows, err := csv.NewReader(strings.NewReader(transfers(t, &transfersCfg{format: formatCSV}))).ReadAll()
+		require.NoError(t, err)
+
+		require.Len(t, rows, 5)
+		assert.Equal(t, transfersCSVHeader, rows[0])
+		assert.Equal(t, []string{"15", "1717236015", testHolderB, testHolderA, "3foo,20ugnot"}, rows[3])
+	})
+
+	t.Run("from address", func(t *testing.T) {
+		t.Parallel()
+
+		rows, err := csv.NewReader(strings.NewReader(transfers(t, &transfersCfg{
+			format:      formatCSV,
+			fromAddress: " " + testHolderB + ",",
+		}))).ReadAll()
+		require.NoError(t, err)
+
+		require.Len(t, rows, 3)
+		assert.Equal(t, testHolderB, rows[1][2])
+		assert.Equal(t, testHolderB, rows[2][2])
+	})
+
+	t.Run("json graph", func(t *testing.T) {
+		t.Parallel()
+
+		var graph TransferGraph
+
+		require.NoError(t, json.Unmarshal([]byte(transfers(t, &transfersCfg{format: formatJSON, title: "test"})), &graph))
+
+		assert.Equal(t, "test", graph.Title)
+		assert.Equal(t, 4, graph.Transfers)
+
+		// The addresses are ranked by their moved ugnot
+		assert.Equal(t, []AddressFlow{
+			{Address: testHolderB, Received: "150ugnot", ReceivedCount: 2, Sent: "3foo,21ugnot", SentCount: 2, Net: "-3foo,+129ugnot"},
+			{Address: testHolderA, Received: "3foo,20ugnot", ReceivedCount: 1, Sent: "150ugnot", SentCount: 2, Net: "+3foo,-130ugnot"},
+			{Address: recipient, Received: "1ugnot", ReceivedCount: 1, Sent: "0", SentCount: 0, Net: "+1ugnot"},
+		}, graph.Addresses)
+
+		assert.Equal(t, []TransferEdge{
+			{From: testHolderA, To: testHolderB, Transfers: 2, Coins: "150ugnot", Amount: 150},
+			{From: testHolderB, To: testHolderA, Transfers: 1, Coins: "3foo,20ugnot", Amount: 20},
+			{From: testHolderB, To: recipient, Transfers: 1, Coins: "1ugnot", Amount: 1},
+		}, graph.Edges)
+	})
+
+	t.Run("csv edges", func(t *testing.T) {
+		t.Parallel()
+
+		rows, err := csv.NewReader(strings.NewReader(transfers(t, &transfersCfg{format: formatEdges, denom: "foo"}))).ReadAll()
+		require.NoError(t, err)
+
+		assert.Equal(t, [][]string{
+			transferEdgesCSVHeader,
+			{testHolderA, testHolderB, "2", "150ugnot", "0"},
+			{testHolderB, testHolderA, "1", "3foo,20ugnot", "3"},
+			{testHolderB, recipient, "1", "1ugnot", "0"},
+		}, rows)
+	})
+
+	t.Run("graphml", func(t *testing.T) {
+		t.Parallel()
+
+		var graphML struct {
+			Graph struct {
+				EdgeDefault string `xml:"edgedefault,attr"`
+				Nodes       []struct {
+					ID   string `xml:"id,attr"`
+					Data []struct {
+						Key   string `xml:"key,attr"`
+						Value string `xml:",chardata"`
+					} `xml:"data"`
+				} `xml:"node"`
+				Edges []struct {
+					Source string `xml:"source,attr"`
+					Target string `xml:"target,attr"`
+				} `xml:"edge"`
+			} `xml:"graph"`
+		}
+
+		require.NoError(t, xml.Unmarshal([]byte(transfers(t, &transfersCfg{format: formatGraphML})), &graphML))
+
+		assert.Equal(t, "directed", graphML.Graph.EdgeDefault)
+		require.Len(t, graphML.Graph.Nodes, 3)
+		assert.Equal(t, testHolderB, graphML.Graph.Nodes[0].ID)
+		assert.Equal(t, "net", graphML.Graph.Nodes[0].Data[2].Key)
+		assert.Equal(t, "-3foo,+129ugnot", graphML.Graph.Nodes[0].Data[2].Value)
+
+		require.Len(t, graphML.Graph.Edges, 3)
+		assert.Equal(t, testHolderB, graphML.Graph.Edges[2].Source)
+		assert.Equal(t, recipient, graphML.Graph.Edges[2].Target)
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+
+		out := transfers(t, &transfersCfg{format: formatMarkdown, title: "test"})
+
+		assert.Contains(t, out, "# test\n\n## transfers\n```\n4\n```\n")
+		assert.Contains(t, out, recipient+" received 1ugnot (1), sent 0 (0), net +1ugnot\n")
+	})
+
+	t.Run("output path", func(t *testing.T) {
+		t.Parallel()
+
+		outputPath := filepath.Join(t.TempDir(), "transfers.csv")
+
+		assert.Empty(t, transfers(t, &transfersCfg{format: formatEdges, outputPath: outputPath}))
+
+		raw, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(string(raw), "from,to,transfers,coins,amount\n"))
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		t.Parallel()
+
+		err := execTransfers(context.Background(), &transfersCfg{
+			sourceCfg:  sourceCfg{fileType: ".jsonl", sourcePath: sourceDir},
+			format:     formatJSONL,
+			rejectsCfg: rejectsCfg{strict: true},
+		}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, errInvalidTxLine)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		testTable := []struct {
+			name     string
+			cfg      *transfersCfg
+			expected error
+		}{
+			{"missing file type", &transfersCfg{sourceCfg: sourceCfg{sourcePath: sourceDir}, format: formatJSONL}, errInvalidFileType},
+			{"missing source", &transfersCfg{sourceCfg: sourceCfg{fileType: ".jsonl"}, format: formatJSONL}, errInvalidSourceDir},
+			{"format", &transfersCfg{sourceCfg: sourceCfg{fileType: ".jsonl", sourcePath: sourceDir}, format: "dot"}, errInvalidFormat},
+			{"from address", &transfersCfg{sourceCfg: sourceCfg{fileType: ".jsonl", sourcePath: sourceDir}, format: formatJSONL, fromAddress: "g1invalid"}, errInvalidAddress},
+		}
+
+		for _, testCase := range testTable {
+			assert.ErrorIs(t, execTransfers(context.Background(), testCase.cfg, &bytes.Buffer{}), testCase.expected, testCase.name)
+		}
+	})
+}
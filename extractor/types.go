@@ -1,16 +1,74 @@
 package main
 
+import "github.com/gnolang/gno/tm2/pkg/std"
+
+// metadataSchemaVersion is the schema version of the package metadata,
+// bumped whenever its fields change
+const metadataSchemaVersion = 3
+
 // Metadata defines the metadata info that accompanies
 // gno source code
 type Metadata struct {
-	Creator string `json:"creator"` // the creator of the source code (deployer)
-	Deposit string `json:"deposit"` // the deposit associated with the deployment
+	SchemaVersion int      `json:"schema_version"` // the metadata schema version
+	Creator       string   `json:"creator"`        // the creator of the source code (deployer)
+	Deposit       string   `json:"deposit"`        // the coins sent along with the deployment (same as send)
+	Send          string   `json:"send"`           // the coins sent along with the deployment
+	MaxDeposit    string   `json:"max_deposit"`    // the maximum storage deposit of the deployment
+	Height        uint64   `json:"height"`         // the block height of the deployment
+	HeightSource  string   `json:"height_source"`  // block, file_range (the height is a lower bound) or unknown
+	Timestamp     int64    `json:"timestamp"`      // the block timestamp of the deployment (unix seconds), if known
+	TxHash        string   `json:"tx_hash"`        // the base64 encoded SHA-256 of the amino encoded tx
+	TxIndex       int      `json:"tx_index"`       // the index of the tx within its block height
+	MsgIndex      int      `json:"msg_index"`      // the index of the message within its tx
+	Memo          string   `json:"memo"`           // the memo of the tx
+	GasWanted     int64    `json:"gas_wanted"`     // the gas limit of the tx
+	GasFee        string   `json:"gas_fee"`        // the gas fee of the tx
+	Signers       []Signer `json:"signers"`        // the signers of the tx
+}
+
+// Signer defines a single transaction signer
+type Signer struct {
+	Address string `json:"address"` // the signer address
+	PubKey  string `json:"pub_key"` // the bech32 signer public key, if part of the signature
 }
 
 // metadataFromMsg extracts the metadata from a message
 func metadataFromMsg(msg AddPackage) Metadata {
 	return Metadata{
-		Creator: msg.Creator.String(),
-		Deposit: msg.Send.String(),
+		SchemaVersion: metadataSchemaVersion,
+		Creator:       msg.Creator.String(),
+		Deposit:       msg.Send.String(),
+		Send:          msg.Send.String(),
+		MaxDeposit:    msg.MaxDeposit.String(),
+		Height:        msg.Height,
+		HeightSource:  string(msg.HeightSource),
+		Timestamp:     msg.Timestamp,
+		TxHash:        msg.TxHash,
+		TxIndex:       msg.TxIndex,
+		MsgIndex:      msg.MsgIndex,
+		Memo:          msg.Tx.Memo,
+		GasWanted:     msg.Tx.Fee.GasWanted,
+		GasFee:        msg.Tx.Fee.GasFee.String(),
+		Signers:       signersFromTx(msg.Tx),
 	}
 }
+
+// signersFromTx extracts the signers of a transaction, in signature order
+func signersFromTx(tx std.Tx) []Signer {
+	addresses := tx.GetSigners()
+	signers := make([]Signer, 0, len(addresses))
+
+	for i, address := range addresses {
+		signer := Signer{
+			Address: address.String(),
+		}
+
+		if i < len(tx.Signatures) && tx.Signatures[i].PubKey != nil {
+			signer.PubKey = tx.Signatures[i].PubKey.String()
+		}
+
+		signers = append(signers, signer)
+	}
+
+	return signers
+}
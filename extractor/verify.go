@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// Define verify constants
+const (
+	// maxReportedProblems is the number of problems of each kind listed in the report
+	maxReportedProblems = 20
+
+	// knownGapsFile is the name of the chain directory known gaps baseline
+	knownGapsFile = "KNOWN_GAPS.txt"
+)
+
+var (
+	errMissingChainDir    = errors.New("missing chain directory argument")
+	errVerificationFailed = errors.New("archive verification failed")
+	errInvalidKnownGap    = errors.New("invalid known gap")
+)
+
+// Define verify config
+type verifyCfg struct {
+	allowEmptyOverlaps bool
+	maxLineSize        int
+}
+
+// newVerifyCmd creates the archive verification command
+func newVerifyCmd() *ffcli.Command {
+	var (
+		cfg = &verifyCfg{}
+		fs  = flag.NewFlagSet("verify", flag.ExitOnError)
+	)
+
+	// Register the flags
+	cfg.registerFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "verify",
+		ShortUsage: "verify [flags] <chaindir>",
+		ShortHelp:  "verifies the coverage and integrity of a chain archive",
+		LongHelp:   "Verifies the backup files of the chain directory cover every block up to the metadata latest block height exactly once, that every tx line decodes, and that the tx timestamps never go backward. Fails if any check fails. The gaps within the block ranges of the chain directory " + knownGapsFile + " (a <from>-<to> line per range) are reported without failing",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return execVerify(ctx, cfg, args, os.Stdout)
+		},
+	}
+}
+
+// registerFlags registers the verify command flag set
+func (c *verifyCfg) registerFlags(fs *flag.FlagSet) {
+	fs.BoolVar(
+		&c.allowEmptyOverlaps,
+		"allow-empty-overlaps",
+		false,
+		"flag indicating if the overlaps holding no tx found in both files should be reported without failing",
+	)
+
+	fs.IntVar(
+		&c.maxLineSize,
+		"max-line-size",
+		defaultMaxLineSize,
+		"the maximum size in bytes of a single tx line, longer lines are undecodable",
+	)
+}
+
+// timestampRegression is a tx whose timestamp is before the previous tx timestamp
+type timestampRegression struct {
+	file     string
+	tx       int // the number of the tx in the file, starting from 1
+	previous int64
+	current  int64
+}
+
+// archiveReport is the verification report of a chain archive
+type archiveReport struct {
+	coverage         blockCoverage
+	knownGaps        []blockRange // the known gaps baseline, nil if there is none
+	latest           uint64
+	txs              int
+	undecodable      []LineError
+	regressions      []timestampRegression
+	undecodableCount int
+	regressionCount  int
+}
+
+// execVerify runs the archive verification
+func execVerify(ctx context.Context, cfg *verifyCfg, args []string, stdout io.Writer) error {
+	chainDir, err := chainDirFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := readChainMetadata(chainDir)
+	if err != nil {
+		return err
+	}
+
+	latest, err := metadata.latestHeight()
+	if err != nil {
+		return err
+	}
+
+	files, err := listBackupFiles(chainDir)
+	if err != nil {
+		return err
+	}
+
+	coverage, err := analyzeCoverage(files)
+	if err != nil {
+		return err
+	}
+
+	coverage.expectLatest(latest)
+
+	knownGaps, err := readKnownGaps(chainDir)
+	if err != nil {
+		return err
+	}
+
+	report := &archiveReport{
+		coverage:  coverage,
+		knownGaps: knownGaps,
+		latest:    latest,
+	}
+
+	var lastTimestamp int64
+
+	for _, file := range files {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var (
+			decoder = newAutoDecoder(file.path)
+			txs     int
+		)
+
+		opts := streamOpts{
+			decodeFn: func(line []byte) (archiveEntry, error) {
+				entry, err := decoder.decode(line)
+				if err != nil {
+					return entry, err
+				}
+
+				txs++
+
+				// Archives without timestamps have nothing to compare
+				if entry.timestamp == 0 {
+					return entry, nil
+				}
+
+				if entry.timestamp < lastTimestamp {
+					report.addRegression(timestampRegression{
+						file:     file.name(),
+						tx:       txs,
+						previous: lastTimestamp,
+						current:  entry.timestamp,
+					})
+				}
+
+				lastTimestamp = max(lastTimestamp, entry.timestamp)
+
+				return entry, nil
+			},
+			rejectFn: func(lineErr LineError) error {
+				report.addUndecodable(lineErr)
+
+				return nil
+			},
+			maxLineSize: cfg.maxLineSize,
+		}
+
+		for _, err := range streamFileMessages(file.path, opts) {
+			if err != nil {
+				return err
+			}
+		}
+
+		report.txs += txs
+	}
+
+	if _, err := io.WriteString(stdout, report.render()); err != nil {
+		return fmt.Errorf("unable to write verification report, %w", err)
+	}
+
+	if !report.ok(cfg.allowEmptyOverlaps) {
+		return errVerificationFailed
+	}
+
+	return nil
+}
+
+// chainDirFromArgs returns the chain directory argument
+func chainDirFromArgs(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errMissingChainDir
+	}
+
+	if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+		return "", errInvalidChainDir
+	}
+
+	return args[0], nil
+}
+
+// readKnownGaps reads the known gaps baseline of the chain directory, if any
+func readKnownGaps(chainDir string) ([]blockRange, error) {
+	path := filepath.Join(chainDir, knownGapsFile)
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to open known gaps, %w", err)
+	}
+	defer file.Close()
+
+	return parseKnownGaps(file, knownGapsFile)
+}
+
+// parseKnownGaps parses a known gaps baseline, with a <from>-<to> line per
+// block range (ie 125107-140452). Empty lines and # comments are skipped
+func parseKnownGaps(r io.Reader, name string) ([]blockRange, error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		gaps    = make([]blockRange, 0)
+		lineNum int
+	)
+
+	for scanner.Scan() {
+		lineNum++
+
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		from, to, ok := strings.Cut(entry, "-")
+		if !ok {
+			return nil, fmt.Errorf("%w %s:%d, missing <from>-<to> separator", errInvalidKnownGap, name, lineNum)
+		}
+
+		var (
+			gap blockRange
+			err error
+		)
+
+		if gap.from, err = strconv.ParseUint(strings.TrimSpace(from), 10, 64); err != nil {
+			return nil, fmt.Errorf("%w %s:%d, invalid from block, %s", errInvalidKnownGap, name, lineNum, err)
+		}
+
+		if gap.to, err = strconv.ParseUint(strings.TrimSpace(to), 10, 64); err != nil {
+			return nil, fmt.Errorf("%w %s:%d, invalid to block, %s", errInvalidKnownGap, name, lineNum, err)
+		}
+
+		if gap.from == 0 || gap.to < gap.from {
+			return nil, fmt.Errorf("%w %s:%d, empty block range %d-%d", errInvalidKnownGap, name, lineNum, gap.from, gap.to)
+		}
+
+		gaps = append(gaps, gap)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s, %w", name, err)
+	}
+
+	return gaps, nil
+}
+
+// isKnownGap checks if the gap is within a block range of the known gaps baseline
+func (r *archiveReport) isKnownGap(gap coverageGap) bool {
+	for _, known := range r.knownGaps {
+		if gapWithin(gap, known) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gapWithin checks if the gap is within the block range
+func gapWithin(gap coverageGap, r blockRange) bool {
+	return r.from <= gap.from && gap.to <= r.to
+}
+
+// newGaps returns the gaps out of the known gaps baseline
+func (r *archiveReport) newGaps() []coverageGap {
+	var gaps []coverageGap
+
+	for _, gap := range r.coverage.gaps {
+		if !r.isKnownGap(gap) {
+			gaps = append(gaps, gap)
+		}
+	}
+
+	return gaps
+}
+
+// staleKnownGaps returns the known gaps baseline ranges holding no gap anymore,
+// as the blocks were backfilled
+func (r *archiveReport) staleKnownGaps() []blockRange {
+	var stale []blockRange
+
+	for _, known := range r.knownGaps {
+		backfilled := !slices.ContainsFunc(r.coverage.gaps, func(gap coverageGap) bool {
+			return gapWithin(gap, known)
+		})
+
+		if backfilled {
+			stale = append(stale, known)
+		}
+	}
+
+	return stale
+}
+
+// addUndecodable records an undecodable line
+func (r *archiveReport) addUndecodable(lineErr LineError) {
+	r.undecodableCount++
+
+	if len(r.undecodable) < maxReportedProblems {
+		r.undecodable = append(r.undecodable, lineErr)
+	}
+}
+
+// addRegression records a timestamp regression
+func (r *archiveReport) addRegression(regression timestampRegression) {
+	r.regressionCount++
+
+	if len(r.regressions) < maxReportedProblems {
+		r.regressions = append(r.regressions, regression)
+	}
+}
+
+// ok checks if the archive passed every check. The gaps of the known gaps
+// baseline are allowed, and so can be the overlaps holding no tx found in
+// both files, as they hold no duplicate data
+func (r *archiveReport) ok(allowEmptyOverlaps bool) bool {
+	if len(r.newGaps()) > 0 || r.coverage.covered() > r.latest {
+		return false
+	}
+
+	if r.undecodableCount > 0 || r.regressionCount > 0 {
+		return false
+	}
+
+	for _, overlap := range r.coverage.overlaps {
+		if !allowEmptyOverlaps || overlap.duplicates > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// render renders the verification report
+func (r *archiveReport) render() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "latest block height: %d\n", r.latest)
+	b.WriteString(r.coverage.render())
+
+	if r.knownGaps != nil {
+		newGaps := r.newGaps()
+
+		fmt.Fprintf(&b, "known gaps: %d, new gaps: %d\n", len(r.coverage.gaps)-len(newGaps), len(newGaps))
+
+		for _, gap := range newGaps {
+			fmt.Fprintf(&b, "  blocks %d-%d missing, not in %s\n", gap.from, gap.to, knownGapsFile)
+		}
+
+		for _, known := range r.staleKnownGaps() {
+			fmt.Fprintf(&b, "  blocks %d-%d backfilled, can be removed from %s\n", known.from, known.to, knownGapsFile)
+		}
+	}
+
+	if covered := r.coverage.covered(); covered > r.latest {
+		fmt.Fprintf(&b, "past the latest block height: blocks %d-%d\n", r.latest+1, covered)
+	}
+
+	fmt.Fprintf(&b, "txs: %d\n", r.txs)
+	fmt.Fprintf(&b, "undecodable lines: %d\n", r.undecodableCount)
+
+	for _, lineErr := range r.undecodable {
+		fmt.Fprintf(&b, "  %s:%d: %s\n", lineErr.File, lineErr.Line, lineErr.Err)
+	}
+
+	if r.undecodableCount > len(r.undecodable) {
+		fmt.Fprintf(&b, "  ... and %d more\n", r.undecodableCount-len(r.undecodable))
+	}
+
+	fmt.Fprintf(&b, "backward timestamps: %d\n", r.regressionCount)
+
+	for _, regression := range r.regressions {
+		fmt.Fprintf(
+			&b,
+			"  %s tx %d: %s after %s\n",
+			regression.file,
+			regression.tx,
+			formatTimestamp(regression.current),
+			formatTimestamp(regression.previous),
+		)
+	}
+
+	if r.regressionCount > len(r.regressions) {
+		fmt.Fprintf(&b, "  ... and %d more\n", r.regressionCount-len(r.regressions))
+	}
+
+	return b.String()
+}
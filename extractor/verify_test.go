@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// txLines renders a tx-archive line for every timestamp
+func txLines(t *testing.T, timestamps ...int64) string {
+	t.Helper()
+
+	var b strings.Builder
+
+	for _, timestamp := range timestamps {
+		line, err := amino.MarshalJSON(gnoland.TxWithMetadata{
+			Tx: std.Tx{Memo: "verify"},
+			Metadata: &gnoland.GnoTxMetadata{
+				Timestamp: timestamp,
+			},
+		})
+		require.NoError(t, err)
+
+		b.Write(line)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	verify := func(t *testing.T, cfg *verifyCfg, files map[string]string) (string, error) {
+		t.Helper()
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancelFn()
+
+		var out bytes.Buffer
+
+		err := execVerify(ctx, cfg, []string{writeBackupFiles(t, files)}, &out)
+
+		return out.String(), err
+	}
+
+	t.Run("valid archive", func(t *testing.T) {
+		t.Parallel()
+
+		legacyLine, err := amino.MarshalJSON(LegacyTx{Tx: std.Tx{Memo: "legacy"}, BlockNum: 15})
+		require.NoError(t, err)
+
+		out, err := verify(t, &verifyCfg{}, map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100, 110, 110),
+			"backup_0000011-0000020.jsonl": "\n" + string(legacyLine) + "\n" + txLines(t, 120),
+			"backup_0000021-0000030.jsonl": "",
+			"metadata.json":                `{"latest_block_height": 30}`,
+		})
+		require.NoError(t, err)
+
+		expected := "latest block height: 30\n" +
+			"coverage: blocks 1-30, 3 files\n" +
+			"gaps: 0\n" +
+			"overlaps: 0\n" +
+			"txs: 5\n" +
+			"undecodable lines: 0\n" +
+			"backward timestamps: 0\n"
+
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("incomplete coverage", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := verify(t, &verifyCfg{}, map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100),
+			"backup_0000021-0000030.jsonl": txLines(t, 120),
+			"metadata.json":                `{"latest_block_height": 40}`,
+		})
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		assert.Contains(t, out, "gaps: 2\n"+
+			"  blocks 11-20 missing, between backup_0000001-0000010.jsonl and backup_0000021-0000030.jsonl\n"+
+			"  blocks 31-40 missing, after backup_0000021-0000030.jsonl\n")
+	})
+
+	t.Run("known gaps", func(t *testing.T) {
+		t.Parallel()
+
+		files := map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100),
+			"backup_0000021-0000030.jsonl": txLines(t, 120),
+			"backup_0000041-0000050.jsonl": txLines(t, 140),
+			"metadata.json":                `{"latest_block_height": 60}`,
+			knownGapsFile:                  "# lost backups\n11-20\n31-40 # lost run\n51-60\n70-80\n",
+		}
+
+		out, err := verify(t, &verifyCfg{}, files)
+		require.NoError(t, err)
+
+		assert.Contains(t, out, "gaps: 3\n")
+		assert.Contains(t, out, "known gaps: 3, new gaps: 0\n"+
+			"  blocks 70-80 backfilled, can be removed from "+knownGapsFile+"\n")
+
+		// Only the gaps out of the baseline fail
+		files[knownGapsFile] = "11-20\n"
+
+		out, err = verify(t, &verifyCfg{}, files)
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		assert.Contains(t, out, "known gaps: 1, new gaps: 2\n"+
+			"  blocks 31-40 missing, not in "+knownGapsFile+"\n"+
+			"  blocks 51-60 missing, not in "+knownGapsFile+"\n")
+
+		files[knownGapsFile] = "11-20\n31\n"
+
+		_, err = verify(t, &verifyCfg{}, files)
+		assert.ErrorIs(t, err, errInvalidKnownGap)
+	})
+
+	t.Run("past the latest block", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := verify(t, &verifyCfg{}, map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100),
+			"metadata.json":                `{"latest_block_height": 5}`,
+		})
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		assert.Contains(t, out, "past the latest block height: blocks 6-10\n")
+	})
+
+	t.Run("no backup files", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := verify(t, &verifyCfg{}, map[string]string{
+			"metadata.json": `{"latest_block_height": 5}`,
+		})
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		assert.True(t, strings.HasPrefix(out, "latest block height: 5\n"+
+			"coverage: no backup files\n"+
+			"gaps: 1\n"+
+			"  blocks 1-5 missing\n"))
+	})
+
+	t.Run("overlaps", func(t *testing.T) {
+		t.Parallel()
+
+		empty := map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100),
+			"backup_0000010-0000020.jsonl": txLines(t, 120),
+			"metadata.json":                `{"latest_block_height": 20}`,
+		}
+
+		_, err := verify(t, &verifyCfg{}, empty)
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		// Overlaps without duplicate txs can be allowed
+		out, err := verify(t, &verifyCfg{allowEmptyOverlaps: true}, empty)
+		require.NoError(t, err)
+
+		assert.Contains(t, out, "  blocks 10-10 in backup_0000001-0000010.jsonl and backup_0000010-0000020.jsonl, 0 duplicate txs\n")
+
+		out, err = verify(t, &verifyCfg{allowEmptyOverlaps: true}, map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100),
+			"backup_0000010-0000020.jsonl": txLines(t, 100, 120),
+			"metadata.json":                `{"latest_block_height": 20}`,
+		})
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		assert.Contains(t, out, "  blocks 10-10 in backup_0000001-0000010.jsonl and backup_0000010-0000020.jsonl, 1 duplicate txs\n")
+	})
+
+	t.Run("undecodable lines", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := verify(t, &verifyCfg{}, map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 100) + "{\"tx\": \n",
+			"metadata.json":                `{"latest_block_height": 10}`,
+		})
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		assert.Contains(t, out, "txs: 1\nundecodable lines: 1\n")
+		assert.Contains(t, out, "backup_0000001-0000010.jsonl:2: ")
+	})
+
+	t.Run("backward timestamps", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := verify(t, &verifyCfg{}, map[string]string{
+			"backup_0000001-0000010.jsonl": txLines(t, 1717236000, 1717236060),
+			"backup_0000011-0000020.jsonl": txLines(t, 0, 1717236000),
+			"metadata.json":                `{"latest_block_height": 20}`,
+		})
+		assert.ErrorIs(t, err, errVerificationFailed)
+
+		assert.Contains(t, out, "backward timestamps: 1\n"+
+			"  backup_0000011-0000020.jsonl tx 2: 2024-06-01T10:00:00Z after 2024-06-01T10:01:00Z\n")
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		t.Parallel()
+
+		err := execVerify(context.Background(), &verifyCfg{}, nil, &bytes.Buffer{})
+		assert.ErrorIs(t, err, errMissingChainDir)
+
+		_, err = verify(t, &verifyCfg{}, map[string]string{})
+		assert.ErrorContains(t, err, "unable to read chain metadata")
+	})
+}
+
+func TestParseKnownGaps(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		gaps, err := parseKnownGaps(strings.NewReader("# sapphire\n125107-140452\n\n 345712 - 349482 # trailing\n"), knownGapsFile)
+		require.NoError(t, err)
+
+		assert.Equal(t, []blockRange{{from: 125107, to: 140452}, {from: 345712, to: 349482}}, gaps)
+	})
+
+	testTable := []struct {
+		name     string
+		input    string
+		contains string
+	}{
+		{"missing separator", "125107\n", "KNOWN_GAPS.txt:1, missing"},
+		{"invalid from block", "a-10\n", "KNOWN_GAPS.txt:1, invalid from block"},
+		{"invalid to block", "1-10\n11-b\n", "KNOWN_GAPS.txt:2, invalid to block"},
+		{"empty range", "20-10\n", "KNOWN_GAPS.txt:1, empty block range"},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseKnownGaps(strings.NewReader(testCase.input), knownGapsFile)
+			require.ErrorIs(t, err, errInvalidKnownGap)
+			assert.Contains(t, err.Error(), testCase.contains)
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gno/gnovm/pkg/gnomod"
+)
+
+// Define version constants
+const (
+	packageVersionsFile = "versions.json"
+	versionDirSeparator = ":v"
+)
+
+// PackageVersions defines the version index (versions.json)
+// of a single package path
+type PackageVersions struct {
+	Path     string           `json:"path"`     // the package path
+	Latest   int              `json:"latest"`   // the latest version number
+	Versions []PackageVersion `json:"versions"` // all versions, ordered by height and tx index
+}
+
+// PackageVersion defines a single deployment of a package path
+type PackageVersion struct {
+	Version      int        `json:"version"`       // the version number, starting from 1
+	Dir          string     `json:"dir"`           // the directory holding the version source code
+	Height       uint64     `json:"height"`        // the block height of the deployment
+	HeightSource string     `json:"height_source"` // block, file_range (the height is a lower bound) or unknown
+	Creator      string     `json:"creator"`       // the creator of the source code (deployer)
+	Deposit      string     `json:"deposit"`       // the deposit associated with the deployment
+	Files        []FileHash `json:"files"`         // the package files, with their hashes
+
+	Module   bool          `json:"module,omitempty"`   // flag indicating if the module file was generated
+	Requires []Requirement `json:"requires,omitempty"` // the extracted versions the generated module file points at
+}
+
+// FileHash defines the hash of a single package file
+type FileHash struct {
+	Name   string `json:"name"`   // the file name
+	SHA256 string `json:"sha256"` // the hex encoded SHA-256 of the file body
+}
+
+// versionStore keeps the version history of every package path
+// that was written during a single extractor run.
+//
+// The latest version of a package always lives in the package output dir,
+// while every superseded version N is moved to the sibling <dir>:vN.
+// The versions.json index in the package output dir lists all of them
+type versionStore struct {
+	rootDir   string                      // the extractor output root
+	histories map[string]*PackageVersions // output dir -> package history
+
+	// Incremental runs carry on with the package histories of the previous run
+	incremental bool
+
+	// Packages deployed without a module file get a generated one
+	modules bool
+}
+
+// newVersionStore creates a new, empty package version store
+func newVersionStore(rootDir string) *versionStore {
+	return &versionStore{
+		rootDir:   rootDir,
+		histories: make(map[string]*PackageVersions),
+	}
+}
+
+// write writes the given package as a new version of its package path
+func (s *versionStore) write(msg AddPackage, outputDir string) error {
+	history, ok := s.histories[outputDir]
+	if !ok {
+		var err error
+
+		// First time the path is seen in this run
+		history, err = s.history(msg.Package.Path, outputDir)
+		if err != nil {
+			return err
+		}
+
+		s.histories[outputDir] = history
+	}
+
+	// Pin the imports to the versions extracted so far, if the package has no module file
+	var (
+		module   *gnomod.File
+		requires []Requirement
+	)
+
+	if s.modules && !hasModuleFile(msg.Package.Files) {
+		requires = s.requirements(msg)
+		module = s.moduleFile(msg.Package.Path, outputDir, requires)
+	}
+
+	// Archive the current latest version, if any
+	if history.Latest > 0 {
+		archiveDir := versionDir(outputDir, history.Latest)
+
+		if err := movePackageFiles(outputDir, archiveDir); err != nil {
+			return err
+		}
+
+		history.Versions[history.Latest-1].Dir = filepath.Base(archiveDir)
+	}
+
+	// Write dir before writing files
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write dir, %w", err)
+	}
+
+	// Write the package source code
+	if err := writePackageFiles(msg, outputDir, module); err != nil {
+		return err
+	}
+
+	// Write the package metadata
+	if err := writePackageMetadata(metadataFromMsg(msg), outputDir); err != nil {
+		return err
+	}
+
+	// Update the version index
+	history.Latest = len(history.Versions) + 1
+
+	version := versionFromMsg(msg, history.Latest, filepath.Base(outputDir))
+	version.Module = module != nil
+	version.Requires = requires
+
+	history.Versions = append(history.Versions, version)
+
+	return writePackageVersions(history, outputDir)
+}
+
+// history returns the version history of a package path seen for the first time
+// in this run. Incremental runs load the history left by the previous run, while
+// full runs drop whatever a previous run left behind
+func (s *versionStore) history(pkgPath, outputDir string) (*PackageVersions, error) {
+	if s.incremental {
+		history, err := readPackageVersions(outputDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if history != nil {
+			return history, nil
+		}
+	}
+
+	if err := resetPackageDir(outputDir); err != nil {
+		return nil, err
+	}
+
+	return &PackageVersions{
+		Path: pkgPath,
+	}, nil
+}
+
+// versionFromMsg creates the version index entry for the given package
+func versionFromMsg(msg AddPackage, version int, dir string) PackageVersion {
+	md := metadataFromMsg(msg)
+
+	files := make([]FileHash, 0, len(msg.Package.Files))
+	for _, file := range msg.Package.Files {
+		hash := sha256.Sum256([]byte(file.Body))
+
+		files = append(files, FileHash{
+			Name:   file.Name,
+			SHA256: hex.EncodeToString(hash[:]),
+		})
+	}
+
+	return PackageVersion{
+		Version:      version,
+		Dir:          dir,
+		Height:       md.Height,
+		HeightSource: md.HeightSource,
+		Creator:      md.Creator,
+		Deposit:      md.Deposit,
+		Files:        files,
+	}
+}
+
+// versionDir returns the archive directory of the given package version
+func versionDir(outputDir string, version int) string {
+	return outputDir + versionDirSeparator + strconv.Itoa(version)
+}
+
+// resetPackageDir removes the package files from the output dir, along with
+// any sibling version dirs (<dir>:<suffix>). Nested package dirs are kept
+func resetPackageDir(outputDir string) error {
+	if err := removePackageFiles(outputDir); err != nil {
+		return err
+	}
+
+	var (
+		parent = filepath.Dir(outputDir)
+		prefix = filepath.Base(outputDir) + ":"
+	)
+
+	entries, err := os.ReadDir(parent)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read dir, %w", err)
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(parent, entry.Name())); err != nil {
+			return fmt.Errorf("unable to remove stale version dir, %w", err)
+		}
+	}
+
+	return nil
+}
+
+// removePackageFiles removes all regular files from the package output dir
+func removePackageFiles(outputDir string) error {
+	entries, err := os.ReadDir(outputDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read dir, %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(outputDir, entry.Name())); err != nil {
+			return fmt.Errorf("unable to remove file %s, %w", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// movePackageFiles moves the package files (everything except
+// the version index and nested package dirs) into the archive dir
+func movePackageFiles(outputDir, archiveDir string) error {
+	if err := os.MkdirAll(archiveDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write dir, %w", err)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return fmt.Errorf("unable to read dir, %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == packageVersionsFile {
+			continue
+		}
+
+		if err := os.Rename(
+			filepath.Join(outputDir, entry.Name()),
+			filepath.Join(archiveDir, entry.Name()),
+		); err != nil {
+			return fmt.Errorf("unable to archive file %s, %w", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// readPackageVersions reads the package version index from the output directory,
+// returning nil if there is none
+func readPackageVersions(outputDir string) (*PackageVersions, error) {
+	versionsRaw, err := os.ReadFile(filepath.Join(outputDir, packageVersionsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read package versions, %w", err)
+	}
+
+	var versions PackageVersions
+	if err := json.Unmarshal(versionsRaw, &versions); err != nil {
+		return nil, fmt.Errorf("unable to JSON unmarshal package versions, %w", err)
+	}
+
+	return &versions, nil
+}
+
+// writePackageVersions writes the package version index to the output directory
+func writePackageVersions(versions *PackageVersions, outputDir string) error {
+	// Get the output path
+	writePath := filepath.Join(outputDir, packageVersionsFile)
+
+	// Get the JSON index
+	versionsRaw, marshalErr := json.MarshalIndent(versions, "", "  ")
+	if marshalErr != nil {
+		return fmt.Errorf("unable to JSON marshal package versions, %w", marshalErr)
+	}
+
+	if writeErr := os.WriteFile(writePath, versionsRaw, 0o644); writeErr != nil {
+		return fmt.Errorf("unable to write package versions, %w", writeErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/gnoland"
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/std"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionStore_History(t *testing.T) {
+	t.Parallel()
+
+	var (
+		outputDir = t.TempDir()
+		sourceDir = t.TempDir()
+
+		creator = addressFromString(t, "g1jg8mtutu9khhfwc4nxmuhcpftf0pajdhfvsqf5")
+	)
+
+	newMsg := func(path string, files ...*std.MemFile) vm.MsgAddPackage {
+		return vm.MsgAddPackage{
+			Creator: creator,
+			Package: &std.MemPackage{
+				Name:  filepath.Base(path),
+				Path:  path,
+				Files: files,
+			},
+		}
+	}
+
+	var (
+		v1 = newMsg("gno.land/r/demo/foo", &std.MemFile{Name: "a.gno", Body: "v1"}, &std.MemFile{Name: "old.gno", Body: "v1"})
+		v2 = newMsg("gno.land/r/demo/foo", &std.MemFile{Name: "a.gno", Body: "v2"})
+		v3 = newMsg("gno.land/r/demo/foo", &std.MemFile{Name: "a.gno", Body: "v3"})
+
+		nested = newMsg("gno.land/r/demo/foo/bar", &std.MemFile{Name: "bar.gno", Body: "bar"})
+	)
+
+	file, err := os.Create(filepath.Join(sourceDir, "backup_0000001-0000100.jsonl"))
+	require.NoError(t, err)
+
+	for i, msg := range []vm.MsgAddPackage{v1, nested, v2, v3} {
+		require.NoError(t, writeTxToFile(t, gnoland.TxWithMetadata{
+			Tx:       std.Tx{Msgs: []std.Msg{msg}},
+			Metadata: &gnoland.GnoTxMetadata{BlockHeight: int64(10 * (i + 1))},
+		}, file))
+	}
+
+	require.NoError(t, file.Close())
+
+	// Leave behind a directory from the old height suffixed layout
+	basePath := filepath.Join(outputDir, "r", "demo", "foo")
+	require.NoError(t, os.MkdirAll(basePath+":0", os.ModePerm))
+
+	cfg := &extractorCfg{
+		sourceCfg: sourceCfg{fileType: sourceFileType, sourcePath: sourceDir},
+		outputDir: outputDir,
+	}
+
+	// Run the extraction twice, the output should be the same
+	for i := 0; i < 2; i++ {
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+		require.NoError(t, execExtract(ctx, cfg))
+		cancelFn()
+
+		// Check the latest version
+		body, err := os.ReadFile(filepath.Join(basePath, "a.gno"))
+		require.NoError(t, err)
+		assert.Equal(t, "v3", string(body))
+		assert.NoFileExists(t, filepath.Join(basePath, "old.gno"))
+		assert.Equal(t, uint64(40), readMetadata(t, basePath).Height)
+
+		// Check the archived versions
+		body, err = os.ReadFile(filepath.Join(versionDir(basePath, 1), "old.gno"))
+		require.NoError(t, err)
+		assert.Equal(t, "v1", string(body))
+		assert.Equal(t, uint64(10), readMetadata(t, versionDir(basePath, 1)).Height)
+		assert.Equal(t, uint64(30), readMetadata(t, versionDir(basePath, 2)).Height)
+		assert.NoDirExists(t, versionDir(basePath, 3))
+		assert.NoDirExists(t, basePath+":0")
+
+		// Check the nested package is untouched
+		assert.FileExists(t, filepath.Join(basePath, "bar", "bar.gno"))
+
+		// Check the version index
+		raw, err := os.ReadFile(filepath.Join(basePath, packageVersionsFile))
+		require.NoError(t, err)
+
+		var versions PackageVersions
+		require.NoError(t, json.Unmarshal(raw, &versions))
+
+		assert.Equal(t, "gno.land/r/demo/foo", versions.Path)
+		assert.Equal(t, 3, versions.Latest)
+		require.Len(t, versions.Versions, 3)
+
+		for i, expected := range []struct {
+			dir    string
+			height uint64
+			files  int
+		}{
+			{"foo:v1", 10, 2},
+			{"foo:v2", 30, 1},
+			{"foo", 40, 1},
+		} {
+			assert.Equal(t, i+1, versions.Versions[i].Version)
+			assert.Equal(t, expected.dir, versions.Versions[i].Dir)
+			assert.Equal(t, expected.height, versions.Versions[i].Height)
+			assert.Equal(t, creator.String(), versions.Versions[i].Creator)
+			assert.Len(t, versions.Versions[i].Files, expected.files)
+		}
+
+		// sha256("v3")
+		assert.Equal(
+			t,
+			"e0d2747b9ab7abb6eb65e0373fa1b428a28bd6d8a2380106dcc080f58005ee14",
+			versions.Versions[2].Files[0].SHA256,
+		)
+	}
+}
@@ -1,45 +1,25 @@
 EXTRACTOR_DIR ?= extractor
+# file type of the archive files read by the extractor
+EXTRACTOR_FILE_TYPE ?= .jsonl
+# number of archive files decoded concurrently by the extractor,
+# the extracted tree is the same whatever the number of workers
+EXTRACTOR_WORKERS ?= 4
 
-# tx-archive lives in the gnolang/gno monorepo under contribs/tx-archive.
-# contribs/*/go.mod files use `replace github.com/gnolang/gno => ../..` so
-# `go run <path>@version` does not work remotely — we must build from a
-# local checkout.
-#
-# GNO_REF must match the build the target chain runs, so each chain Makefile
-# pins its node's `chain/*` tag. tx-archive amino-decodes the node's block
-# results with the gno types from this ref, and amino rejects unknown JSON
-# fields: on `master` the fetch dies with `unknown JSON field "errors" for type
-# vm.TypeCheckError` against gnoland1, whose build still carries that field
-# (dropped from master in #5893).
-GNO_REF      ?= master
-# Ref is part of the path so bumping GNO_REF re-clones instead of silently
-# reusing a checkout of the previous ref.
-GNO_REPO     ?= $(HOME)/.cache/tx-exports/gno-$(subst /,-,$(GNO_REF))
-TXARCHIVE    ?= $(GNO_REPO)/contribs/tx-archive
+# the most blocks backed up by a single fetch, chain Makefiles lower it when
+# the RPC node limits the fetches
+MAX_INTERVAL ?= 100000
 
 .PHONY: all
 all:
 	@echo 'use make fetch or make fetch-all to download blocks'
 	$(MAKE) join stats extractor
 
-$(TXARCHIVE)/cmd/main.go:
-	@mkdir -p $(dir $(GNO_REPO))
-	@if [ ! -d $(GNO_REPO) ]; then \
-		git clone --depth=1 --branch $(GNO_REF) https://github.com/gnolang/gno.git $(GNO_REPO); \
-	else \
-		git -C $(GNO_REPO) fetch --depth=1 origin $(GNO_REF) && \
-		git -C $(GNO_REPO) checkout FETCH_HEAD; \
-	fi
-
-.PHONY: tx-archive-ensure
-tx-archive-ensure: $(TXARCHIVE)/cmd/main.go ## clone/update the gno repo so tx-archive is runnable
-
 # Backup transport selection.
 # Set USE_WS=1 in a chain Makefile to fetch over a WebSocket connection
 # (wss://<host>/websocket) instead of HTTP(S). A single long-lived WS connection
 # avoids the per-request rate limiting / WAF blocks that some RPC endpoints apply
 # to high-volume HTTP batch fetches, at the cost of slower fetches (tx results are
-# not batched over WS). REMOTE stays HTTP(S) so the latest-block curl still works.
+# not batched over WS).
 ifeq ($(USE_WS),1)
 WS_FLAG       = -ws
 BACKUP_REMOTE = $(shell echo $(REMOTE) | tr -d '"' | sed -e 's#^http://#ws://#' -e 's#^https://#wss://#')/websocket
@@ -48,119 +28,102 @@ WS_FLAG       =
 BACKUP_REMOTE = $(REMOTE)
 endif
 
-# --batch 100: the RPC nodes enforce a 10s WebSocket write deadline (tm2
+# The extractor `fetch` subcommand runs the tx-archive backup in-process: it
+# reads the range to fetch from metadata.json and the node status itself, caps
+# it to MAX_INTERVAL, and updates metadata.json atomically (no gno checkout, jq,
+# bc or curl needed).
+#
+# -batch 100: the RPC nodes enforce a 10s WebSocket write deadline (tm2
 # defaultWSWriteWait). Assembling a batch response for tx-archive's default
 # 1000 blocks takes longer than that in tx-dense ranges, and the server
 # drops the connection mid-fetch.
 .PHONY: fetch
-fetch: tx-archive-ensure
-	@echo "Backup from: $(FROM_BLOCK) to $(TO_BLOCK)"
-	cd $(TXARCHIVE) && go run ./cmd backup -verbose $(WS_FLAG) \
-		--batch 100 \
-		--remote $(BACKUP_REMOTE) \
-		--from-block $(FROM_BLOCK) \
-		--to-block   $(TO_BLOCK) \
-		--output-path "$(shell pwd)/backup_$(shell printf '%07d' $(FROM_BLOCK))-$(shell printf '%07d' $(TO_BLOCK)).jsonl"
-
-    # Update metadata
-	@cat metadata.json | jq -a '.latest_block_height = $(TO_BLOCK)' > /tmp/aa.json
-	@mv /tmp/aa.json metadata.json
+fetch:
+	go run -C "../$(EXTRACTOR_DIR)" . fetch $(WS_FLAG) $(FETCH_FLAGS) \
+		-batch 100 \
+		-remote $(BACKUP_REMOTE) \
+		-max-interval $(MAX_INTERVAL) \
+		-chain-dir "$(shell pwd)"
 
 .PHONY: fetch-all
 fetch-all:
-	@for i in `seq $(FROM_BLOCK) $(MAX_INTERVAL) $(LATEST_BLOCK_HEIGHT)`; do \
-		make -C . fetch FROM_BLOCK="$$i"; \
-	done
+	$(MAKE) -C . fetch FETCH_FLAGS=-all
+
+# The stats are computed by the extractor `stats` subcommand, which decodes
+# the backup files with the same gno types as the extractor (no jq needed).
+# FAUCET / FAUCET_MIN can be set in a chain Makefile to track another faucet.
+STATS_FLAGS = $(if $(FAUCET),-faucet $(FAUCET)) $(if $(FAUCET_MIN),-faucet-min $(FAUCET_MIN))
 
 .PHONY: stats
 stats:
-	echo "# $(REMOTE)" > README.md
-	echo >> README.md
-
-	echo "## TXs" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | wc -l >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-	echo "## addpkgs" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | jq '.tx.msg[].package.Path | select( . != null )' | sort | uniq -c | sort --stable -nr >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-	echo "## top realm calls" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | jq '.tx.msg[].pkg_path | select( . != null )' | sort | uniq -c | sort --stable -nr >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-	echo "## top faucet requesters" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | jq -r '.tx.msg[] | select(.["@type"]=="/bank.MsgSend") | select(.["from_address"]=="g127jydsh6cms3lrtdenydxsckh23a8d6emqcvfa") | .to_address + " " + .amount' | sed 's/ugnot$$//' | awk 'NR == 1 {next} {a[$$1] += $$2} {b[$$1] += 1} END {for (i in a) {if (a[i] >= 500000000){printf "%-15s\t%s\t%s\n", i, b[i], a[i]}}}' | sort -rnk2 >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-stats-legacy:
-	echo "# $(REMOTE)" > README.md
-	echo >> README.md
-
-	echo "## TXs" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | wc -l >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-	echo "## addpkgs" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | jq '.msg[].package.Path | select( . != null )' | sort | uniq -c | sort --stable -nr >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-	echo "## top realm calls" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | jq '.msg[].pkg_path | select( . != null )' | sort | uniq -c | sort --stable -nr >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-	echo "## top faucet requesters" >> README.md
-	echo '```' >> README.md
-	cat backup_*.jsonl | jq -r '.msg[] | select(.["@type"]=="/bank.MsgSend") | select(.["from_address"]=="g127jydsh6cms3lrtdenydxsckh23a8d6emqcvfa") | .to_address + " " + .amount' | sed 's/ugnot$$//' | awk 'NR == 1 {next} {a[$$1] += $$2} {b[$$1] += 1} END {for (i in a) {if (a[i] >= 500000000){printf "%-15s\t%s\t%s\n", i, b[i], a[i]}}}' | sort -rnk2 >> README.md
-	echo '```' >> README.md
-	echo >> README.md
-
-
-# Only files with contiguous block ranges may be merged: a coverage gap
-# between two files must stay visible in the file names.
+	go run -C "../$(EXTRACTOR_DIR)" . stats $(STATS_FLAGS) \
+		-file-type "$(EXTRACTOR_FILE_TYPE)" \
+		-source-path "$(shell pwd)" \
+		-title $(REMOTE) \
+		-output-path "$(shell pwd)/README.md"
+
+# The tx sheet format is detected for every line, so the legacy
+# (test2 - test4) archives no longer need a dedicated target.
+.PHONY: stats-legacy
+stats-legacy: stats
+
+# The bank send ledger (or, with TRANSFERS_FORMAT, the flow graph as markdown,
+# json, edges or graphml) is written to stdout. TRANSFERS_FROM limits it to the
+# sends of comma separated addresses, ie TRANSFERS_FROM=$(FAUCET).
+TRANSFERS_FORMAT ?= jsonl
+
+.PHONY: transfers
+transfers:
+	@go run -C "../$(EXTRACTOR_DIR)" . transfers \
+		$(if $(TRANSFERS_FROM),-from-address $(TRANSFERS_FROM)) \
+		-file-type "$(EXTRACTOR_FILE_TYPE)" \
+		-source-path "$(shell pwd)" \
+		-format $(TRANSFERS_FORMAT) \
+		-title $(REMOTE)
+
+
+# Only files with contiguous block ranges are joined: a coverage gap or an
+# overlap between two files stays visible in the file names, and is listed in
+# the coverage report printed by the extractor `join` subcommand.
+JOIN_MAX_SIZE ?= 102400
+
 .PHONY: join
 join:
-	@echo "Joining small backup files..."
-	@prev_file=""; \
-	for f in $$(ls backup_*.jsonl 2>/dev/null | sort); do \
-		size=$$(stat -c%s "$$f"); \
-		f_start=$$(echo "$$f" | sed 's/backup_0*\([0-9][0-9]*\)-.*/\1/'); \
-		if [ -n "$$prev_file" ]; then \
-			prev_size=$$(stat -c%s "$$prev_file"); \
-			prev_end=$$(echo "$$prev_file" | sed 's/backup_[0-9]*-0*\([0-9][0-9]*\)\..*/\1/'); \
-			if [ $$((prev_size + size)) -lt 102400 ] && [ "$$f_start" -eq $$((prev_end + 1)) ]; then \
-				start=$$(echo "$$prev_file" | sed 's/backup_\([0-9]*\)-.*/\1/'); \
-				end=$$(echo "$$f" | sed 's/backup_[0-9]*-\([0-9]*\)\..*/\1/'); \
-				new_file="backup_$${start}-$${end}.jsonl"; \
-				cat "$$prev_file" "$$f" > "$${new_file}.tmp"; \
-				rm "$$prev_file" "$$f"; \
-				mv "$${new_file}.tmp" "$$new_file"; \
-				prev_file="$$new_file"; \
-				echo "  Joined -> $$new_file ($$(stat -c%s "$$new_file") bytes)"; \
-				continue; \
-			fi; \
-		fi; \
-		prev_file="$$f"; \
-	done
-	@echo "Done."
-
+	go run -C "../$(EXTRACTOR_DIR)" . join \
+		-max-size $(JOIN_MAX_SIZE) \
+		-chain-dir "$(shell pwd)"
+
+# The archive must cover every block up to metadata.json latest_block_height
+# exactly once, with decodable tx lines whose timestamps never go backward.
+# VERIFY_FLAGS can be set in a chain Makefile (ie -allow-empty-overlaps).
+.PHONY: verify
+verify:
+	go run -C "../$(EXTRACTOR_DIR)" . verify $(VERIFY_FLAGS) "$(shell pwd)"
+
+# MANIFEST.json records the block range, line count, size, SHA-256 and first /
+# last tx timestamp of every archive file, so that a truncated or hand edited
+# archive file is detected by verify-manifest.
+.PHONY: manifest
+manifest:
+	go run -C "../$(EXTRACTOR_DIR)" . manifest \
+		-file-type "$(EXTRACTOR_FILE_TYPE)" \
+		"$(shell pwd)"
+
+.PHONY: verify-manifest
+verify-manifest:
+	go run -C "../$(EXTRACTOR_DIR)" . verify-manifest \
+		-file-type "$(EXTRACTOR_FILE_TYPE)" \
+		"$(shell pwd)"
+
+# The extraction is incremental: extracted/extractor-state.json records the
+# extracted content, so only the content fetched since the last run is extracted
+# (including the content a join appended to an already extracted file).
 .PHONY: extractor
 extractor:
 	go run -C "../$(EXTRACTOR_DIR)" . \
+		-incremental \
+		-file-type "$(EXTRACTOR_FILE_TYPE)" \
+		-workers $(EXTRACTOR_WORKERS) \
 		-source-path "$(shell pwd)" \
 		-output-dir "$(shell pwd)/extracted"
 